@@ -0,0 +1,66 @@
+package pakr
+
+// Options that control a call to ResolveBatch()
+type ResolveBatchOptions struct {
+	// Stop at the first set of requirements that can not be satisfied,
+	// instead of resolving every set
+	StopOnFirstConflict bool
+}
+
+// The result of resolving one set of requirements in ResolveBatch()
+type BatchResult struct {
+	// The set of requirements that was resolved
+	Requires Packages
+	// Whether the requirements were satisfied
+	Solved bool
+	// The solution, when the requirements were satisfied
+	Solution Packages
+	// The requirements that caused the resolve to fail,
+	// when the requirements were not satisfied
+	Conflicts Packages
+}
+
+// Resolves each of a list of requirement sets in order, against the same
+// package index and permanent constraints, keeping the solver warm between
+// them as with SolveIncremental().
+//
+// When opts.StopOnFirstConflict is set, the batch stops as soon as a set
+// can not be satisfied, and the results only go up to and include the
+// failing set. Otherwise every set is resolved and has a result.
+//
+// Returns the results, in the order of the sets, and the index of the first
+// set that could not be satisfied, or -1 if every resolved set succeeded.
+// The requirements of the Resolver are restored afterwards, but the last
+// solve of the batch remains the current solve.
+// Returns a non-nil error if there was an internal error.
+func (r *Resolver) ResolveBatch(sets []Packages, opts ResolveBatchOptions) ([]BatchResult, int, error) {
+	requires := r.requires
+	defer func() {
+		r.requires = requires
+	}()
+
+	failed := -1
+	results := make([]BatchResult, 0, len(sets))
+	for i, set := range sets {
+		ok, err := r.SolveIncremental(set)
+		if err != nil {
+			return results, failed, err
+		}
+
+		result := BatchResult{Requires: set, Solved: ok}
+		if ok {
+			result.Solution = r.Solution()
+		} else {
+			result.Conflicts = r.Conflicts()
+			if failed < 0 {
+				failed = i
+			}
+		}
+		results = append(results, result)
+
+		if !ok && opts.StopOnFirstConflict {
+			break
+		}
+	}
+	return results, failed, nil
+}
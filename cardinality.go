@@ -0,0 +1,115 @@
+package pakr
+
+import (
+	"github.com/justinfx/pigosat"
+)
+
+// atMostK builds clauses that allow at most k of the given literals
+// to be true, using a sequential counter encoding. The encoding uses
+// auxiliary literals created by newLit, which must return ids that
+// are not used by any other clause.
+func atMostK(lits []pigosat.Literal, k int, newLit func() pigosat.Literal) pigosat.Formula {
+	n := len(lits)
+	clauses := pigosat.Formula{}
+
+	if k >= n {
+		return clauses
+	}
+	if k <= 0 {
+		for _, x := range lits {
+			clauses = append(clauses, []pigosat.Literal{-x})
+		}
+		return clauses
+	}
+
+	// s[i][j] is true if at least j+1 of the first i+1 literals are true
+	s := make([][]pigosat.Literal, n-1)
+	for i := range s {
+		s[i] = make([]pigosat.Literal, k)
+		for j := range s[i] {
+			s[i][j] = newLit()
+		}
+	}
+
+	clauses = append(clauses, []pigosat.Literal{-lits[0], s[0][0]})
+	for j := 1; j < k; j++ {
+		clauses = append(clauses, []pigosat.Literal{-s[0][j]})
+	}
+
+	for i := 1; i < n-1; i++ {
+		x := lits[i]
+		clauses = append(clauses,
+			[]pigosat.Literal{-x, s[i][0]},
+			[]pigosat.Literal{-s[i-1][0], s[i][0]},
+		)
+		for j := 1; j < k; j++ {
+			clauses = append(clauses,
+				[]pigosat.Literal{-x, -s[i-1][j-1], s[i][j]},
+				[]pigosat.Literal{-s[i-1][j], s[i][j]},
+			)
+		}
+		clauses = append(clauses, []pigosat.Literal{-x, -s[i-1][k-1]})
+	}
+
+	clauses = append(clauses, []pigosat.Literal{-lits[n-1], -s[n-2][k-1]})
+	return clauses
+}
+
+// atMostWeighted builds clauses that allow the sum of the weights of
+// the true literals to be at most k, using a sequential weight counter
+// encoding. Literals with a weight of zero or less are unconstrained, and
+// literals with a weight above k are always false. The encoding uses
+// auxiliary literals created by newLit, which must return ids that are
+// not used by any other clause. Its size grows with len(lits) * k.
+func atMostWeighted(lits []pigosat.Literal, weights []int, k int, newLit func() pigosat.Literal) pigosat.Formula {
+	clauses := pigosat.Formula{}
+
+	// Only the literals that can contribute to the sum are counted
+	var (
+		xs []pigosat.Literal
+		ws []int
+	)
+	for i, x := range lits {
+		switch w := weights[i]; {
+		case w <= 0:
+			continue
+		case w > k:
+			clauses = append(clauses, []pigosat.Literal{-x})
+		default:
+			xs = append(xs, x)
+			ws = append(ws, w)
+		}
+	}
+	if len(xs) == 0 {
+		return clauses
+	}
+
+	// s[i][j] is true if the sum of the weights of the
+	// first i+1 literals is at least j+1
+	s := make([][]pigosat.Literal, len(xs))
+	for i := range s {
+		s[i] = make([]pigosat.Literal, k)
+		for j := range s[i] {
+			s[i][j] = newLit()
+		}
+	}
+
+	for i, x := range xs {
+		w := ws[i]
+		for j := 0; j < w; j++ {
+			clauses = append(clauses, []pigosat.Literal{-x, s[i][j]})
+		}
+		if i == 0 {
+			continue
+		}
+		for j := 0; j < k; j++ {
+			clauses = append(clauses, []pigosat.Literal{-s[i-1][j], s[i][j]})
+		}
+		for j := 0; j+w < k; j++ {
+			clauses = append(clauses, []pigosat.Literal{-x, -s[i-1][j], s[i][j+w]})
+		}
+		// Adding this literal to a sum above k-w would exceed k
+		clauses = append(clauses, []pigosat.Literal{-x, -s[i-1][k-w]})
+	}
+	return clauses
+}
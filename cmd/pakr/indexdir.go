@@ -0,0 +1,84 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/justinfx/pakr"
+)
+
+// indexFiles returns the sorted paths of the *.json files in a
+// directory, and optionally in all of its subdirectories
+func indexFiles(dir string, recursive bool) ([]string, error) {
+	var paths []string
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if path != dir && !recursive {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if strings.HasSuffix(d.Name(), ".json") {
+			paths = append(paths, path)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(paths)
+	return paths, nil
+}
+
+// ReadIndexDir reads every *.json file in a directory as a fragment of
+// the index, using the IndexParser, and merges the fragments into a
+// single index with pakr.MergeIndexes(). A target Package that is defined
+// in more than one fragment is an error. Subdirectories are only read
+// when recursive is true. Errors name the offending file.
+func ReadIndexDir(parser IndexParser, dir string, recursive bool) ([]pakr.Dependency, error) {
+	paths, err := indexFiles(dir, recursive)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to read index directory: %s", err)
+	}
+	if len(paths) == 0 {
+		return nil, fmt.Errorf("No *.json index files found in %s", dir)
+	}
+
+	fragments := make([][]pakr.Dependency, len(paths))
+	for i, path := range paths {
+		if fragments[i], err = readIndexFile(parser, path); err != nil {
+			return nil, err
+		}
+	}
+
+	deps, err := pakr.MergeIndexes(fragments...)
+	var conflict *pakr.IndexConflictError
+	if errors.As(err, &conflict) {
+		return nil, fmt.Errorf("Package %s in index file %s is already defined in %s",
+			conflict.Package, paths[conflict.Second], paths[conflict.First])
+	}
+	return deps, err
+}
+
+// readIndexFile parses a single index file
+func readIndexFile(parser IndexParser, path string) ([]pakr.Dependency, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	deps, err := parser.ParseIndex(f)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to parse index file %s: %s", path, err)
+	}
+	return deps, nil
+}
@@ -9,15 +9,27 @@ import (
 	"io"
 	"log"
 	"os"
+	"os/signal"
 	"runtime"
+	"sort"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/justinfx/pakr"
 )
 
 var (
 	optIndexPath = flag.String("index", "", "Path to Index/Repo JSON file")
+	optIndexDir  = flag.String("index-dir", "", "Path to a directory of Index JSON fragments, used instead of -index")
+	optIndexRec  = flag.Bool("index-recursive", false, "Also read the Index JSON fragments in subdirectories of -index-dir")
 	optReqsPath  = flag.String("reqs", "", "Path to Requirements JSON file")
+	optProgress  = flag.Bool("progress", false, "Report the progress of reading the Index file to stderr")
+	optParser    = flag.String("parser", "json", "Name of the registered parser used to read the index and requirements")
+	optMaxSols   = flag.Int("max-solutions", 1, "Maximum number of alternative solutions to output (0 for all)")
+	optFormat    = flag.String("format", "json", "Output format: json, jsonl for a line per package, or table for human readable output")
+	optSchema    = flag.Int("schema", 1, "Version of the json output schema")
+	optProcs     = flag.Int("procs", runtime.NumCPU(), "Maximum number of CPUs used to parse and resolve")
 )
 
 var usage = `Usage:  %s -index <index.json> -reqs <reqs.json>
@@ -33,8 +45,6 @@ and either the package solution or an error messages explaining the failure.
 `
 
 func main() {
-	runtime.GOMAXPROCS(2)
-
 	// Handle command line flags
 	flag.Usage = func() {
 		fmt.Fprintf(os.Stderr, usage, os.Args[0])
@@ -43,19 +53,60 @@ func main() {
 
 	flag.Parse()
 
-	if *optIndexPath == "" {
-		log.Fatalln("-index flag is required")
+	if *optProcs < 1 {
+		log.Fatalln("-procs must be at least 1")
+	}
+	runtime.GOMAXPROCS(*optProcs)
+
+	if *optIndexPath == "" && *optIndexDir == "" {
+		log.Fatalln("-index or -index-dir flag is required")
+	}
+	if *optIndexPath != "" && *optIndexDir != "" {
+		log.Fatalln("-index and -index-dir flags cannot be used together")
 	}
 
 	if *optReqsPath == "" {
 		log.Fatalln("-reqs flag is required")
 	}
 
-	idxFile, err := os.Open(*optIndexPath)
-	if err != nil {
-		log.Fatalf("Failed to open Index JSON file: %s", err)
+	var (
+		idxFile   *os.File
+		idxReader *CountingReader
+	)
+	if *optIndexPath != "" {
+		var err error
+		if idxFile, err = os.Open(*optIndexPath); err != nil {
+			log.Fatalf("Failed to open Index JSON file: %s", err)
+		}
+		defer idxFile.Close()
+
+		// Count the bytes parsed from the index, for the progress
+		// report. Only stderr is used, as stdout holds the results.
+		var total int64
+		if info, err := idxFile.Stat(); err == nil {
+			total = info.Size()
+		}
+		var progress io.Writer
+		if *optProgress {
+			progress = os.Stderr
+		}
+		idxReader = NewCountingReader(idxFile, total, progress, "Reading index", 250*time.Millisecond)
 	}
-	defer idxFile.Close()
+
+	// Abort cleanly on an interrupt, reporting how far the run got
+	var stage atomic.Value
+	stage.Store("starting")
+
+	interrupts := make(chan os.Signal, 1)
+	signal.Notify(interrupts, os.Interrupt)
+	go func() {
+		<-interrupts
+		fmt.Fprintf(os.Stderr, "\nInterrupted while %s\n", stage.Load())
+		if idxReader != nil {
+			fmt.Fprintln(os.Stderr, idxReader.Progress())
+		}
+		os.Exit(130)
+	}()
 
 	reqsFile, err := os.Open(*optReqsPath)
 	if err != nil {
@@ -63,7 +114,17 @@ func main() {
 	}
 	defer reqsFile.Close()
 
+	if _, ok := schemaVersions[*optSchema]; !ok {
+		log.Fatalf("Unsupported output schema version %d", *optSchema)
+	}
+
+	parser, err := GetParser(*optParser)
+	if err != nil {
+		log.Fatal(err.Error())
+	}
+
 	// Parse data
+	stage.Store("parsing the index and requirements")
 	var wg sync.WaitGroup
 	wg.Add(2)
 
@@ -72,7 +133,7 @@ func main() {
 
 	go func() {
 		var err error
-		reqs, err = ParseReqs(reqsFile)
+		reqs, err = parser.ParseReqs(reqsFile)
 		if err != nil {
 			log.Fatalf("Failed to parse JSON from Requirements file: %s", err)
 		}
@@ -81,8 +142,11 @@ func main() {
 
 	go func() {
 		var err error
-		idx, err = ParseIndex(idxFile)
-		if err != nil {
+		if idxFile == nil {
+			if idx, err = ReadIndexDir(parser, *optIndexDir, *optIndexRec); err != nil {
+				log.Fatal(err.Error())
+			}
+		} else if idx, err = parser.ParseIndex(idxReader); err != nil {
 			log.Fatalf("Failed to parse JSON from Index file: %s", err)
 		}
 		wg.Done()
@@ -90,10 +154,36 @@ func main() {
 
 	wg.Wait()
 
-	resolver := pakr.NewResolver(reqs, idx)
+	stage.Store("resolving")
+	reqs, forbidden := splitForbidden(reqs)
+	mandatory, optional := splitOptional(reqs)
+	resolver, err := pakr.NewResolverE(mandatory, idx)
+	if err != nil {
+		log.Fatalf("Failed to load the Index: %s", err)
+	}
+	if err := applyForbidden(resolver, mandatory, forbidden); err != nil {
+		log.Fatal(err.Error())
+	}
+
+	dropped, err := applyOptional(resolver, optional)
+	if err != nil {
+		log.Fatal(err.Error())
+	}
 
 	buf := bufio.NewWriter(os.Stdout)
-	if err = WriteResults(buf, resolver); err != nil {
+	switch {
+	case *optFormat == "table":
+		err = WriteTableResults(buf, resolver, *optMaxSols, dropped)
+	case *optFormat == "jsonl":
+		err = WriteJSONLResults(buf, resolver, *optMaxSols, *optSchema, dropped, forbidden)
+	case *optFormat != "json":
+		log.Fatalf("Unknown output format %q", *optFormat)
+	case *optMaxSols == 1:
+		err = WriteResults(buf, resolver, *optSchema, dropped, forbidden)
+	default:
+		err = WriteAllResults(buf, resolver, *optMaxSols, *optSchema, dropped, forbidden)
+	}
+	if err != nil {
 		log.Fatal(err.Error())
 	}
 	buf.Flush()
@@ -107,18 +197,248 @@ type Package struct {
 
 func (p Package) Version() string     { return p.Ver }
 func (p Package) ProductName() string { return p.Prod }
-func (p Package) PackageName() string { return fmt.Sprintf("%s-%s", p.Prod, p.Ver) }
+func (p Package) PackageName() string { return pakr.NameFormatter(p.Prod, p.Ver) }
+
+// A Requirement that knows how to serialize to json.
+// Besides the Package object form, it can be parsed from a
+// "<product>-<version>" string, where a version of "*"
+// requires any version of the product.
+// The object form may be marked as optional.
+type Requirement struct {
+	Package
+	Optional bool `json:"optional,omitempty"`
+	// Set for the entries of the "forbid" list
+	Forbidden bool `json:"-"`
+}
+
+func (r *Requirement) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		// Decode the object form without recursing into this method
+		type requirement Requirement
+		return json.Unmarshal(data, (*requirement)(r))
+	}
+	p, err := pakr.ParseRequirement(s)
+	if err != nil {
+		return err
+	}
+	r.Package = Package{Prod: p.ProductName(), Ver: p.Version()}
+	return nil
+}
+
+// IsOptional returns true if the Requirement may be dropped
+func (r Requirement) IsOptional() bool { return r.Optional }
+
+// IsForbidden returns true if the Requirement must not be selected
+func (r Requirement) IsForbidden() bool { return r.Forbidden }
+
+// An OptionalPackager is a requirement that is satisfied if possible,
+// but may be dropped from the resolve when it can not be satisfied
+// together with the mandatory requirements. A custom Parser can
+// produce its own requirements that implement this interface.
+type OptionalPackager interface {
+	pakr.Packager
+	IsOptional() bool
+}
+
+// A ForbiddenPackager is a Package that must not be part of the
+// solution. A custom Parser can produce its own forbidden Packages
+// among the requirements, that implement this interface.
+type ForbiddenPackager interface {
+	pakr.Packager
+	IsForbidden() bool
+}
+
+// splitForbidden separates the forbidden Packages from
+// the requirements, keeping their order
+func splitForbidden(reqs pakr.Packages) (requires, forbidden pakr.Packages) {
+	for _, p := range reqs {
+		if f, ok := p.(ForbiddenPackager); ok && f.IsForbidden() {
+			forbidden = append(forbidden, p)
+		} else {
+			requires = append(requires, p)
+		}
+	}
+	return requires, forbidden
+}
+
+// applyForbidden forbids each of the forbidden Packages in the Resolver.
+// A version of "*" forbids every version of the product. A forbidden
+// Package that is not in the index is ignored, as it can never be
+// selected anyway.
+// Returns a non-nil error if a forbidden Package is also a mandatory
+// requirement, which could never be satisfied, or it can not be
+// forbidden in the Resolver.
+func applyForbidden(resolver *pakr.Resolver, mandatory, forbidden pakr.Packages) error {
+	for _, p := range mandatory {
+		if isForbidden(p, forbidden) {
+			return fmt.Errorf("Package %s is both required and forbidden", p.PackageName())
+		}
+	}
+
+	for _, f := range forbidden {
+		var err error
+		if f.Version() == pakr.AnyVersion {
+			if resolver.TopVersions(f.ProductName(), 1) == nil {
+				continue
+			}
+			err = resolver.ExcludeProduct(f.ProductName())
+		} else {
+			if _, ok := resolver.PackageLiteral(f); !ok {
+				continue
+			}
+			err = resolver.Forbid(f)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// isForbidden returns true if a Package is in the forbid list,
+// either by name or by a "*" version of its product
+func isForbidden(p pakr.Packager, forbidden pakr.Packages) bool {
+	for _, f := range forbidden {
+		if p.PackageName() == f.PackageName() ||
+			(f.Version() == pakr.AnyVersion && p.ProductName() == f.ProductName()) {
+			return true
+		}
+	}
+	return false
+}
+
+// splitOptional separates the mandatory requirements
+// from the optional requirements, keeping their order
+func splitOptional(reqs pakr.Packages) (mandatory, optional pakr.Packages) {
+	for _, p := range reqs {
+		if opt, ok := p.(OptionalPackager); ok && opt.IsOptional() {
+			optional = append(optional, p)
+		} else {
+			mandatory = append(mandatory, p)
+		}
+	}
+	return mandatory, optional
+}
+
+// applyOptional requires as many of the optional requirements as can be
+// satisfied along with the mandatory requirements of the Resolver, as
+// found by MaximalOptionalSubset(). The kept requirements are pushed as
+// an assumption frame, so that they are required by the following
+// resolves. Nothing is dropped when the mandatory requirements can not
+// be satisfied on their own.
+// Returns the optional requirements that were dropped.
+func applyOptional(resolver *pakr.Resolver, optional pakr.Packages) (pakr.Packages, error) {
+	if len(optional) == 0 {
+		return nil, nil
+	}
+	if solved, err := resolver.Resolve(); err != nil || !solved {
+		return nil, err
+	}
+
+	kept, err := resolver.MaximalOptionalSubset(optional)
+	if err != nil {
+		return nil, err
+	}
+	keep := make(map[string]bool, len(kept))
+	for _, p := range kept {
+		keep[p.PackageName()] = true
+	}
+
+	var dropped pakr.Packages
+	for _, p := range optional {
+		if !keep[p.PackageName()] {
+			dropped = append(dropped, p)
+		}
+	}
+	resolver.PushAssumptions(kept)
+	return dropped, nil
+}
 
 // A Requirements type that knows how to serialize to json
 type Requirements struct {
-	Reqs []Package `json:"requires"`
+	Reqs   []Requirement `json:"requires"`
+	Forbid []Requirement `json:"forbid,omitempty"`
+}
+
+// The semantic version of each supported json output schema,
+// selected with the -schema flag by its major version.
+//
+// Version 1 has the "results", "solved" and "error" fields.
+// Version 2 adds the structured "conflicts" of a failed resolve,
+// and since 2.1.0 the optional requirements that were "dropped".
+var schemaVersions = map[int]string{
+	1: "1.0.0",
+	2: "2.1.0",
 }
 
 // A Results type that knows how to serialize to json
 type Results struct {
-	Packages pakr.Packages `json:"results"`
-	Solved   bool          `json:"solved"`
-	Err      string        `json:"error"`
+	Schema    string        `json:"schema"`
+	Packages  pakr.Packages `json:"results"`
+	Solved    bool          `json:"solved"`
+	Err       string        `json:"error"`
+	Conflicts []Conflict    `json:"conflicts,omitempty"`
+	Dropped   []Package     `json:"dropped,omitempty"`
+}
+
+// A Results type, holding multiple alternative solutions,
+// that knows how to serialize to json
+type AllResults struct {
+	Schema    string          `json:"schema"`
+	Solutions []pakr.Packages `json:"results"`
+	Solved    bool            `json:"solved"`
+	Err       string          `json:"error"`
+	Conflicts []Conflict      `json:"conflicts,omitempty"`
+	Dropped   []Package       `json:"dropped,omitempty"`
+}
+
+// A Conflict type that knows how to serialize to json,
+// describing one relation of a failed resolve
+type Conflict struct {
+	Packages []Package `json:"packages"`
+	Relation string    `json:"relation"`
+}
+
+// schemaVersion returns the semantic version of a
+// supported output schema
+func schemaVersion(schema int) (string, error) {
+	version, ok := schemaVersions[schema]
+	if !ok {
+		return "", fmt.Errorf("Unsupported output schema version %d", schema)
+	}
+	return version, nil
+}
+
+// structuredConflicts converts the detailed conflicts
+// of a failed resolve to their json form
+func structuredConflicts(resolver *pakr.Resolver) []Conflict {
+	rels, err := resolver.DetailedConflicts()
+	if err != nil {
+		return nil
+	}
+	conflicts := make([]Conflict, 0, len(rels))
+	for _, rel := range rels {
+		c := Conflict{Relation: string(rel.Relates)}
+		for _, p := range rel.Packages {
+			c.Packages = append(c.Packages, Package{Prod: p.ProductName(), Ver: p.Version()})
+		}
+		conflicts = append(conflicts, c)
+	}
+	return conflicts
+}
+
+// droppedPackages converts the dropped optional
+// requirements to their json form
+func droppedPackages(dropped pakr.Packages) []Package {
+	if len(dropped) == 0 {
+		return nil
+	}
+	pkgs := make([]Package, len(dropped))
+	for i, p := range dropped {
+		pkgs[i] = Package{Prod: p.ProductName(), Ver: p.Version()}
+	}
+	return pkgs
 }
 
 // A Dependency type that knows how to serialize to json
@@ -144,6 +464,15 @@ func ParseReqs(r io.Reader) (reqs pakr.Packages, err error) {
 	// Convert parsed structure into a pakr structure
 	reqs = make(pakr.Packages, 0, len(parsedReqs.Reqs))
 	for _, parsedReq := range parsedReqs.Reqs {
+		if parsedReq.Optional {
+			reqs = append(reqs, parsedReq)
+		} else {
+			reqs = append(reqs, parsedReq.Package)
+		}
+	}
+	for _, parsedReq := range parsedReqs.Forbid {
+		parsedReq.Optional = false
+		parsedReq.Forbidden = true
 		reqs = append(reqs, parsedReq)
 	}
 	return
@@ -159,15 +488,20 @@ func ParseIndex(r io.Reader) (deps []pakr.Dependency, err error) {
 	}
 
 	// Convert the parsed structure into a pakr structure
+	var in pakr.Interner
 	deps = make([]pakr.Dependency, 0, len(parsed.Deps))
 	for _, parsedDep := range parsed.Deps {
 		// Build each dependency
-		dep := pakr.Dependency{parsedDep.Target, make([]pakr.Packages, 0, len(parsedDep.Requires))}
+		dep := pakr.Dependency{
+			Target:   in.Intern(parsedDep.Target.Prod, parsedDep.Target.Ver),
+			Requires: make([]pakr.Packages, 0, len(parsedDep.Requires)),
+		}
 		for _, parsedPaks := range parsedDep.Requires {
-			// Build each Package list
+			// Build each Package list, sharing the Packages that
+			// are referred to many times across the index
 			paks := make(pakr.Packages, 0, len(parsedPaks))
 			for _, parsedPak := range parsedPaks {
-				paks = append(paks, parsedPak)
+				paks = append(paks, in.Intern(parsedPak.Prod, parsedPak.Ver))
 			}
 			dep.Requires = append(dep.Requires, paks)
 		}
@@ -178,37 +512,229 @@ func ParseIndex(r io.Reader) (deps []pakr.Dependency, err error) {
 }
 
 // WriteResults attempts to solve the Resolver and write the
-// results to the io.Writer, in json format
-func WriteResults(w io.Writer, resolver *pakr.Resolver) error {
+// results to the io.Writer, in json format, using the given
+// version of the output schema. The optional requirements that
+// were dropped are reported from schema version 2. The Packages of
+// the forbid list that a failed resolve needs are reported in its error.
+func WriteResults(w io.Writer, resolver *pakr.Resolver, schema int, dropped, forbidden pakr.Packages) error {
+	version, err := schemaVersion(schema)
+	if err != nil {
+		return err
+	}
+
 	solved, err := resolver.Resolve()
 
-	var errStr string
+	res := Results{Schema: version, Packages: nil, Solved: solved}
+
 	if err != nil {
-		fmt.Println("error!!!")
-		errStr = err.Error()
+		res.Err = err.Error()
+
+	} else if solved {
+		res.Packages = resolver.Solution()
+
+	} else {
+		res.Err = conflictReport(resolver, forbidden)
+		if schema >= 2 {
+			res.Conflicts = structuredConflicts(resolver)
+		}
+	}
+	if schema >= 2 {
+		res.Dropped = droppedPackages(dropped)
 	}
 
-	res := Results{Packages: nil, Solved: solved, Err: errStr}
+	enc := json.NewEncoder(w)
+	err = enc.Encode(&res)
+
+	return err
+}
+
+// WriteAllResults attempts to find up to max alternative solutions
+// from the Resolver, and write the results to the io.Writer, in json
+// format, using the given version of the output schema.
+// Each solution is sorted by package name. The optional requirements
+// that were dropped are reported from schema version 2.
+func WriteAllResults(w io.Writer, resolver *pakr.Resolver, max int, schema int, dropped, forbidden pakr.Packages) error {
+	version, err := schemaVersion(schema)
+	if err != nil {
+		return err
+	}
+
+	solved, err := resolver.Resolve()
+	if err != nil {
+		return err
+	}
+
+	res := AllResults{Schema: version, Solutions: nil, Solved: solved}
 
 	if solved {
-		res.Packages = resolver.Solution()
+		if res.Solutions, err = resolver.AllSolutions(max); err != nil {
+			return err
+		}
+		for _, solution := range res.Solutions {
+			sort.Sort(solution)
+		}
 
 	} else {
-		var buf bytes.Buffer
-		fmt.Fprintln(&buf, "The following requirements cannot be satisfied:")
-		for _, c := range resolver.Conflicts() {
-			fmt.Fprintf(&buf, "    %s\n", c.PackageName())
+		res.Err = conflictReport(resolver, forbidden)
+		if schema >= 2 {
+			res.Conflicts = structuredConflicts(resolver)
 		}
+	}
+	if schema >= 2 {
+		res.Dropped = droppedPackages(dropped)
+	}
 
-		fmt.Fprintln(&buf, "\nDetails:")
-		detailed, _ := resolver.DetailedConflicts()
-		fmt.Fprintln(&buf, detailed)
+	enc := json.NewEncoder(w)
+	return enc.Encode(&res)
+}
+
+// The final line of the jsonl output, summarizing the resolve
+type Summary struct {
+	Schema    string     `json:"schema"`
+	Solved    bool       `json:"solved"`
+	Err       string     `json:"error"`
+	Conflicts []Conflict `json:"conflicts,omitempty"`
+	Dropped   []Package  `json:"dropped,omitempty"`
+}
+
+// A package line of the jsonl output. With multiple alternative
+// solutions, each line has the 1-based index of its solution.
+type SolutionPackage struct {
+	Package
+	Solution int `json:"solution,omitempty"`
+}
 
-		res.Err = buf.String()
+// WriteJSONLResults attempts to solve the Resolver and write the results
+// to the io.Writer as json lines: one object per package of the solution,
+// sorted by package name, followed by a Summary object. Each line is an
+// independent json value, using the given version of the output schema.
+// When max is not 1, the packages of up to max alternative solutions are
+// written, each tagged with the index of its solution.
+func WriteJSONLResults(w io.Writer, resolver *pakr.Resolver, max int, schema int, dropped, forbidden pakr.Packages) error {
+	version, err := schemaVersion(schema)
+	if err != nil {
+		return err
 	}
 
+	solved, err := resolver.Resolve()
+
+	sum := Summary{Schema: version, Solved: solved}
 	enc := json.NewEncoder(w)
-	err = enc.Encode(&res)
 
+	if err != nil {
+		sum.Err = err.Error()
+
+	} else if solved {
+		solutions := []pakr.Packages{resolver.Solution()}
+		if max != 1 {
+			if solutions, err = resolver.AllSolutions(max); err != nil {
+				return err
+			}
+		}
+		for i, solution := range solutions {
+			sort.Sort(solution)
+			line := SolutionPackage{}
+			if max != 1 {
+				line.Solution = i + 1
+			}
+			for _, p := range solution {
+				line.Package = Package{Prod: p.ProductName(), Ver: p.Version()}
+				if err = enc.Encode(line); err != nil {
+					return err
+				}
+			}
+		}
+
+	} else {
+		sum.Err = conflictReport(resolver, forbidden)
+		if schema >= 2 {
+			sum.Conflicts = structuredConflicts(resolver)
+		}
+	}
+	if schema >= 2 {
+		sum.Dropped = droppedPackages(dropped)
+	}
+
+	return enc.Encode(&sum)
+}
+
+// WriteTableResults attempts to solve the Resolver and write the
+// results to the io.Writer, as human readable tables, followed
+// by the optional requirements that were dropped. When max is not 1,
+// up to max alternative solutions are written, each under a heading.
+func WriteTableResults(w io.Writer, resolver *pakr.Resolver, max int, dropped pakr.Packages) error {
+	solved, err := resolver.Resolve()
+	if err != nil {
+		return err
+	}
+
+	if solved {
+		solutions := []pakr.Packages{resolver.Solution()}
+		if max != 1 {
+			if solutions, err = resolver.AllSolutions(max); err != nil {
+				return err
+			}
+		}
+		for i, solution := range solutions {
+			sort.Sort(solution)
+			if max != 1 {
+				if i > 0 {
+					fmt.Fprintln(w)
+				}
+				fmt.Fprintf(w, "Solution %d:\n", i+1)
+			}
+			if _, err = io.WriteString(w, solution.Table()); err != nil {
+				return err
+			}
+		}
+		if len(dropped) == 0 {
+			return nil
+		}
+		fmt.Fprintln(w, "\nThe following optional requirements were dropped:")
+		_, err = io.WriteString(w, dropped.Table())
+		return err
+	}
+
+	conflicts := resolver.Conflicts()
+	sort.Sort(conflicts)
+	fmt.Fprintln(w, "The following requirements cannot be satisfied:")
+	fmt.Fprintln(w, conflicts.Table())
+
+	detailed, err := resolver.DetailedConflicts()
+	if err != nil {
+		return err
+	}
+	fmt.Fprintln(w, "Details:")
+	_, err = io.WriteString(w, detailed.Table())
 	return err
 }
+
+// conflictReport builds a descriptive message of the
+// conflicts from a failed resolve, listing the Packages of
+// the forbid list that the requirements need
+func conflictReport(resolver *pakr.Resolver, forbidden pakr.Packages) string {
+	var buf bytes.Buffer
+	fmt.Fprintln(&buf, "The following requirements cannot be satisfied:")
+	for _, c := range resolver.Conflicts() {
+		fmt.Fprintf(&buf, "    %s\n", c.PackageName())
+	}
+
+	detailed, _ := resolver.DetailedConflicts()
+	var needed []string
+	for _, rel := range detailed {
+		if rel.Relates == pakr.Restricts && isForbidden(rel.Packages[0], forbidden) {
+			needed = append(needed, rel.Packages[0].PackageName())
+		}
+	}
+	if len(needed) > 0 {
+		fmt.Fprintln(&buf, "\nThe following forbidden packages are needed:")
+		for _, name := range needed {
+			fmt.Fprintf(&buf, "    %s\n", name)
+		}
+	}
+
+	fmt.Fprintln(&buf, "\nDetails:")
+	fmt.Fprintln(&buf, detailed)
+
+	return buf.String()
+}
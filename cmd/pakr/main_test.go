@@ -0,0 +1,666 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/justinfx/pakr"
+)
+
+// byteStream hands out bytes from fuzz input, returning
+// zero once the input is exhausted
+type byteStream struct {
+	data []byte
+}
+
+func (s *byteStream) next(n int) int {
+	if len(s.data) == 0 {
+		return 0
+	}
+	b := s.data[0]
+	s.data = s.data[1:]
+	return int(b) % n
+}
+
+// indexFromBytes generates a valid Index from arbitrary input,
+// with a few products that each have a few versions, which depend
+// on groups of versions of other products.
+func indexFromBytes(data []byte) Index {
+	s := &byteStream{data}
+
+	numProds := 1 + s.next(4)
+	vers := make([][]Package, numProds)
+	for i := range vers {
+		numVers := 1 + s.next(3)
+		for v := 0; v < numVers; v++ {
+			vers[i] = append(vers[i], Package{Prod: fmt.Sprintf("p%d", i), Ver: fmt.Sprintf("%d.0.0", v)})
+		}
+	}
+
+	var idx Index
+	for _, prod := range vers {
+		for _, target := range prod {
+			dep := Dependency{Target: target}
+			numGroups := s.next(3)
+			for g := 0; g < numGroups; g++ {
+				other := vers[s.next(numProds)]
+				group := []Package{}
+				for _, ver := range other {
+					if s.next(2) == 1 {
+						group = append(group, ver)
+					}
+				}
+				if len(group) == 0 {
+					group = append(group, other[0])
+				}
+				dep.Requires = append(dep.Requires, group)
+			}
+			idx.Deps = append(idx.Deps, dep)
+		}
+	}
+	return idx
+}
+
+func FuzzIndexRoundTrip(f *testing.F) {
+	f.Add([]byte{})
+	f.Add([]byte{3, 2, 1, 2, 1, 0, 1, 1, 2, 0, 1, 1})
+	f.Add([]byte{2, 1, 1, 2, 1, 1, 1, 2, 0, 0, 1})
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		idx := indexFromBytes(data)
+
+		js, err := json.Marshal(&idx)
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+
+		deps, err := ParseIndex(bytes.NewReader(js))
+		if err != nil {
+			t.Fatalf("Failed to parse a valid index: %s\n%s", err.Error(), js)
+		}
+		if len(deps) != len(idx.Deps) {
+			t.Fatalf("Expected %d parsed dependencies, but got %d", len(idx.Deps), len(deps))
+		}
+
+		reqsJs := fmt.Sprintf(`{"requires": [{"product": %q, "version": %q}]}`,
+			idx.Deps[0].Target.Prod, idx.Deps[0].Target.Ver)
+		reqs, err := ParseReqs(strings.NewReader(reqsJs))
+		if err != nil {
+			t.Fatalf("Failed to parse valid requirements: %s", err.Error())
+		}
+
+		resolver, err := pakr.NewResolverE(reqs, deps)
+		if err != nil {
+			t.Fatalf("Failed to create a Resolver for a valid index: %s", err.Error())
+		}
+
+		var buf bytes.Buffer
+		if err = WriteResults(&buf, resolver, 1, nil, nil); err != nil {
+			t.Fatalf("Failed to write results: %s", err.Error())
+		}
+
+		var res struct {
+			Packages []Package `json:"results"`
+			Solved   bool      `json:"solved"`
+			Err      string    `json:"error"`
+		}
+		if err = json.Unmarshal(buf.Bytes(), &res); err != nil {
+			t.Fatalf("Failed to decode results: %s\n%s", err.Error(), buf.String())
+		}
+		if res.Solved && res.Err != "" {
+			t.Fatalf("Expected no error for a solved result, but got %q", res.Err)
+		}
+		if !res.Solved && res.Err == "" {
+			t.Fatal("Expected an error message for an unsolved result")
+		}
+
+		seen := make(map[string]bool)
+		for _, p := range res.Packages {
+			if seen[p.Prod] {
+				t.Fatalf("Multiple versions of product %q in results: %s", p.Prod, buf.String())
+			}
+			seen[p.Prod] = true
+		}
+	})
+}
+
+func FuzzParseIndex(f *testing.F) {
+	f.Add([]byte(`{"depends": [{"package": {"product": "a", "version": "1.0.0"}}]}`))
+	f.Add([]byte(`{"depends": [{"package": {"product": "b", "version": "1.0.0"}, "requires": [[{"product": "a", "version": "1.0.0"}]]}]}`))
+	f.Add([]byte(`{"requires": [{"product": "b", "version": "1.0.0"}]}`))
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		// Arbitrary input may fail to parse, but must never panic
+		ParseIndex(bytes.NewReader(data))
+		ParseReqs(bytes.NewReader(data))
+	})
+}
+
+// An index where every version of a product depends on every
+// version of the products before it, so the same versions are
+// referred to many times across the requires-groups.
+func reusedIndex(numProds, numVers int) []byte {
+	var idx Index
+	for i := 0; i < numProds; i++ {
+		for v := 0; v < numVers; v++ {
+			dep := Dependency{Target: Package{Prod: fmt.Sprintf("p%d", i), Ver: fmt.Sprintf("%d.0.0", v)}}
+			for j := 0; j < i; j++ {
+				var group []Package
+				for w := 0; w < numVers; w++ {
+					group = append(group, Package{Prod: fmt.Sprintf("p%d", j), Ver: fmt.Sprintf("%d.0.0", w)})
+				}
+				dep.Requires = append(dep.Requires, group)
+			}
+			idx.Deps = append(idx.Deps, dep)
+		}
+	}
+	js, _ := json.Marshal(&idx)
+	return js
+}
+
+func BenchmarkParseIndexReuse(b *testing.B) {
+	js := reusedIndex(20, 10)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := ParseIndex(bytes.NewReader(js)); err != nil {
+			b.Fatal(err.Error())
+		}
+	}
+}
+
+func TestParseReqsStrings(t *testing.T) {
+	js := `{"requires": ["a-*", "b-1.0.0", {"product": "c", "version": "2.0.0"}]}`
+
+	reqs, err := ParseReqs(strings.NewReader(js))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	expected := "a-*, b-1.0.0, c-2.0.0"
+	if actual := fmt.Sprint(reqs); actual != expected {
+		t.Errorf("Expected requirements %s, but got %s", expected, actual)
+	}
+
+	if _, err = ParseReqs(strings.NewReader(`{"requires": ["a"]}`)); err == nil {
+		t.Error("Expected an error parsing a requirement without a version")
+	}
+}
+
+func TestWriteResultsSchema(t *testing.T) {
+	idx := `{"depends": [
+		{"package": {"product": "a", "version": "1.0.0"}, "requires": [[{"product": "b", "version": "2.0.0"}]]},
+		{"package": {"product": "b", "version": "1.0.0"}},
+		{"package": {"product": "b", "version": "2.0.0"}}
+	]}`
+	deps, err := ParseIndex(strings.NewReader(idx))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	reqs := pakr.Packages{Package{"a", "1.0.0"}, Package{"b", "1.0.0"}}
+
+	for schema, version := range schemaVersions {
+		var buf bytes.Buffer
+		if err = WriteResults(&buf, pakr.NewResolver(reqs, deps), schema, nil, nil); err != nil {
+			t.Fatal(err.Error())
+		}
+
+		var res map[string]json.RawMessage
+		if err = json.Unmarshal(buf.Bytes(), &res); err != nil {
+			t.Fatal(err.Error())
+		}
+		if string(res["schema"]) != strconv.Quote(version) {
+			t.Errorf("Expected schema %q, but got %s", version, res["schema"])
+		}
+
+		_, hasConflicts := res["conflicts"]
+		if hasConflicts != (schema >= 2) {
+			t.Errorf("Schema %d: unexpected presence of conflicts: %s", schema, buf.String())
+		}
+	}
+
+	if err = WriteResults(io.Discard, pakr.NewResolver(reqs, deps), 99, nil, nil); err == nil {
+		t.Error("Expected an error for an unsupported schema version")
+	}
+	if err = WriteAllResults(io.Discard, pakr.NewResolver(reqs, deps), 0, 99, nil, nil); err == nil {
+		t.Error("Expected an error for an unsupported schema version")
+	}
+}
+
+func TestReadIndexDir(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, js string) {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err.Error())
+		}
+		if err := os.WriteFile(path, []byte(js), 0644); err != nil {
+			t.Fatal(err.Error())
+		}
+	}
+
+	write("a.json", `{"depends": [{"package": {"product": "a", "version": "1.0.0"},
+		"requires": [[{"product": "b", "version": "1.0.0"}]]}]}`)
+	write("b.json", `{"depends": [{"package": {"product": "b", "version": "1.0.0"}}]}`)
+	write("notes.txt", `not an index`)
+	write("sub/c.json", `{"depends": [{"package": {"product": "c", "version": "1.0.0"}}]}`)
+
+	deps, err := ReadIndexDir(jsonParser{}, dir, false)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if len(deps) != 2 {
+		t.Errorf("Expected 2 dependencies without recursion, but got %d", len(deps))
+	}
+
+	deps, err = ReadIndexDir(jsonParser{}, dir, true)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if len(deps) != 3 {
+		t.Errorf("Expected 3 dependencies with recursion, but got %d", len(deps))
+	}
+
+	write("sub/dup.json", `{"depends": [{"package": {"product": "b", "version": "1.0.0"}}]}`)
+	_, err = ReadIndexDir(jsonParser{}, dir, true)
+	if err == nil || !strings.Contains(err.Error(), "dup.json") || !strings.Contains(err.Error(), "b.json") {
+		t.Errorf("Expected an error naming both files that define b-1.0.0, but got %v", err)
+	}
+
+	write("bad.json", `{"depends": [`)
+	_, err = ReadIndexDir(jsonParser{}, dir, false)
+	if err == nil || !strings.Contains(err.Error(), "bad.json") {
+		t.Errorf("Expected an error naming bad.json, but got %v", err)
+	}
+}
+
+func TestCountingReader(t *testing.T) {
+	data := bytes.Repeat([]byte("x"), 1000)
+
+	var progress bytes.Buffer
+	r := NewCountingReader(bytes.NewReader(data), int64(len(data)), &progress, "Reading", 0)
+
+	buf := make([]byte, 100)
+	if _, err := io.ReadFull(r, buf); err != nil {
+		t.Fatal(err.Error())
+	}
+	if r.Count() != 100 {
+		t.Errorf("Expected 100 bytes counted, but got %d", r.Count())
+	}
+	if expected := "Reading: 100 / 1000 bytes (10%)"; r.Progress() != expected {
+		t.Errorf("Expected progress %q, but got %q", expected, r.Progress())
+	}
+
+	if _, err := io.Copy(io.Discard, r); err != nil {
+		t.Fatal(err.Error())
+	}
+	if r.Count() != int64(len(data)) {
+		t.Errorf("Expected %d bytes counted, but got %d", len(data), r.Count())
+	}
+	if !strings.HasSuffix(progress.String(), "\rReading: 1000 / 1000 bytes (100%)\n") {
+		t.Errorf("Expected a final progress line, but got %q", progress.String())
+	}
+
+	// Without a writer, the bytes are only counted
+	r = NewCountingReader(strings.NewReader("abc"), 0, nil, "Reading", 0)
+	io.Copy(io.Discard, r)
+	if expected := "Reading: 3 bytes"; r.Progress() != expected {
+		t.Errorf("Expected progress %q, but got %q", expected, r.Progress())
+	}
+}
+
+func TestOptionalRequirements(t *testing.T) {
+	idx := `{"depends": [
+		{"package": {"product": "a", "version": "1.0.0"}, "requires": [[{"product": "b", "version": "2.0.0"}]]},
+		{"package": {"product": "b", "version": "1.0.0"}},
+		{"package": {"product": "b", "version": "2.0.0"}},
+		{"package": {"product": "c", "version": "1.0.0"}}
+	]}`
+	deps, err := ParseIndex(strings.NewReader(idx))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	js := `{"requires": [
+		"a-1.0.0",
+		{"product": "b", "version": "1.0.0", "optional": true},
+		{"product": "c", "version": "1.0.0", "optional": true}
+	]}`
+	reqs, err := ParseReqs(strings.NewReader(js))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	mandatory, optional := splitOptional(reqs)
+	if fmt.Sprint(mandatory) != "a-1.0.0" || fmt.Sprint(optional) != "b-1.0.0, c-1.0.0" {
+		t.Fatalf("Unexpected mandatory (%s) and optional (%s) requirements", mandatory, optional)
+	}
+
+	resolver := pakr.NewResolver(mandatory, deps)
+	dropped, err := applyOptional(resolver, optional)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if fmt.Sprint(dropped) != "b-1.0.0" {
+		t.Fatalf("Expected b-1.0.0 to be dropped, but got %s", dropped)
+	}
+
+	var buf bytes.Buffer
+	if err = WriteResults(&buf, resolver, 2, dropped, nil); err != nil {
+		t.Fatal(err.Error())
+	}
+	var res struct {
+		Solved  bool      `json:"solved"`
+		Dropped []Package `json:"dropped"`
+	}
+	if err = json.Unmarshal(buf.Bytes(), &res); err != nil {
+		t.Fatal(err.Error())
+	}
+	if !res.Solved {
+		t.Fatalf("Expected the mandatory requirements to be solved: %s", buf.String())
+	}
+	if len(res.Dropped) != 1 || res.Dropped[0] != (Package{"b", "1.0.0"}) {
+		t.Errorf("Expected b-1.0.0 to be reported as dropped: %s", buf.String())
+	}
+	if !strings.Contains(buf.String(), `{"product":"c","version":"1.0.0"}`) {
+		t.Errorf("Expected the kept optional c-1.0.0 in the results: %s", buf.String())
+	}
+
+	// Schema 1 has no dropped field
+	buf.Reset()
+	if err = WriteResults(&buf, resolver, 1, dropped, nil); err != nil {
+		t.Fatal(err.Error())
+	}
+	if strings.Contains(buf.String(), "dropped") {
+		t.Errorf("Expected no dropped field in schema 1: %s", buf.String())
+	}
+
+	// An optional requirement that blocks two later ones is dropped
+	idx = `{"depends": [
+		{"package": {"product": "a", "version": "1.0.0"}},
+		{"package": {"product": "b", "version": "1.0.0"}, "requires": [[{"product": "e", "version": "1.0.0"}]]},
+		{"package": {"product": "c", "version": "1.0.0"}, "requires": [[{"product": "e", "version": "2.0.0"}]]},
+		{"package": {"product": "d", "version": "1.0.0"}, "requires": [[{"product": "e", "version": "2.0.0"}]]},
+		{"package": {"product": "e", "version": "1.0.0"}},
+		{"package": {"product": "e", "version": "2.0.0"}}
+	]}`
+	if deps, err = ParseIndex(strings.NewReader(idx)); err != nil {
+		t.Fatal(err.Error())
+	}
+	js = `{"requires": [
+		"a-1.0.0",
+		{"product": "b", "version": "1.0.0", "optional": true},
+		{"product": "c", "version": "1.0.0", "optional": true},
+		{"product": "d", "version": "1.0.0", "optional": true}
+	]}`
+	if reqs, err = ParseReqs(strings.NewReader(js)); err != nil {
+		t.Fatal(err.Error())
+	}
+	mandatory, optional = splitOptional(reqs)
+	resolver = pakr.NewResolver(mandatory, deps)
+	if dropped, err = applyOptional(resolver, optional); err != nil {
+		t.Fatal(err.Error())
+	}
+	if fmt.Sprint(dropped) != "b-1.0.0" {
+		t.Fatalf("Expected only b-1.0.0 to be dropped, but got %s", dropped)
+	}
+	if solved, _ := resolver.Resolve(); !solved {
+		t.Fatal("Resolver was expected to succeed, but failed.")
+	}
+	solution := resolver.Solution()
+	sort.Sort(solution)
+	if fmt.Sprint(solution) != "a-1.0.0, c-1.0.0, d-1.0.0, e-2.0.0" {
+		t.Errorf("Expected the kept optional requirements in the solution, but got %s", solution)
+	}
+}
+
+func TestForbiddenRequirements(t *testing.T) {
+	idx := `{"depends": [
+		{"package": {"product": "a", "version": "1.0.0"}, "requires": [[{"product": "b", "version": "1.0.0"}]]},
+		{"package": {"product": "b", "version": "1.0.0"}},
+		{"package": {"product": "c", "version": "1.0.0"}}
+	]}`
+	deps, err := ParseIndex(strings.NewReader(idx))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	js := `{"requires": ["a-1.0.0", "c-1.0.0"], "forbid": ["b-1.0.0", "z-*"]}`
+	reqs, err := ParseReqs(strings.NewReader(js))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	reqs, forbidden := splitForbidden(reqs)
+	if fmt.Sprint(reqs) != "a-1.0.0, c-1.0.0" || fmt.Sprint(forbidden) != "b-1.0.0, z-*" {
+		t.Fatalf("Unexpected requirements (%s) and forbidden packages (%s)", reqs, forbidden)
+	}
+
+	// A forbidden package that is needed fails the resolve
+	resolver := pakr.NewResolver(reqs, deps)
+	if err = applyForbidden(resolver, reqs, forbidden); err != nil {
+		t.Fatal(err.Error())
+	}
+	var buf bytes.Buffer
+	if err = WriteResults(&buf, resolver, 2, nil, forbidden); err != nil {
+		t.Fatal(err.Error())
+	}
+	var res Results
+	if err = json.Unmarshal(buf.Bytes(), &res); err != nil {
+		t.Fatal(err.Error())
+	}
+	if res.Solved {
+		t.Fatalf("Expected the resolve to fail: %s", buf.String())
+	}
+	if !strings.Contains(res.Err, "The following forbidden packages are needed:\n    b-1.0.0\n") {
+		t.Errorf("Expected b-1.0.0 to be reported as a needed forbidden package:\n%s", res.Err)
+	}
+
+	// A Package restricted by anything but the forbid list is not reported
+	resolver = pakr.NewResolver(reqs, deps)
+	if err = resolver.Forbid(pakr.NewPackage("b", "1.0.0")); err != nil {
+		t.Fatal(err.Error())
+	}
+	buf.Reset()
+	if err = WriteResults(&buf, resolver, 2, nil, nil); err != nil {
+		t.Fatal(err.Error())
+	}
+	res = Results{}
+	if err = json.Unmarshal(buf.Bytes(), &res); err != nil {
+		t.Fatal(err.Error())
+	}
+	if res.Solved || strings.Contains(res.Err, "forbidden packages") {
+		t.Errorf("Expected no needed forbidden packages to be reported:\n%s", res.Err)
+	}
+
+	// Forbidding a mandatory requirement is a contradiction
+	for _, forbid := range []string{`"c-1.0.0"`, `"c-*"`} {
+		js = `{"requires": ["c-1.0.0"], "forbid": [` + forbid + `]}`
+		if reqs, err = ParseReqs(strings.NewReader(js)); err != nil {
+			t.Fatal(err.Error())
+		}
+		reqs, forbidden = splitForbidden(reqs)
+		resolver = pakr.NewResolver(reqs, deps)
+		err = applyForbidden(resolver, reqs, forbidden)
+		if err == nil {
+			t.Fatalf("Expected an error forbidding %s", forbid)
+		}
+		if err.Error() != "Package c-1.0.0 is both required and forbidden" {
+			t.Errorf("Unexpected error forbidding %s: %s", forbid, err.Error())
+		}
+	}
+}
+
+func TestWriteJSONLResults(t *testing.T) {
+	idx := `{"depends": [
+		{"package": {"product": "a", "version": "1.0.0"}, "requires": [[{"product": "b", "version": "2.0.0"}]]},
+		{"package": {"product": "b", "version": "1.0.0"}},
+		{"package": {"product": "b", "version": "2.0.0"}}
+	]}`
+	deps, err := ParseIndex(strings.NewReader(idx))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	for _, solved := range []bool{true, false} {
+		reqs := pakr.Packages{Package{"a", "1.0.0"}}
+		if !solved {
+			reqs = append(reqs, Package{"b", "1.0.0"})
+		}
+
+		var buf bytes.Buffer
+		if err = WriteJSONLResults(&buf, pakr.NewResolver(reqs, deps), 1, 2, nil, nil); err != nil {
+			t.Fatal(err.Error())
+		}
+
+		lines := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+		var pkgs []Package
+		for _, line := range lines[:len(lines)-1] {
+			var p Package
+			if err = json.Unmarshal([]byte(line), &p); err != nil {
+				t.Fatalf("Invalid json line %q: %s", line, err)
+			}
+			pkgs = append(pkgs, p)
+		}
+
+		var sum Summary
+		if err = json.Unmarshal([]byte(lines[len(lines)-1]), &sum); err != nil {
+			t.Fatalf("Invalid summary line %q: %s", lines[len(lines)-1], err)
+		}
+		if sum.Solved != solved {
+			t.Errorf("Expected the summary to report solved=%v: %s", solved, buf.String())
+		}
+
+		if solved {
+			if fmt.Sprint(pkgs) != "[{a 1.0.0} {b 2.0.0}]" || sum.Err != "" {
+				t.Errorf("Unexpected solution lines:\n%s", buf.String())
+			}
+		} else if len(pkgs) != 0 || sum.Err == "" || len(sum.Conflicts) == 0 {
+			t.Errorf("Expected only a summary with the conflicts:\n%s", buf.String())
+		}
+	}
+
+	// Alternative solutions tag each line with their solution
+	idx = `{"depends": [
+		{"package": {"product": "a", "version": "1.0.0"}, "requires": [[
+			{"product": "b", "version": "1.0.0"}, {"product": "b", "version": "2.0.0"}
+		]]},
+		{"package": {"product": "b", "version": "1.0.0"}},
+		{"package": {"product": "b", "version": "2.0.0"}}
+	]}`
+	if deps, err = ParseIndex(strings.NewReader(idx)); err != nil {
+		t.Fatal(err.Error())
+	}
+	var buf bytes.Buffer
+	err = WriteJSONLResults(&buf, pakr.NewResolver(pakr.Packages{Package{"a", "1.0.0"}}, deps), 0, 2, nil, nil)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	lines := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+	versions := make(map[int]string)
+	for _, line := range lines[:len(lines)-1] {
+		var p SolutionPackage
+		if err = json.Unmarshal([]byte(line), &p); err != nil {
+			t.Fatalf("Invalid json line %q: %s", line, err)
+		}
+		if p.Prod == "b" {
+			versions[p.Solution] = p.Ver
+		}
+	}
+	if len(lines) != 5 || len(versions) != 2 || versions[1] == versions[2] {
+		t.Errorf("Expected the lines of two alternative solutions:\n%s", buf.String())
+	}
+}
+
+func TestWriteTableResults(t *testing.T) {
+	idx := `{"depends": [
+		{"package": {"product": "a", "version": "1.0.0"}, "requires": [[
+			{"product": "b", "version": "1.0.0"}, {"product": "b", "version": "2.0.0"}
+		]]},
+		{"package": {"product": "b", "version": "1.0.0"}},
+		{"package": {"product": "b", "version": "2.0.0"}}
+	]}`
+	deps, err := ParseIndex(strings.NewReader(idx))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	reqs := pakr.Packages{Package{"a", "1.0.0"}}
+
+	var buf bytes.Buffer
+	if err = WriteTableResults(&buf, pakr.NewResolver(reqs, deps), 1, nil); err != nil {
+		t.Fatal(err.Error())
+	}
+	if strings.Contains(buf.String(), "Solution") {
+		t.Errorf("Expected a single solution without a heading:\n%s", buf.String())
+	}
+
+	buf.Reset()
+	if err = WriteTableResults(&buf, pakr.NewResolver(reqs, deps), 0, nil); err != nil {
+		t.Fatal(err.Error())
+	}
+	if !strings.Contains(buf.String(), "Solution 1:\n") || !strings.Contains(buf.String(), "\nSolution 2:\n") {
+		t.Errorf("Expected both alternative solutions:\n%s", buf.String())
+	}
+	if strings.Count(buf.String(), "b") != 2 || !strings.Contains(buf.String(), "1.0.0") ||
+		!strings.Contains(buf.String(), "2.0.0") {
+		t.Errorf("Expected both versions of b:\n%s", buf.String())
+	}
+}
+
+// stubParser is a Parser that returns fixed results
+type stubParser struct{}
+
+func (stubParser) ParseReqs(r io.Reader) (pakr.Packages, error) {
+	return pakr.Packages{Package{"stub", "1.0.0"}}, nil
+}
+
+func (stubParser) ParseIndex(r io.Reader) ([]pakr.Dependency, error) {
+	return []pakr.Dependency{{Target: Package{"stub", "1.0.0"}}}, nil
+}
+
+func TestParserRegistry(t *testing.T) {
+	if _, err := GetParser("json"); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	RegisterParser("stub", stubParser{})
+	defer func() {
+		parsersMu.Lock()
+		delete(parsers, "stub")
+		parsersMu.Unlock()
+	}()
+
+	parser, err := GetParser("stub")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	reqs, err := parser.ParseReqs(strings.NewReader(""))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if fmt.Sprint(reqs) != "stub-1.0.0" {
+		t.Errorf("Expected the requirements of the registered parser, but got %s", reqs)
+	}
+
+	_, err = GetParser("missing")
+	if err == nil {
+		t.Fatal("Expected an error for an unknown parser")
+	}
+	if err.Error() != `Unknown parser "missing" (available: json, stub)` {
+		t.Errorf("Unexpected error for an unknown parser: %s", err.Error())
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Error("Expected registering a nil parser to panic")
+		}
+	}()
+	RegisterParser("nil", nil)
+}
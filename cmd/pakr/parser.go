@@ -0,0 +1,88 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/justinfx/pakr"
+)
+
+// A ReqParser reads a requirements document, and produces
+// the list of Packages to be resolved
+type ReqParser interface {
+	ParseReqs(r io.Reader) (pakr.Packages, error)
+}
+
+// An IndexParser reads an index document, and produces
+// the list of available dependencies
+type IndexParser interface {
+	ParseIndex(r io.Reader) ([]pakr.Dependency, error)
+}
+
+// A Parser can read both requirements and index documents.
+// Custom Parsers can produce their own pakr.Packager types.
+type Parser interface {
+	ReqParser
+	IndexParser
+}
+
+var (
+	parsersMu sync.RWMutex
+	parsers   = map[string]Parser{
+		"json": jsonParser{},
+	}
+)
+
+// RegisterParser makes a Parser available by name, for selection
+// with the -parser flag. As this package can not be imported, a build
+// of the command with a custom pakr.Packager implementation adds a
+// source file to this package, which registers its Parser from an
+// init() function.
+// Registering the same name twice replaces the previous Parser.
+func RegisterParser(name string, p Parser) {
+	if p == nil {
+		panic("pakr: RegisterParser parser is nil")
+	}
+	parsersMu.Lock()
+	parsers[name] = p
+	parsersMu.Unlock()
+}
+
+// GetParser returns a registered Parser by name.
+// Returns a non-nil error if no Parser is registered with the name.
+func GetParser(name string) (Parser, error) {
+	parsersMu.RLock()
+	defer parsersMu.RUnlock()
+
+	p, ok := parsers[name]
+	if !ok {
+		return nil, fmt.Errorf("Unknown parser %q (available: %s)", name, strings.Join(parserNames(), ", "))
+	}
+	return p, nil
+}
+
+// parserNames returns the sorted names of all registered Parsers.
+// Expects the caller to hold the lock.
+func parserNames() []string {
+	names := make([]string, 0, len(parsers))
+	for name := range parsers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// jsonParser is the default Parser, reading the JSON
+// formats described by the Requirements and Index types
+type jsonParser struct{}
+
+func (jsonParser) ParseReqs(r io.Reader) (pakr.Packages, error) {
+	return ParseReqs(r)
+}
+
+func (jsonParser) ParseIndex(r io.Reader) ([]pakr.Dependency, error) {
+	return ParseIndex(r)
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// A CountingReader counts the bytes read from an io.Reader, and
+// periodically reports the progress against an expected total
+// to an io.Writer, such as stderr
+type CountingReader struct {
+	r     io.Reader
+	total int64
+	count atomic.Int64
+
+	// Where the progress is reported. If nil, the bytes
+	// are only counted.
+	w     io.Writer
+	label string
+	every time.Duration
+
+	mu   sync.Mutex
+	last time.Time
+	done bool
+}
+
+// NewCountingReader wraps an io.Reader, expected to hold total bytes.
+// A non-nil w receives a progress line, at most once per interval,
+// and once more when the total is reached or the reader is exhausted.
+func NewCountingReader(r io.Reader, total int64, w io.Writer, label string, every time.Duration) *CountingReader {
+	return &CountingReader{r: r, total: total, w: w, label: label, every: every}
+}
+
+func (c *CountingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	count := c.count.Add(int64(n))
+
+	if c.w != nil {
+		c.mu.Lock()
+		if (err == io.EOF || count == c.total) && !c.done {
+			c.done = true
+			fmt.Fprintf(c.w, "\r%s\n", c.Progress())
+		} else if !c.done && time.Since(c.last) >= c.every {
+			c.last = time.Now()
+			fmt.Fprintf(c.w, "\r%s", c.Progress())
+		}
+		c.mu.Unlock()
+	}
+	return n, err
+}
+
+// Count returns the number of bytes read so far
+func (c *CountingReader) Count() int64 {
+	return c.count.Load()
+}
+
+// Progress returns a description of the bytes read so far,
+// out of the total, and the percentage when the total is known
+func (c *CountingReader) Progress() string {
+	count := c.Count()
+	if c.total <= 0 {
+		return fmt.Sprintf("%s: %d bytes", c.label, count)
+	}
+	return fmt.Sprintf("%s: %d / %d bytes (%d%%)", c.label, count, c.total, count*100/c.total)
+}
@@ -0,0 +1,87 @@
+package pakr
+
+import "sort"
+
+// A VersionChange describes a Product that was chosen
+// at a different version in each of two solutions
+type VersionChange struct {
+	Product string
+	A       Packager
+	B       Packager
+}
+
+// A SolutionComparison describes the differences between two
+// solutions, by Product. All lists are sorted by Product name.
+type SolutionComparison struct {
+	// Products chosen at a different version by each solution
+	Changed []VersionChange
+	// Packages chosen at the same version by both solutions
+	Same Packages
+	// Packages whose Product is only in the first solution
+	OnlyA Packages
+	// Packages whose Product is only in the second solution
+	OnlyB Packages
+}
+
+// Returns true if both solutions chose the same Packages
+func (c SolutionComparison) Equal() bool {
+	return len(c.Changed) == 0 && len(c.OnlyA) == 0 && len(c.OnlyB) == 0
+}
+
+// String renders the comparison as a table, with the version chosen
+// by each solution side by side, for every Product that differs.
+// A Product that is missing from one of the solutions shows "-".
+func (c SolutionComparison) String() string {
+	rows := make([][]string, 0, len(c.Changed)+len(c.OnlyA)+len(c.OnlyB))
+	for _, ch := range c.Changed {
+		rows = append(rows, []string{ch.Product, ch.A.Version(), ch.B.Version()})
+	}
+	for _, p := range c.OnlyA {
+		rows = append(rows, []string{p.ProductName(), p.Version(), "-"})
+	}
+	for _, p := range c.OnlyB {
+		rows = append(rows, []string{p.ProductName(), "-", p.Version()})
+	}
+	sort.SliceStable(rows, func(i, j int) bool { return rows[i][0] < rows[j][0] })
+	return formatTable([]string{"PRODUCT", "A", "B"}, rows)
+}
+
+// CompareSolutions compares two solutions by Product, such as the
+// solutions of the same requirements resolved with different sort
+// modes. Unlike Packages.Diff(), which only reports added and removed
+// Packages, the comparison pairs up the versions that each solution
+// chose for the same Product.
+func CompareSolutions(a, b Packages) SolutionComparison {
+	inB := make(map[string]Packager, len(b))
+	for _, p := range b {
+		inB[p.ProductName()] = p
+	}
+
+	var c SolutionComparison
+	inA := make(map[string]bool, len(a))
+	for _, p := range a {
+		prod := p.ProductName()
+		inA[prod] = true
+
+		other, ok := inB[prod]
+		switch {
+		case !ok:
+			c.OnlyA = append(c.OnlyA, p)
+		case other.Version() == p.Version():
+			c.Same = append(c.Same, p)
+		default:
+			c.Changed = append(c.Changed, VersionChange{prod, p, other})
+		}
+	}
+	for _, p := range b {
+		if !inA[p.ProductName()] {
+			c.OnlyB = append(c.OnlyB, p)
+		}
+	}
+
+	sort.Slice(c.Changed, func(i, j int) bool { return c.Changed[i].Product < c.Changed[j].Product })
+	sort.Sort(c.Same)
+	sort.Sort(c.OnlyA)
+	sort.Sort(c.OnlyB)
+	return c
+}
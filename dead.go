@@ -0,0 +1,84 @@
+package pakr
+
+import (
+	"sort"
+	"strings"
+)
+
+// A DeadPackage is a Package in a package index that can never be
+// part of any solution, because its own dependencies can not be solved
+type DeadPackage struct {
+	Package Packager
+	// The conflicts reported by DetailedConflicts(),
+	// when the Package is required on its own
+	Conflicts PackageRelations
+}
+
+func (d DeadPackage) String() string {
+	reasons := make([]string, len(d.Conflicts))
+	for i, rel := range d.Conflicts {
+		reasons[i] = rel.String()
+	}
+	return d.Package.PackageName() + ": " + strings.Join(reasons, "; ")
+}
+
+// DeadPackages returns every Package that is defined in the package
+// index, but can never be part of any solution, because requiring it
+// on its own can not be solved. This catches Packages whose dependencies
+// reference versions that conflict with each other. The Packages are
+// ordered by product name and version. Use ExplainDeadPackages() for
+// the reason each Package is dead.
+// Returns a non-nil error if the index is invalid, such as two different
+// Packages sharing the same PackageName.
+func DeadPackages(index []Dependency) (Packages, error) {
+	dead, err := ExplainDeadPackages(index)
+	if err != nil {
+		return nil, err
+	}
+	pkgs := make(Packages, len(dead))
+	for i, d := range dead {
+		pkgs[i] = d.Package
+	}
+	return pkgs, nil
+}
+
+// ExplainDeadPackages finds the same Packages as DeadPackages(), along
+// with the conflicts that prevent each one from being solved.
+// Every defined Package is solved in turn as the only requirement of a
+// temporary Resolver, so this is expensive for large indexes.
+// Returns a non-nil error if the index is invalid, such as two different
+// Packages sharing the same PackageName.
+func ExplainDeadPackages(index []Dependency) ([]DeadPackage, error) {
+	r := &Resolver{index: index}
+	if err := r.Initialize(); err != nil {
+		return nil, err
+	}
+
+	seen := make(map[string]bool, len(index))
+	targets := make(Packages, 0, len(index))
+	for _, dep := range index {
+		if !seen[packageKey(dep.Target)] {
+			seen[packageKey(dep.Target)] = true
+			targets = append(targets, dep.Target)
+		}
+	}
+	sort.SliceStable(targets, func(i, j int) bool { return comparePackages(targets[i], targets[j]) < 0 })
+
+	dead := []DeadPackage{}
+	for _, p := range targets {
+		r.RequireTemp(p)
+		ok, err := r.Resolve()
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			continue
+		}
+		rels, err := r.DetailedConflicts()
+		if err != nil {
+			return nil, err
+		}
+		dead = append(dead, DeadPackage{p, rels})
+	}
+	return dead, nil
+}
@@ -0,0 +1,141 @@
+package pakr
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"sort"
+
+	"github.com/justinfx/pigosat"
+)
+
+// The clauses of a single Package, as described by Describe()
+type packageDescription struct {
+	required   bool
+	restricted bool
+	depends    []Packages
+	conflicts  map[string]Packager
+}
+
+// Describe writes a readable description of the formula that the Resolver
+// builds from its package index and permanent constraints, to verify that
+// the index was interpreted as intended. For every Package, ordered by
+// product name and version, it lists the requires-groups it depends on,
+// the Packages it conflicts with, including the other versions of its
+// Product and externally supplied conflicts, and whether it is pinned or
+// not allowed. Clauses of the cardinality encodings, such as those of
+// AddExactlyN(), are not described.
+// Returns a non-nil error if the formula can not be built,
+// or writing to w fails.
+func (r *Resolver) Describe(w io.Writer) error {
+	tmp := r.tempConfig(r.requires)
+	tmp.idMap = newStringIdMap()
+	tmp.prodMap = NewProductMap()
+	clauses, err := tmp.buildFormula()
+	if err != nil {
+		return err
+	}
+
+	descs := make(map[string]*packageDescription)
+	var pkgs Packages
+	for id := 1; id <= int(tmp.idMap.i); id++ {
+		name := tmp.idMap.IdToString(pigosat.Literal(id))
+		if name == "" {
+			continue
+		}
+		p, err := tmp.prodMap.PackageByName(name)
+		if err != nil {
+			return err
+		}
+		pkgs = append(pkgs, p)
+		descs[name] = &packageDescription{conflicts: make(map[string]Packager)}
+	}
+	sort.SliceStable(pkgs, func(i, j int) bool { return comparePackages(pkgs[i], pkgs[j]) < 0 })
+
+	// The versions in the compact conflict encoding of each Product
+	versions := make(map[string]map[string]Packager)
+
+clauses:
+	for _, clause := range clauses {
+		var pos, neg Packages
+		for _, lit := range clause {
+			id := lit
+			if id < 0 {
+				id = -id
+			}
+			name := tmp.idMap.IdToString(id)
+			if name == "" {
+				// An auxiliary literal of an encoding
+				if product, ok := tmp.amo[id]; ok {
+					if versions[product] == nil {
+						versions[product] = make(map[string]Packager)
+					}
+					for _, other := range clause {
+						if p, err := tmp.prodMap.PackageByName(tmp.idMap.IdToString(other)); err == nil {
+							versions[product][p.PackageName()] = p
+						}
+					}
+				}
+				continue clauses
+			}
+			p, _ := tmp.prodMap.PackageByName(name)
+			if lit < 0 {
+				neg = append(neg, p)
+			} else {
+				pos = append(pos, p)
+			}
+		}
+
+		switch {
+		case len(pos) == 1 && len(neg) == 0:
+			descs[pos[0].PackageName()].required = true
+		case len(neg) == 1 && len(pos) == 0:
+			descs[neg[0].PackageName()].restricted = true
+		case len(neg) == 1:
+			desc := descs[neg[0].PackageName()]
+			desc.depends = append(desc.depends, pos)
+		case len(pos) == 0:
+			for _, a := range neg {
+				for _, b := range neg {
+					if a.PackageName() != b.PackageName() {
+						descs[a.PackageName()].conflicts[b.PackageName()] = b
+					}
+				}
+			}
+		}
+	}
+
+	for _, vers := range versions {
+		for _, a := range vers {
+			for _, b := range vers {
+				if a.PackageName() != b.PackageName() {
+					descs[a.PackageName()].conflicts[b.PackageName()] = b
+				}
+			}
+		}
+	}
+
+	buf := bufio.NewWriter(w)
+	for _, p := range pkgs {
+		desc := descs[p.PackageName()]
+		fmt.Fprintln(buf, p.PackageName())
+		if desc.required {
+			fmt.Fprintln(buf, "    is required")
+		}
+		if desc.restricted {
+			fmt.Fprintln(buf, "    is not allowed")
+		}
+		for _, vers := range desc.depends {
+			fmt.Fprintf(buf, "    depends on one of (%s)\n", vers)
+		}
+		if len(desc.conflicts) > 0 {
+			others := make(Packages, 0, len(desc.conflicts))
+			for _, other := range desc.conflicts {
+				others = append(others, other)
+			}
+			sort.Slice(others, func(i, j int) bool { return comparePackages(others[i], others[j]) < 0 })
+			fmt.Fprintf(buf, "    conflicts with (%s)\n", others)
+		}
+	}
+	return buf.Flush()
+}
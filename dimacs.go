@@ -0,0 +1,117 @@
+package pakr
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"strconv"
+
+	"github.com/justinfx/pigosat"
+)
+
+// WriteDIMACS writes the formula of the Resolver to w in DIMACS CNF format.
+// Each literal id of a Package is described by a "c <id> <name>" comment
+// line before the preamble. Auxiliary literals have no comment.
+// The permanent constraints are part of the formula, as with Freeze(),
+// but the requirements are not.
+// The formula can be read again with NewResolverFromDIMACS().
+func (r *Resolver) WriteDIMACS(w io.Writer) error {
+	tmp := r.tempConfig(r.requires)
+	tmp.idMap = newStringIdMap()
+	tmp.prodMap = NewProductMap()
+
+	clauses, err := tmp.buildFormula()
+	if err != nil {
+		return err
+	}
+
+	// Include the auxiliary literals of the encodings, which
+	// are not counted by the names of the Packages
+	buf := bufio.NewWriter(w)
+	vars := int(tmp.idMap.i)
+	for id := 1; id <= vars; id++ {
+		if name := tmp.idMap.IdToString(pigosat.Literal(id)); name != "" {
+			fmt.Fprintf(buf, "c %d %s\n", id, name)
+		}
+	}
+	fmt.Fprintf(buf, "p cnf %d %d\n", vars, len(clauses))
+	for _, clause := range clauses {
+		for _, lit := range clause {
+			buf.WriteString(strconv.Itoa(int(lit)))
+			buf.WriteByte(' ')
+		}
+		buf.WriteString("0\n")
+	}
+	return buf.Flush()
+}
+
+// NewResolverFromDIMACS reads a formula in DIMACS CNF format, and returns
+// a new Resolver that solves it in place of a package index. This allows
+// testing and reusing the solver layer with a prebuilt formula, such as
+// one written by WriteDIMACS(), or a SAT problem that is not about packages.
+//
+// The names map the literal ids to Package names. When names is nil,
+// they are read from the "c <id> <name>" comment lines written by
+// WriteDIMACS(). Literal ids without a name are auxiliary literals, that
+// are never part of the Solution(). A Package of type *Package is made for
+// each name, split into its product and version at the last "-". A name
+// without a version is used as the product, with an empty version.
+//
+// The Resolver has no requirements, which can be set with SetRequirements()
+// using Packages with the same names.
+// Returns a non-nil error if the formula can not be parsed.
+func NewResolverFromDIMACS(r io.Reader, names map[int]string) (*Resolver, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	if names == nil {
+		names = make(map[int]string)
+		scanner := bufio.NewScanner(bytes.NewReader(data))
+		for scanner.Scan() {
+			var (
+				id   int
+				name string
+			)
+			if n, _ := fmt.Sscanf(scanner.Text(), "c %d %s", &id, &name); n == 2 {
+				names[id] = name
+			}
+		}
+	}
+
+	clauses, err := readClauses(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+
+	f := &frozenFormula{
+		packages: make(map[pigosat.Literal]*Package, len(names)),
+		clauses:  clauses,
+	}
+	for id, name := range names {
+		if id <= 0 {
+			return nil, fmt.Errorf("DIMACS name %q has an invalid id %d", name, id)
+		}
+		product, version, ok := splitPackageName(name)
+		if !ok {
+			product, version = name, ""
+		}
+		p := NewPackage(product, version)
+		p.packageName = name
+		f.packages[pigosat.Literal(id)] = p
+		f.vars = max(f.vars, id)
+	}
+	for _, clause := range clauses {
+		for _, lit := range clause {
+			f.vars = max(f.vars, int(lit), -int(lit))
+		}
+	}
+
+	res := &Resolver{frozen: f}
+	if err := res.Initialize(); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
@@ -6,5 +6,7 @@ and a list of requirements/selections from that index, and resolve a package sol
 - A "product" is a unique name that will have 1 or more package versions.
 
 - A "package" is a specific version of a product, with specific dependencies.
+
+pakr requires Go 1.23 or newer, for the iter.Seq of an IndexSource.
 */
 package pakr
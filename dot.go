@@ -0,0 +1,55 @@
+package pakr
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// The edge colors of the requires-groups of a target, in order
+var dotGroupColors = []string{
+	"black", "blue", "red", "darkgreen", "orange", "purple", "brown", "cyan4",
+}
+
+// WriteIndexDOT writes the whole package index to w as a graph in the
+// Graphviz DOT language, independent of any solution. There is a node for
+// every Package, and an edge from each target to every member of each of
+// its requires-groups. The edges of the same requires-group share a color
+// and a label with the number of the group. The graph is written as the
+// index is walked, so only the names of the Packages already written are
+// kept in memory.
+// Returns a non-nil error if writing to w fails.
+func WriteIndexDOT(w io.Writer, index []Dependency) error {
+	buf := bufio.NewWriter(w)
+	buf.WriteString("digraph index {\n")
+
+	seen := make(map[string]bool)
+	node := func(p Packager) {
+		if name := p.PackageName(); !seen[name] {
+			seen[name] = true
+			fmt.Fprintf(buf, "\t%s;\n", dotQuote(name))
+		}
+	}
+
+	for _, dep := range index {
+		node(dep.Target)
+		target := dotQuote(dep.Target.PackageName())
+		for i, vers := range dep.Requires {
+			color := dotGroupColors[i%len(dotGroupColors)]
+			for _, ver := range vers {
+				node(ver)
+				fmt.Fprintf(buf, "\t%s -> %s [color=%s, fontcolor=%s, label=\"%d\"];\n",
+					target, dotQuote(ver.PackageName()), color, color, i+1)
+			}
+		}
+	}
+
+	buf.WriteString("}\n")
+	return buf.Flush()
+}
+
+// dotQuote returns a string as a quoted DOT identifier
+func dotQuote(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s) + `"`
+}
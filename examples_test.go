@@ -35,15 +35,7 @@ func Example_solve() {
 	// in the Dependency index
 	resolver := NewResolver(required, index)
 
-	solved, err := resolver.Resolve()
-	if err != nil {
-		panic(err.Error())
-	}
-	if !solved {
-		panic("Resolver was expected to succeed, but failed.")
-	}
-
-	solutions := resolver.Solution()
+	solutions := resolver.MustResolve()
 	sort.Sort(solutions)
 
 	for _, pkg := range solutions {
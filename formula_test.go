@@ -0,0 +1,117 @@
+package pakr
+
+import (
+	"bytes"
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+var updateGolden = flag.Bool("update", false, "Update the golden formula files in testdata")
+
+// dumpFormula writes the formula generated by the Resolver for its
+// package index with WriteDIMACS()
+func dumpFormula(r *Resolver) (string, error) {
+	var buf bytes.Buffer
+	if err := r.WriteDIMACS(&buf); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+func TestFormulaGolden(t *testing.T) {
+	P := NewPackage
+
+	cases := []struct {
+		name     string
+		sortMode resolveSort
+		index    []Dependency
+	}{
+		{
+			"success", ResolveSortNone, []Dependency{
+				{
+					P("A", "1.0.0"), []Packages{
+						{P("B", "1.2.3"), P("B", "1.2.5"), P("B", "1.2.9")},
+						{P("C", "2.0.0"), P("C", "2.1.0"), P("C", "2.2.0")},
+					},
+				},
+				{
+					P("C", "2.1.0"), []Packages{
+						{P("D", "5.0.0"), P("D", "5.0.1")},
+						{P("E", "2.0.0"), P("E", "3.0.0")},
+					},
+				},
+				{
+					P("D", "5.0.1"), []Packages{
+						{P("B", "1.2.3"), P("B", "1.2.9")},
+						{P("E", "3.0.0")},
+					},
+				},
+				{
+					P("F", "0.5.5"), []Packages{
+						{P("C", "2.1.0")},
+						{P("X", "1.5.0")},
+						{P("Y", "2.0.0")},
+					},
+				},
+				{P("Z", "1.0.0"), nil},
+			},
+		},
+		{
+			"high", ResolveSortHigh, []Dependency{
+				{P("A", "2.0.0"), []Packages{{P("B", "2.0.0"), P("B", "1.0.0")}}},
+				{P("A", "1.0.0"), []Packages{{P("B", "2.0.0"), P("B", "1.0.0")}}},
+				{P("B", "2.0.0"), []Packages{{P("C", "2.0.0"), P("C", "1.0.0")}}},
+				{P("B", "1.0.0"), []Packages{{P("C", "2.0.0"), P("C", "1.0.0")}}},
+				{P("C", "2.0.0"), []Packages{}},
+				{P("C", "1.0.0"), []Packages{}},
+			},
+		},
+		{
+			"low", ResolveSortLow, []Dependency{
+				{P("A", "1.0.0"), []Packages{{P("B", "1.0.0"), P("B", "2.0.0")}}},
+				{P("A", "2.0.0"), []Packages{{P("B", "1.0.0"), P("B", "2.0.0")}}},
+				{P("B", "1.0.0"), []Packages{{P("C", "1.0.0"), P("C", "2.0.0")}}},
+				{P("B", "2.0.0"), []Packages{{P("C", "1.0.0"), P("C", "2.0.0")}}},
+				{P("C", "1.0.0"), []Packages{}},
+				{P("C", "2.0.0"), []Packages{}},
+			},
+		},
+	}
+
+	for _, c := range cases {
+		resolver := NewSortResolver(nil, c.index, c.sortMode)
+
+		actual, err := dumpFormula(resolver)
+		if err != nil {
+			t.Fatalf("%s: %s", c.name, err.Error())
+		}
+
+		// Generating the formula again must give the same result
+		again, err := dumpFormula(NewSortResolver(nil, c.index, c.sortMode))
+		if err != nil {
+			t.Fatalf("%s: %s", c.name, err.Error())
+		}
+		if actual != again {
+			t.Errorf("%s: Formula was not generated deterministically", c.name)
+		}
+
+		golden := filepath.Join("testdata", c.name+".cnf")
+		if *updateGolden {
+			if err = os.WriteFile(golden, []byte(actual), 0644); err != nil {
+				t.Fatal(err.Error())
+			}
+			continue
+		}
+
+		expected, err := os.ReadFile(golden)
+		if err != nil {
+			t.Fatalf("%s: Failed to read golden file: %s", c.name, err.Error())
+		}
+		if actual != string(expected) {
+			t.Errorf("%s: Formula does not match %s (run with -update to regenerate):\n%s",
+				c.name, golden, actual)
+		}
+	}
+}
@@ -0,0 +1,247 @@
+package pakr
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/justinfx/pigosat"
+)
+
+const (
+	// The first line of a frozen Resolver, followed by its format version
+	frozenHeader = "pakr-frozen"
+	// The current version of the frozen format. Version 2 adds the
+	// Products of the compact conflict encodings.
+	frozenVersion = 2
+)
+
+// A frozenFormula is the compiled formula of a Resolver,
+// loaded by LoadFrozen() in place of a package index
+type frozenFormula struct {
+	vars     int
+	packages map[pigosat.Literal]*Package
+	clauses  pigosat.Formula
+	// The Product of each auxiliary literal of a compact conflict encoding
+	amo map[pigosat.Literal]string
+}
+
+// load maps the frozen Packages to their original literal ids,
+// and returns the frozen clauses
+func (f *frozenFormula) load(idMap *stringIdMap, prodMap *ProductMap) (pigosat.Formula, error) {
+	for id := 1; id <= f.vars; id++ {
+		p, ok := f.packages[pigosat.Literal(id)]
+		if !ok {
+			idMap.NewAux()
+			continue
+		}
+		idMap.StringToId(packageKey(p))
+		if err := prodMap.add(p); err != nil {
+			return nil, err
+		}
+	}
+	return append(pigosat.Formula(nil), f.clauses...), nil
+}
+
+// Freeze writes the compiled formula of the Resolver to w, so that it
+// can be loaded again with LoadFrozen() without the cost of building it
+// from the package index. The frozen format holds the literal id of every
+// Package, with its product and version, the requirements, and the SAT
+// clauses in DIMACS form, following a format version header.
+//
+// The permanent constraints, such as minimum versions, pinned and forbidden
+// Packages, are compiled into the clauses. Preferences and the temporary
+// requirements are not frozen.
+// Returns a non-nil error if a requirement was added with RequireOneOf().
+func (r *Resolver) Freeze(w io.Writer) error {
+	for _, p := range r.requires {
+		if _, ok := p.(*oneOfRequirement); ok {
+			return fmt.Errorf("The requirement %s can not be frozen", p.PackageName())
+		}
+	}
+
+	tmp := r.tempConfig(r.requires)
+	tmp.idMap = newStringIdMap()
+	tmp.prodMap = NewProductMap()
+	clauses, err := tmp.buildFormula()
+	if err != nil {
+		return err
+	}
+
+	buf := bufio.NewWriter(w)
+	fmt.Fprintf(buf, "%s %d\n", frozenHeader, frozenVersion)
+
+	vars := int(tmp.idMap.i)
+	for id := 1; id <= vars; id++ {
+		name := tmp.idMap.IdToString(pigosat.Literal(id))
+		if name == "" {
+			continue
+		}
+		p, err := tmp.prodMap.PackageByName(name)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(buf, "v %d %q %q %q\n", id, name, p.ProductName(), p.Version())
+	}
+	for id := 1; id <= vars; id++ {
+		if product, ok := tmp.amo[pigosat.Literal(id)]; ok {
+			fmt.Fprintf(buf, "a %d %q\n", id, product)
+		}
+	}
+	for _, p := range r.requires {
+		fmt.Fprintf(buf, "r %q %q %q\n", p.PackageName(), p.ProductName(), p.Version())
+	}
+
+	fmt.Fprintf(buf, "p cnf %d %d\n", vars, len(clauses))
+	for _, clause := range clauses {
+		for _, lit := range clause {
+			buf.WriteString(strconv.Itoa(int(lit)))
+			buf.WriteByte(' ')
+		}
+		buf.WriteString("0\n")
+	}
+	return buf.Flush()
+}
+
+// LoadFrozen reads a Resolver that was written with Resolver.Freeze(),
+// and returns a new Resolver that is ready to solve. It produces the
+// same solutions as the frozen Resolver.
+//
+// The loaded Resolver has no package index. Its Packages are all of
+// type *Package, with the same names as the original Packages.
+// Setting a new index with SetPackageIndex() replaces the frozen formula.
+// Returns a non-nil error if the data is not a frozen Resolver, or
+// was written by an unsupported version of the format.
+func LoadFrozen(r io.Reader) (*Resolver, error) {
+	var (
+		format   int
+		requires Packages
+	)
+	f := &frozenFormula{packages: make(map[pigosat.Literal]*Package)}
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, 64*1024*1024)
+
+	if !scanner.Scan() {
+		if err := scanner.Err(); err != nil {
+			return nil, err
+		}
+		return nil, errors.New("Frozen Resolver is empty")
+	}
+	if _, err := fmt.Sscanf(scanner.Text(), frozenHeader+" %d", &format); err != nil {
+		return nil, fmt.Errorf("Frozen Resolver has an invalid header %q", scanner.Text())
+	}
+	if format < 1 || format > frozenVersion {
+		return nil, fmt.Errorf("Frozen Resolver has unsupported format version %d", format)
+	}
+
+	lineNum := 1
+	numClauses := -1
+	for scanner.Scan() {
+		lineNum++
+		line := scanner.Text()
+		if len(line) == 0 {
+			continue
+		}
+
+		var (
+			id                     int
+			name, product, version string
+			err                    error
+		)
+		switch line[0] {
+
+		case 'v':
+			_, err = fmt.Sscanf(line, "v %d %q %q %q", &id, &name, &product, &version)
+			if err == nil && id <= 0 {
+				err = fmt.Errorf("invalid id %d", id)
+			}
+			if err == nil {
+				p := NewPackage(product, version)
+				p.packageName = name
+				f.packages[pigosat.Literal(id)] = p
+			}
+
+		case 'a':
+			_, err = fmt.Sscanf(line, "a %d %q", &id, &product)
+			if err == nil && id <= 0 {
+				err = fmt.Errorf("invalid id %d", id)
+			}
+			if err == nil {
+				if f.amo == nil {
+					f.amo = make(map[pigosat.Literal]string)
+				}
+				f.amo[pigosat.Literal(id)] = product
+			}
+
+		case 'r':
+			if _, err = fmt.Sscanf(line, "r %q %q %q", &name, &product, &version); err == nil {
+				p := NewPackage(product, version)
+				p.packageName = name
+				requires = append(requires, p)
+			}
+
+		case 'p':
+			_, err = fmt.Sscanf(line, "p cnf %d %d", &f.vars, &numClauses)
+			if err == nil {
+				f.clauses = make(pigosat.Formula, 0, numClauses)
+			}
+
+		default:
+			if numClauses < 0 {
+				err = errors.New("clause before the preamble")
+				break
+			}
+			fields := strings.Fields(line)
+			clause := make([]pigosat.Literal, 0, len(fields))
+			for _, field := range fields {
+				var lit int
+				if lit, err = strconv.Atoi(field); err != nil {
+					break
+				}
+				if lit == 0 {
+					break
+				}
+				if lit > f.vars || -lit > f.vars {
+					err = fmt.Errorf("literal %d is out of range", lit)
+					break
+				}
+				clause = append(clause, pigosat.Literal(lit))
+			}
+			f.clauses = append(f.clauses, clause)
+		}
+
+		if err != nil {
+			return nil, fmt.Errorf("Frozen Resolver line %d: %s", lineNum, err.Error())
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	if numClauses < 0 {
+		return nil, errors.New("Frozen Resolver has no clauses preamble")
+	}
+	if len(f.clauses) != numClauses {
+		return nil, fmt.Errorf("Frozen Resolver expected %d clauses, but got %d", numClauses, len(f.clauses))
+	}
+	for id := range f.packages {
+		if int(id) > f.vars {
+			return nil, fmt.Errorf("Frozen Resolver Package id %d is out of range", id)
+		}
+	}
+	for id := range f.amo {
+		if int(id) > f.vars {
+			return nil, fmt.Errorf("Frozen Resolver auxiliary id %d is out of range", id)
+		}
+	}
+
+	res := &Resolver{requires: requires, frozen: f}
+	if err := res.Initialize(); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
@@ -0,0 +1,86 @@
+package pakr
+
+import (
+	"errors"
+	"fmt"
+)
+
+// A set of requirements that no longer resolves after a change
+// to the package index, as reported by ChangeImpact()
+type ImpactResult struct {
+	// The set of requirements
+	Requires Packages
+	// The requirements that caused the resolve to fail after the change
+	Conflicts Packages
+}
+
+// ChangeImpact is a what-if analysis of bumping a Product from one version
+// to another in the package index. Every reference to the old version in
+// the requires-groups of the index, and in the sets of requirements, is
+// changed to the new version, which takes over the dependencies of the old
+// version if it is not already defined, and the old version is removed.
+// Each set of requirements is resolved before and after the change, on
+// clones of the Resolver, so that this Resolver is not modified.
+//
+// Returns the sets that were solved before the change, but are not
+// solved after it, in the order of the sets, along with their conflicts.
+// Returns a non-nil error if the old version does not exist in the
+// package index, for a frozen Resolver, or if there was an internal error.
+func (r *Resolver) ChangeImpact(product, fromVersion, toVersion string, sets []Packages) ([]ImpactResult, error) {
+	if r.frozen != nil {
+		return nil, errors.New("Cannot change the package index of a frozen Resolver")
+	}
+
+	var from, to *Dependency
+	for dep := range r.dependencies() {
+		if dep.Target.ProductName() != product {
+			continue
+		}
+		switch dep.Target.Version() {
+		case fromVersion:
+			from = &dep
+		case toVersion:
+			to = &dep
+		}
+	}
+	if from == nil {
+		return nil, fmt.Errorf("Package %q does not exist in the Resolver",
+			NewPackage(product, fromVersion).PackageName())
+	}
+
+	before, err := r.Clone()
+	if err != nil {
+		return nil, err
+	}
+	beforeResults, _, err := before.ResolveBatch(sets, ResolveBatchOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	after, err := r.Clone()
+	if err != nil {
+		return nil, err
+	}
+	if to == nil {
+		// The new version takes over the dependencies of the old version
+		to = &Dependency{Target: NewPackage(product, toVersion), Requires: from.Requires}
+		if err := after.AddDependency(*to); err != nil {
+			return nil, err
+		}
+	}
+	if err := after.AddReplacement(from.Target, to.Target); err != nil {
+		return nil, err
+	}
+	afterResults, _, err := after.ResolveBatch(sets, ResolveBatchOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	var impact []ImpactResult
+	for i, result := range afterResults {
+		if beforeResults[i].Solved && !result.Solved {
+			impact = append(impact, ImpactResult{Requires: sets[i], Conflicts: result.Conflicts})
+		}
+	}
+	return impact, nil
+}
@@ -1,8 +1,14 @@
 package pakr
 
 import (
+	"encoding/json"
+	"errors"
 	"fmt"
+	"iter"
+	"sort"
 	"strings"
+	"sync"
+	"text/template"
 
 	"github.com/justinfx/pigosat"
 )
@@ -17,6 +23,43 @@ type Packager interface {
 	Version() string
 }
 
+// SourcePackager is an optional interface of a Packager, that reports
+// the source it was defined by, such as the name of a repository.
+// The source is carried through to the Packages of a solution,
+// which can be grouped by it with Resolver.SolutionBySource().
+type SourcePackager interface {
+	Packager
+	Source() string
+}
+
+// packageSource returns the source of a Packager that implements
+// SourcePackager, or an empty string
+func packageSource(p Packager) string {
+	if s, ok := p.(SourcePackager); ok {
+		return s.Source()
+	}
+	return ""
+}
+
+// KeyPackager is an optional interface of a Packager, that provides the
+// key identifying it to the solver, separately from its PackageName(),
+// which is only used for display. Packages with different keys are
+// distinct, even if they share the same PackageName(), such as the same
+// version of a product built for different platforms. Packagers that do
+// not implement it are identified by their PackageName().
+type KeyPackager interface {
+	Packager
+	SatKey() string
+}
+
+// packageKey returns the key identifying a Packager to the solver
+func packageKey(p Packager) string {
+	if k, ok := p.(KeyPackager); ok {
+		return k.SatKey()
+	}
+	return p.PackageName()
+}
+
 // A slice of Packagers that supports sorting
 type Packages []Packager
 
@@ -36,11 +79,177 @@ func (p Packages) Swap(i, j int) {
 }
 
 func (p Packages) String() string {
-	strs := make([]string, len(p))
-	for i, p := range p {
-		strs[i] = p.PackageName()
+	return strings.Join(p.Names(), ", ")
+}
+
+// Names returns the PackageName() of each Package, in order
+func (p Packages) Names() []string {
+	names := make([]string, len(p))
+	for i, pkg := range p {
+		names[i] = pkg.PackageName()
+	}
+	return names
+}
+
+// Products returns the unique product names of the Packages, sorted
+func (p Packages) Products() []string {
+	seen := make(map[string]bool, len(p))
+	products := make([]string, 0, len(p))
+	for _, pkg := range p {
+		if !seen[pkg.ProductName()] {
+			seen[pkg.ProductName()] = true
+			products = append(products, pkg.ProductName())
+		}
+	}
+	sort.Strings(products)
+	return products
+}
+
+// Table renders the Packages as a table with aligned product
+// and version columns, one Package per line
+func (p Packages) Table() string {
+	rows := make([][]string, len(p))
+	for i, pkg := range p {
+		rows[i] = []string{pkg.ProductName(), pkg.Version()}
+	}
+	return formatTable([]string{"PRODUCT", "VERSION"}, rows)
+}
+
+// Equal returns true if both lists contain the same set of Packages,
+// by their PackageName(), regardless of order and duplicates.
+// A nil list is equal to an empty list.
+func (p Packages) Equal(other Packages) bool {
+	names := make(map[string]bool, len(p))
+	for _, pkg := range p {
+		names[pkg.PackageName()] = true
+	}
+	found := make(map[string]bool, len(names))
+	for _, pkg := range other {
+		name := pkg.PackageName()
+		if !names[name] {
+			return false
+		}
+		found[name] = true
+	}
+	return len(found) == len(names)
+}
+
+// Diff compares the Packages with another list of Packages, by their
+// product and version. Returns the Packages that are only in the other
+// list as added, and the Packages that are only in this list as removed.
+func (p Packages) Diff(other Packages) (added, removed Packages) {
+	p.walkDiff(other,
+		func(pkg Packager) { added = append(added, pkg) },
+		func(pkg Packager) { removed = append(removed, pkg) })
+	return added, removed
+}
+
+// walkDiff compares the Packages with another list of Packages like
+// Diff(), calling onAdded for each Package that is only in the other
+// list, followed by onRemoved for each Package that is only in this list
+func (p Packages) walkDiff(other Packages, onAdded, onRemoved func(Packager)) {
+	type key struct{ product, version string }
+
+	inP := make(map[key]bool, len(p))
+	for _, pkg := range p {
+		inP[key{pkg.ProductName(), pkg.Version()}] = true
+	}
+	inOther := make(map[key]bool, len(other))
+	for _, pkg := range other {
+		k := key{pkg.ProductName(), pkg.Version()}
+		inOther[k] = true
+		if !inP[k] {
+			onAdded(pkg)
+		}
+	}
+	for _, pkg := range p {
+		if !inOther[key{pkg.ProductName(), pkg.Version()}] {
+			onRemoved(pkg)
+		}
+	}
+}
+
+// The JSON form of a Package, as used by the pakr command
+type jsonPackage struct {
+	Product string `json:"product"`
+	Version string `json:"version"`
+}
+
+// MarshalJSON encodes the Packages as a list of
+// {"product": ..., "version": ...} objects, the same as the output of
+// the pakr command, using the ProductName() and Version() of each
+// Packager. Nil Packages are encoded as null.
+func (p Packages) MarshalJSON() ([]byte, error) {
+	if p == nil {
+		return []byte("null"), nil
+	}
+	pkgs := make([]jsonPackage, len(p))
+	for i, pkg := range p {
+		if pkg == nil {
+			return nil, fmt.Errorf("Package at index %d is nil", i)
+		}
+		pkgs[i] = jsonPackage{pkg.ProductName(), pkg.Version()}
 	}
-	return strings.Join(strs, ", ")
+	return json.Marshal(pkgs)
+}
+
+// UnmarshalJSON decodes a list of {"product": ..., "version": ...}
+// objects, as encoded by MarshalJSON(). Each Package is decoded
+// as a *Package, whatever the type of the encoded Packager was.
+func (p *Packages) UnmarshalJSON(data []byte) error {
+	var pkgs []jsonPackage
+	if err := json.Unmarshal(data, &pkgs); err != nil {
+		return err
+	}
+	if pkgs == nil {
+		*p = nil
+		return nil
+	}
+	*p = make(Packages, len(pkgs))
+	for i, pkg := range pkgs {
+		(*p)[i] = NewPackage(pkg.Product, pkg.Version)
+	}
+	return nil
+}
+
+// Validate checks the Packages for internal consistency, before
+// they are used as a set of requirements. It reports nil entries,
+// entries with an empty product or version, and multiple versions
+// of the same Product (which can never be satisfied together).
+// Returns a joined error describing every problem found, or nil.
+func (p Packages) Validate() error {
+	var errs []error
+	seen := make(map[string]Packager, len(p))
+
+	for i, pkg := range p {
+		if pkg == nil {
+			errs = append(errs, fmt.Errorf("Package at index %d is nil", i))
+			continue
+		}
+
+		prodName := pkg.ProductName()
+		if prodName == "" {
+			errs = append(errs, fmt.Errorf("Package at index %d has an empty product name", i))
+		}
+		if pkg.Version() == "" {
+			errs = append(errs, fmt.Errorf("Package at index %d has an empty version", i))
+		}
+		if prodName == "" {
+			continue
+		}
+
+		other, ok := seen[prodName]
+		if !ok {
+			seen[prodName] = pkg
+			continue
+		}
+		if other.Version() != pkg.Version() {
+			errs = append(errs, fmt.Errorf("Packages %s and %s are different versions of product %q",
+				other.PackageName(), pkg.PackageName(), prodName))
+		}
+	}
+
+	return errors.Join(errs...)
 }
 
 // Defines a Package, and all of its direct dependencies.
@@ -68,6 +277,104 @@ func (p *Dependency) AddVersionSet(vers Packages) {
 	}
 }
 
+// LayerIndexes combines multiple layers of package indexes into a single
+// index, where later layers override earlier ones.
+//
+// When a layer defines a Dependency for a target Package (by PackageName),
+// it replaces every Dependency defined for that same target in the earlier
+// layers. The requires-groups are not merged, so that an overlay always
+// fully describes the dependencies of the Packages it redefines. Targets that
+// are only defined in earlier layers remain available. The combined index
+// keeps the order in which each target was first defined.
+func LayerIndexes(layers ...[]Dependency) []Dependency {
+	// Find the layer that has the final definition of each target,
+	// and the order in which the targets were first defined
+	owner := make(map[string]int)
+	order := []string{}
+	for i, layer := range layers {
+		for _, dep := range layer {
+			name := dep.Target.PackageName()
+			if _, ok := owner[name]; !ok {
+				order = append(order, name)
+			}
+			owner[name] = i
+		}
+	}
+
+	byName := make(map[string][]Dependency, len(order))
+	for i, layer := range layers {
+		for _, dep := range layer {
+			name := dep.Target.PackageName()
+			if owner[name] == i {
+				byName[name] = append(byName[name], dep)
+			}
+		}
+	}
+
+	index := make([]Dependency, 0, len(order))
+	for _, name := range order {
+		index = append(index, byName[name]...)
+	}
+	return index
+}
+
+// An IndexConflictError is returned by MergeIndexes() when the same
+// target Package is defined in more than one of the merged indexes
+type IndexConflictError struct {
+	// The PackageName of the target
+	Package string
+	// The positions of the first two indexes that define the target
+	First, Second int
+}
+
+func (e *IndexConflictError) Error() string {
+	return fmt.Sprintf("Package %q is defined in both index %d and index %d",
+		e.Package, e.First, e.Second)
+}
+
+// MergeIndexes combines multiple package indexes into a single index,
+// such as fragments of an index that are authored separately. Unlike
+// LayerIndexes(), the indexes may not override each other: the same target
+// Package (by PackageName) being defined in more than one index is an error.
+// Multiple Dependencies for a target within one index are all kept. The
+// combined index keeps the order of the indexes.
+// Returns an *IndexConflictError for the first target that is defined
+// in more than one index.
+func MergeIndexes(indexes ...[]Dependency) ([]Dependency, error) {
+	owner := make(map[string]int)
+	size := 0
+	for i, index := range indexes {
+		for _, dep := range index {
+			name := dep.Target.PackageName()
+			if prev, ok := owner[name]; ok && prev != i {
+				return nil, &IndexConflictError{name, prev, i}
+			}
+			owner[name] = i
+		}
+		size += len(index)
+	}
+
+	merged := make([]Dependency, 0, size)
+	for _, index := range indexes {
+		merged = append(merged, index...)
+	}
+	return merged, nil
+}
+
+// NameFormatter builds the PackageName of a Package from its product
+// name and version. The default joins them with a hyphen, as in
+// "product-1.0". It can be replaced to match the naming of another
+// ecosystem, such as "product@1.0" or "product==1.0", or to avoid
+// ambiguous names when products or versions contain hyphens.
+//
+// Package names are the keys of the SAT variables, so the formatter
+// should be set once at startup, before any Packages are created.
+// Mixing Packages named with different formats within one resolve
+// is unsupported.
+var NameFormatter = func(product, version string) string {
+	return product + "-" + version
+}
+
 // A Package is a specific version of a Product
 type Package struct {
 	product     string
@@ -80,15 +387,122 @@ func NewPackage(productName, version string) *Package {
 	return &Package{product: productName, version: version}
 }
 
+// The key of an interned Package
+type internKey struct {
+	product, version string
+}
+
+// An Interner shares the Packages created for the same product name
+// and version, so that the many references to the same version in a
+// large index, such as in the requires-groups of many Packages, use a
+// single allocation. Its Packages are kept until the Interner itself is
+// released, so an Interner can be scoped to the parse of one index.
+// The zero value is ready to use, and is safe for concurrent use.
+type Interner struct {
+	mu   sync.Mutex
+	pkgs map[internKey]*Package
+}
+
+// Intern returns the shared Package for a product name and version,
+// creating it on the first call for the same inputs. As with any
+// Package, its PackageName is built with the NameFormatter and cached
+// when it is first asked for, which is not synchronized, so a shared
+// Package should be named before it is used from multiple goroutines.
+func (in *Interner) Intern(productName, version string) *Package {
+	key := internKey{productName, version}
+	in.mu.Lock()
+	defer in.mu.Unlock()
+
+	if p, ok := in.pkgs[key]; ok {
+		return p
+	}
+	if in.pkgs == nil {
+		in.pkgs = make(map[internKey]*Package)
+	}
+	p := NewPackage(productName, version)
+	in.pkgs[key] = p
+	return p
+}
+
+// The Interner of Intern(), shared by every goroutine
+var internPool Interner
+
+// Intern returns a shared Package for a product name and version, from
+// a pool that is shared by every goroutine, as with Interner.Intern().
+// The Packages of the pool are never released, so it is intended for
+// the Packages of long lived package indexes. Use an Interner to scope
+// the shared Packages to the parse of one index instead.
+func Intern(productName, version string) *Package {
+	return internPool.Intern(productName, version)
+}
+
+// AnyVersion is the version of a wildcard requirement, which is
+// satisfied by any version of its product in the package index.
+const AnyVersion = "*"
+
+// ParseRequirement parses a requirement string of the form
+// <Name>-<Version> into a Package. It always expects the default
+// hyphen form, regardless of NameFormatter. The version is everything after
+// the last hyphen, so product names may contain hyphens. A version of
+// "*", such as "A-*", requires any version of the product, and is
+// expanded to the versions known to the Resolver at resolve time.
+// Returns a non-nil error if the string has no product or version.
+func ParseRequirement(s string) (*Package, error) {
+	product, version, ok := splitPackageName(s)
+	if !ok {
+		return nil, fmt.Errorf("Requirement %q is not of the form <Name>-<Version>", s)
+	}
+	return NewPackage(product, version), nil
+}
+
+// ParsePackage parses a string of the form <Name>-<Version> into a
+// Package. The version is everything after the last hyphen, so
+// "my-lib-1.2.3" is version "1.2.3" of product "my-lib". It always
+// expects the default hyphen form, regardless of NameFormatter.
+// Use ParseRequirement() to also accept a "*" version.
+// Returns a non-nil error if the string has no product or version.
+func ParsePackage(s string) (Packager, error) {
+	product, version, ok := splitPackageName(s)
+	if !ok || version == AnyVersion {
+		return nil, fmt.Errorf("Package %q is not of the form <Name>-<Version>", s)
+	}
+	return NewPackage(product, version), nil
+}
+
+// ParsePackages parses a list of strings with ParsePackage().
+// Returns a non-nil error for the first string that can not be parsed.
+func ParsePackages(ss []string) (Packages, error) {
+	pkgs := make(Packages, len(ss))
+	for i, s := range ss {
+		p, err := ParsePackage(s)
+		if err != nil {
+			return nil, err
+		}
+		pkgs[i] = p
+	}
+	return pkgs, nil
+}
+
+// splitPackageName splits a <Name>-<Version> string on its last hyphen.
+// Returns false if either part is empty.
+func splitPackageName(s string) (product, version string, ok bool) {
+	i := strings.LastIndex(s, "-")
+	if i <= 0 || i == len(s)-1 {
+		return "", "", false
+	}
+	return s[:i], s[i+1:], true
+}
+
 // ProductName returns the unversioned name of the product
 func (p *Package) ProductName() string {
 	return p.product
 }
 
-// PackageName returns the <Name>-<Version> of the Package
+// PackageName returns the <Name>-<Version> of the Package,
+// as formatted by NameFormatter
 func (p *Package) PackageName() string {
 	if p.packageName == "" {
-		p.packageName = fmt.Sprintf("%s-%s", p.product, p.version)
+		p.packageName = NameFormatter(p.product, p.version)
 	}
 	return p.packageName
 }
@@ -108,7 +522,7 @@ func (p *Package) String() string {
 func packagesToIds(a []Packager, idMap *stringIdMap) []pigosat.Literal {
 	ids := make([]pigosat.Literal, len(a))
 	for i, s := range a {
-		ids[i] = idMap.StringToId(s.PackageName())
+		ids[i] = idMap.StringToId(packageKey(s))
 	}
 	return ids
 }
@@ -117,11 +531,12 @@ func packagesToIds(a []Packager, idMap *stringIdMap) []pigosat.Literal {
 type packageSet map[string]Packager
 
 // A struct that tracks mappings of Product names to package sets,
-// and Package names to Packagers.
+// and Package keys and names to Packagers.
 // Create with NewProductMap()
 type ProductMap struct {
 	prods map[string]packageSet
 	pkgs  map[string]Packager
+	names map[string]Packager
 }
 
 // ProductMap tracks Packages, organizing them as a mapping of
@@ -130,6 +545,7 @@ func NewProductMap() *ProductMap {
 	return &ProductMap{
 		prods: map[string]packageSet{},
 		pkgs:  map[string]Packager{},
+		names: map[string]Packager{},
 	}
 }
 
@@ -144,19 +560,61 @@ func (m *ProductMap) NumPackages() int {
 }
 
 // Add a Package to the mapping
-// It will be organized by its Product name
+// It will be organized by its Product name.
+// A Package with the same key, which is the SatKey() of a KeyPackager,
+// or else the PackageName(), replaces the existing one, unless the
+// existing Package has a source, as reported by SourcePackager, and
+// the new one does not, so that the source is kept.
 func (m *ProductMap) Add(p Packager) {
+	key := packageKey(p)
+	if existing, ok := m.pkgs[key]; ok &&
+		packageSource(existing) != "" && packageSource(p) == "" {
+		return
+	}
+
 	prodName := p.ProductName()
-	pkgName := p.PackageName()
 
 	set, ok := m.prods[prodName]
 	if !ok {
-		m.prods[prodName] = packageSet{pkgName: p}
+		m.prods[prodName] = packageSet{key: p}
 	} else {
-		set[pkgName] = p
+		set[key] = p
+	}
+	// Index the Package by its key and name
+	m.pkgs[key] = p
+	m.names[p.PackageName()] = p
+}
+
+// add performs an Add(), after checking the Package for a collision.
+// Returns a non-nil error if a different Package, with another product
+// or version, already exists with the same key, since it would otherwise
+// be merged with the existing one, or if a Package with the same key
+// is from another source.
+func (m *ProductMap) add(p Packager) error {
+	if err := m.checkCollision(p); err != nil {
+		return err
+	}
+	m.Add(p)
+	return nil
+}
+
+// checkCollision returns a non-nil error if a Package with the same
+// key, but a different product or version, already exists
+func (m *ProductMap) checkCollision(p Packager) error {
+	existing, ok := m.pkgs[packageKey(p)]
+	if !ok {
+		return nil
+	}
+	if existing.ProductName() != p.ProductName() || existing.Version() != p.Version() {
+		return fmt.Errorf("Package name %q is used by both product %q version %q, and product %q version %q",
+			p.PackageName(), existing.ProductName(), existing.Version(), p.ProductName(), p.Version())
+	}
+	src, existingSrc := packageSource(p), packageSource(existing)
+	if src != "" && existingSrc != "" && src != existingSrc {
+		return fmt.Errorf("Package %q is defined by both source %q and source %q",
+			p.PackageName(), existingSrc, src)
 	}
-	// Index the Package by its name
-	m.pkgs[pkgName] = p
+	return nil
 }
 
 // Retrieve all Packages mapped by their Product name
@@ -174,14 +632,18 @@ func (m *ProductMap) Packages(productName string) []Packager {
 	return packs
 }
 
-// Looks up and returns a Package by its PackageName
+// Looks up and returns a Package by its key, which is the SatKey()
+// of a KeyPackager, or else by its PackageName. When Packages with
+// different keys share a PackageName, the last one added is returned.
 // Returns a non-nil error if not found
 func (m *ProductMap) PackageByName(packageName string) (Packager, error) {
-	p, ok := m.pkgs[packageName]
-	if ok {
+	if p, ok := m.pkgs[packageName]; ok {
 		return p, nil
 	}
-	return p, fmt.Errorf("Package %q does not exist", packageName)
+	if p, ok := m.names[packageName]; ok {
+		return p, nil
+	}
+	return nil, fmt.Errorf("Package %q does not exist", packageName)
 }
 
 // A constant defining a relationship of a Packages contribution
@@ -189,10 +651,11 @@ func (m *ProductMap) PackageByName(packageName string) (Packager, error) {
 type Relation string
 
 const (
-	Required  Relation = `Required`
-	Conflicts Relation = `Conflicts`
-	Depends   Relation = `Depends`
-	Restricts Relation = `Restricted`
+	Required      Relation = `Required`
+	RequiredOneOf Relation = `RequiredOneOf`
+	Conflicts     Relation = `Conflicts`
+	Depends       Relation = `Depends`
+	Restricts     Relation = `Restricted`
 )
 
 // PackageRelation relationship of either one Package
@@ -202,25 +665,119 @@ type PackageRelation struct {
 	Relates  Relation
 }
 
+// The names of the Packages involved in a PackageRelation,
+// as passed to the RelationTemplates
+type RelationNames struct {
+	// The name of the first Package
+	Package string
+	// The names of the other Packages, separated by commas
+	Others string
+	// The names of all of the Packages, separated by commas
+	All string
+}
+
+// The default phrases for each Relation
+var defaultRelationTemplates = map[Relation]string{
+	Required:      "Package {{.Package}} is required",
+	RequiredOneOf: "One of ({{.All}}) is required",
+	Restricts:     "Package {{.Package}} is not allowed",
+	Depends:       "Package {{.Package}} depends on one of ({{.Others}})",
+	Conflicts:     "Package {{.Package}} conflicts with ({{.Others}})",
+}
+
+// RelationTemplates are the text/template phrases used by
+// PackageRelation.String() for each Relation, which are executed with
+// the RelationNames of the relationship. Replace a template to customize
+// or translate the conflict reports. A Relation without a valid template
+// falls back to the default English phrase. Each template is parsed the
+// first time it is used, and the parsed template is reused afterwards.
+var RelationTemplates = map[Relation]string{
+	Required:      defaultRelationTemplates[Required],
+	RequiredOneOf: defaultRelationTemplates[RequiredOneOf],
+	Restricts:     defaultRelationTemplates[Restricts],
+	Depends:       defaultRelationTemplates[Depends],
+	Conflicts:     defaultRelationTemplates[Conflicts],
+}
+
 // Generate the string representation of the relationship
-// as a descriptive phrase.
+// as a descriptive phrase, using the RelationTemplates.
 func (r *PackageRelation) String() string {
-	switch r.Relates {
-	case Required:
-		return fmt.Sprintf("Package %s is required", r.Packages[0].PackageName())
-	case Restricts:
-		return fmt.Sprintf("Package %s is not allowed", r.Packages[0].PackageName())
-	case Depends:
-		return fmt.Sprintf("Package %s depends on one of (%s)", r.Packages[0].PackageName(), r.Packages[1:])
-	case Conflicts:
-		return fmt.Sprintf("Package %s conflicts with (%s)", r.Packages[0].PackageName(), r.Packages[1:])
+	names := RelationNames{All: r.Packages.String()}
+	if len(r.Packages) > 0 {
+		names.Package = r.Packages[0].PackageName()
+		names.Others = r.Packages[1:].String()
+	}
+
+	if text, ok := RelationTemplates[r.Relates]; ok {
+		if phrase, err := executeRelationTemplate(text, names); err == nil {
+			return phrase
+		}
+	}
+	if text, ok := defaultRelationTemplates[r.Relates]; ok {
+		phrase, _ := executeRelationTemplate(text, names)
+		return phrase
 	}
 	return ""
 }
 
+// A parsed relation phrase template, or the error parsing it
+type parsedRelationTemplate struct {
+	tmpl *template.Template
+	err  error
+}
+
+// The parsed relation phrase templates, by their text, so that
+// each template is only parsed the first time it is used
+var relationTemplateCache sync.Map
+
+// executeRelationTemplate renders a relation phrase template
+func executeRelationTemplate(text string, names RelationNames) (string, error) {
+	cached, ok := relationTemplateCache.Load(text)
+	if !ok {
+		tmpl, err := template.New("relation").Parse(text)
+		cached, _ = relationTemplateCache.LoadOrStore(text, parsedRelationTemplate{tmpl, err})
+	}
+	parsed := cached.(parsedRelationTemplate)
+	if parsed.err != nil {
+		return "", parsed.err
+	}
+	var buf strings.Builder
+	if err := parsed.tmpl.Execute(&buf, names); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
 // PackageRelations is a list of PackageRelation objects
 type PackageRelations []*PackageRelation
 
+// The order of each Relation when sorting PackageRelations, which
+// follows the requirements through their dependencies to the conflicts
+var relationOrder = map[Relation]int{
+	Required:      0,
+	RequiredOneOf: 1,
+	Depends:       2,
+	Conflicts:     3,
+	Restricts:     4,
+}
+
+// sortRelations sorts PackageRelations by their Relation, and then
+// by the names of their Packages, starting with the first Package
+func sortRelations(rels PackageRelations) {
+	sort.SliceStable(rels, func(i, j int) bool {
+		a, b := rels[i], rels[j]
+		if a.Relates != b.Relates {
+			return relationOrder[a.Relates] < relationOrder[b.Relates]
+		}
+		for k := 0; k < len(a.Packages) && k < len(b.Packages); k++ {
+			if c := strings.Compare(a.Packages[k].PackageName(), b.Packages[k].PackageName()); c != 0 {
+				return c < 0
+			}
+		}
+		return len(a.Packages) < len(b.Packages)
+	})
+}
+
 // Generate all descriptive phrases for contained relationships,
 // separated by newlines
 func (p PackageRelations) String() string {
@@ -234,20 +791,102 @@ func (p PackageRelations) String() string {
 	return strings.Join(strs, "\n")
 }
 
-// Take a list of Dependency objects, and produce a flat
+// Table renders the relationships as a table with aligned columns
+// for the relation type, the Package, and the other involved Packages
+func (p PackageRelations) Table() string {
+	rows := make([][]string, len(p))
+	for i, rel := range p {
+		var pkg, others string
+		if rel.Relates == RequiredOneOf {
+			others = rel.Packages.String()
+		} else if len(rel.Packages) > 0 {
+			pkg = rel.Packages[0].PackageName()
+			others = rel.Packages[1:].String()
+		}
+		rows[i] = []string{string(rel.Relates), pkg, others}
+	}
+	return formatTable([]string{"RELATION", "PACKAGE", "WITH"}, rows)
+}
+
+// formatTable renders a header and rows of cells as lines of text,
+// where each column is padded to the width of its widest cell.
+// Cells are never truncated.
+func formatTable(header []string, rows [][]string) string {
+	widths := make([]int, len(header))
+	for i, cell := range header {
+		widths[i] = len(cell)
+	}
+	for _, row := range rows {
+		for i, cell := range row {
+			if len(cell) > widths[i] {
+				widths[i] = len(cell)
+			}
+		}
+	}
+
+	var buf strings.Builder
+	writeRow := func(row []string) {
+		var line strings.Builder
+		for i, cell := range row {
+			if i > 0 {
+				line.WriteString("  ")
+			}
+			line.WriteString(cell)
+			if i < len(row)-1 {
+				line.WriteString(strings.Repeat(" ", widths[i]-len(cell)))
+			}
+		}
+		buf.WriteString(strings.TrimRight(line.String(), " "))
+		buf.WriteString("\n")
+	}
+
+	writeRow(header)
+	for _, row := range rows {
+		writeRow(row)
+	}
+	return buf.String()
+}
+
+// compareSortOrder orders two Packages for the sort modes of a Resolver,
+// by their PackageName(), as with Packages.Less(). Packages with the same
+// PackageName(), such as the same version for different platforms told
+// apart by a KeyPackager, are ordered by their keys, so that distinct
+// Packages have a total order.
+func compareSortOrder(a, b Packager) int {
+	if c := strings.Compare(a.PackageName(), b.PackageName()); c != 0 {
+		return c
+	}
+	return strings.Compare(packageKey(a), packageKey(b))
+}
+
+// comparePackages orders two Packages by their product name, then by
+// their version as compared by CompareVersions(). Packages that are still
+// equal, such as the same version for different platforms, are ordered
+// by their PackageName(), so that distinct Packages have a total order.
+func comparePackages(a, b Packager) int {
+	if c := strings.Compare(a.ProductName(), b.ProductName()); c != 0 {
+		return c
+	}
+	if c := CompareVersions(a.Version(), b.Version()); c != 0 {
+		return c
+	}
+	return strings.Compare(a.PackageName(), b.PackageName())
+}
+
+// Take a sequence of Dependency objects, and produce a flat
 // list of Packagers from all targets and dependencies.
 // Basically a list of every reference to every Packager.
-func flattenDependencies(deps []Dependency) Packages {
-	packMap := make(map[string]Packager, len(deps))
-	for _, d := range deps {
-		packMap[d.Target.PackageName()] = d.Target
+func flattenDependencies(deps iter.Seq[Dependency]) Packages {
+	packMap := make(map[string]Packager)
+	for d := range deps {
+		packMap[packageKey(d.Target)] = d.Target
 		if d.Requires == nil {
 			continue
 		}
 
 		for _, verList := range d.Requires {
 			for _, ver := range verList {
-				packMap[ver.PackageName()] = ver
+				packMap[packageKey(ver)] = ver
 			}
 		}
 	}
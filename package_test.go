@@ -1,15 +1,28 @@
 package pakr
 
 import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"iter"
+	"regexp"
+	"slices"
 	"sort"
+	"strconv"
+	"strings"
+	"sync"
 	"testing"
+
+	"github.com/justinfx/pigosat"
 )
 
-func TestPackageSuccess(t *testing.T) {
+// sampleIndex returns a small index, with a mix of
+// single and multi-version Products
+func sampleIndex() []Dependency {
 	P := NewPackage
 
-	// Our specific package requirements and their deps
-	index := []Dependency{
+	return []Dependency{
 		{
 			P("A", "1.0.0"), []Packages{
 				{P("B", "1.2.3"), P("B", "1.2.5"), P("B", "1.2.9")},
@@ -37,6 +50,11 @@ func TestPackageSuccess(t *testing.T) {
 		},
 		{P("Z", "1.0.0"), nil},
 	}
+}
+
+func TestPackageSuccess(t *testing.T) {
+	// Our specific package requirements and their deps
+	index := sampleIndex()
 
 	// Just set our specific requires to be each Package defined
 	// in the Dependency index
@@ -230,3 +248,4723 @@ func TestChooseLowVersions(t *testing.T) {
 		}
 	}
 }
+
+func TestPackagesValidate(t *testing.T) {
+	P := NewPackage
+
+	valid := Packages{P("A", "1.0.0"), P("B", "2.0.0"), P("A", "1.0.0")}
+	if err := valid.Validate(); err != nil {
+		t.Fatalf("Expected valid Packages, but got error: %s", err.Error())
+	}
+
+	invalid := Packages{
+		P("A", "1.0.0"),
+		nil,
+		P("", "1.0.0"),
+		P("B", ""),
+		P("A", "2.0.0"),
+	}
+	err := invalid.Validate()
+	if err == nil {
+		t.Fatal("Expected Validate to fail, but got a nil error")
+	}
+	t.Log(err)
+
+	expected := []string{
+		"index 1 is nil",
+		"index 2 has an empty product name",
+		"index 3 has an empty version",
+		"A-1.0.0 and A-2.0.0 are different versions",
+	}
+	for _, msg := range expected {
+		if !strings.Contains(err.Error(), msg) {
+			t.Errorf("Expected error to contain %q", msg)
+		}
+	}
+}
+
+func TestSolutionMap(t *testing.T) {
+	P := NewPackage
+
+	index := []Dependency{
+		{
+			P("A", "1.0.0"), []Packages{
+				{P("B", "1.2.3"), P("B", "1.2.5"), P("B", "1.2.9")},
+				{P("C", "2.0.0"), P("C", "2.1.0"), P("C", "2.2.0")},
+			},
+		},
+		{
+			P("C", "2.1.0"), []Packages{
+				{P("D", "5.0.0"), P("D", "5.0.1")},
+			},
+		},
+		{P("D", "5.0.1"), []Packages{{P("B", "1.2.3"), P("B", "1.2.9")}}},
+	}
+
+	resolver := NewResolver(Packages{P("A", "1.0.0"), P("C", "2.1.0")}, index)
+
+	solved, err := resolver.Resolve()
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if !solved {
+		t.Fatal("Resolver was expected to succeed, but failed.")
+	}
+
+	vers, err := resolver.SolutionMap()
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	t.Log(vers)
+
+	solution := resolver.Solution()
+	if len(vers) != len(solution) {
+		t.Fatalf("Expected %d products in map, but got %d", len(solution), len(vers))
+	}
+	for _, pkg := range solution {
+		if vers[pkg.ProductName()] != pkg.Version() {
+			t.Errorf("Expected product %s to map to version %s, but got %q",
+				pkg.ProductName(), pkg.Version(), vers[pkg.ProductName()])
+		}
+	}
+	for _, prod := range []string{"A", "B", "C", "D"} {
+		if _, ok := vers[prod]; !ok {
+			t.Errorf("Expected product %s in the solution map", prod)
+		}
+	}
+	if vers["A"] != "1.0.0" || vers["C"] != "2.1.0" {
+		t.Errorf("Expected required versions A-1.0.0 and C-2.1.0, but got %v", vers)
+	}
+}
+
+func TestAddDependency(t *testing.T) {
+	P := NewPackage
+
+	index := []Dependency{
+		{P("A", "1.0.0"), []Packages{{P("B", "1.0.0")}}},
+		{P("B", "1.0.0"), []Packages{{P("C", "1.0.0")}}},
+	}
+
+	resolver := NewResolver(Packages{P("A", "2.0.0")}, index)
+
+	if err := resolver.AddDependency(Dependency{P("A", "2.0.0"), []Packages{{P("B", "2.0.0")}}}); err != nil {
+		t.Fatal(err.Error())
+	}
+	if err := resolver.AddDependency(Dependency{P("B", "2.0.0"), []Packages{{P("C", "1.0.0")}}}); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	solved, err := resolver.Resolve()
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if !solved {
+		t.Fatal("Resolver was expected to succeed, but failed.")
+	}
+
+	solution := resolver.Solution()
+	sort.Sort(solution)
+	expected := Packages{P("A", "2.0.0"), P("B", "2.0.0"), P("C", "1.0.0")}
+	if solution.String() != expected.String() {
+		t.Fatalf("Expected solution %s, but got %s", expected, solution)
+	}
+
+	// The new versions must conflict with the existing ones
+	resolver.RequireTemp(P("A", "2.0.0"))
+	resolver.RequireTemp(P("B", "1.0.0"))
+	if solved, err = resolver.Resolve(); err != nil {
+		t.Fatal(err.Error())
+	}
+	if solved {
+		t.Fatal("Resolver was expected to fail with B-1.0.0 and B-2.0.0, but succeeded.")
+	}
+}
+
+func TestAddDependencyConstraints(t *testing.T) {
+	P := NewPackage
+
+	resolve := func(resolver *Resolver) bool {
+		solved, err := resolver.Resolve()
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		return solved
+	}
+
+	// The index of the caller is not modified
+	index := make([]Dependency, 1, 4)
+	index[0] = Dependency{P("A", "1.0"), nil}
+	resolver := NewResolver(Packages{P("A", "1.0")}, index)
+	if err := resolver.AddDependency(Dependency{P("A", "2.0"), nil}); err != nil {
+		t.Fatal(err.Error())
+	}
+	if index[:2][1].Target != nil {
+		t.Fatal("Expected AddDependency to not write to the index of the caller")
+	}
+
+	// An excluded Product excludes its new versions
+	resolver = NewResolver(nil, []Dependency{{P("X", "1.0"), nil}})
+	if err := resolver.ExcludeProduct("X"); err != nil {
+		t.Fatal(err.Error())
+	}
+	if err := resolver.AddDependency(Dependency{P("X", "2.0"), nil}); err != nil {
+		t.Fatal(err.Error())
+	}
+	resolver.RequireTemp(P("X", "2.0"))
+	if resolve(resolver) {
+		t.Error("Expected the new version of an excluded Product to fail, but it succeeded.")
+	}
+
+	// A new version satisfies a minimum version
+	resolver = NewResolver(nil, []Dependency{{P("A", "1.0"), nil}})
+	if err := resolver.RequireAtLeast("A", "1.0"); err != nil {
+		t.Fatal(err.Error())
+	}
+	resolver.RequireTemp(P("A", "2.0"))
+	if err := resolver.AddDependency(Dependency{P("A", "2.0"), nil}); err != nil {
+		t.Fatal(err.Error())
+	}
+	if !resolve(resolver) {
+		t.Error("Expected a new version above the minimum version to succeed, but it failed.")
+	}
+
+	// An ignored dependency is not added
+	resolver = NewResolver(Packages{P("D", "1.0")}, []Dependency{{P("E", "1.0"), nil}})
+	resolver.IgnoreDependency("D", "E")
+	if err := resolver.AddDependency(Dependency{P("D", "1.0"), []Packages{{P("E", "1.0")}}}); err != nil {
+		t.Fatal(err.Error())
+	}
+	if !resolve(resolver) {
+		t.Fatal("Resolver was expected to succeed, but failed.")
+	}
+	if vers, _ := resolver.SolutionMap(); vers["E"] != "" {
+		t.Errorf("Expected the ignored dependency E to not be required, but got %s", resolver.Solution())
+	}
+
+	// The pairs of a conflict source apply to new Packages
+	resolver = NewResolver(nil, []Dependency{{P("A", "1.0"), nil}})
+	resolver.SetConflictSource(func() [][2]Packager {
+		return [][2]Packager{{P("F", "1.0"), P("G", "1.0")}}
+	})
+	for _, dep := range []Dependency{{P("F", "1.0"), nil}, {P("G", "1.0"), nil}} {
+		if err := resolver.AddDependency(dep); err != nil {
+			t.Fatal(err.Error())
+		}
+	}
+	resolver.SetRequirements(Packages{P("F", "1.0"), P("G", "1.0")})
+	if resolve(resolver) {
+		t.Error("Expected the conflicting pair F-1.0 and G-1.0 to fail, but it succeeded.")
+	}
+
+	// References to a replaced Package resolve to its replacement
+	resolver = NewResolver(Packages{P("I", "1.0")}, []Dependency{{P("H", "1.0"), nil}, {P("H2", "1.0"), nil}})
+	if err := resolver.AddReplacement(P("H", "1.0"), P("H2", "1.0")); err != nil {
+		t.Fatal(err.Error())
+	}
+	if err := resolver.AddDependency(Dependency{P("I", "1.0"), []Packages{{P("H", "1.0")}}}); err != nil {
+		t.Fatal(err.Error())
+	}
+	if !resolve(resolver) {
+		t.Fatal("Resolver was expected to succeed, but failed.")
+	}
+	if vers, _ := resolver.SolutionMap(); vers["H2"] != "1.0" || vers["H"] != "" {
+		t.Errorf("Expected H2-1.0 in place of H-1.0, but got %s", resolver.Solution())
+	}
+
+	// A Product that gains a version is no longer external
+	resolver = NewResolver(nil, []Dependency{{P("J", "1.0"), []Packages{{P("K", "1.0")}}}})
+	resolver.AssumeExternalAvailable(true)
+	if err := resolver.AddDependency(Dependency{P("K", "2.0"), nil}); err != nil {
+		t.Fatal(err.Error())
+	}
+	resolver.RequireTemp(P("K", "2.0"))
+	if !resolve(resolver) {
+		t.Error("Expected a defined version of a former external Product to succeed, but it failed.")
+	}
+}
+
+// benchIndex builds a package index of products that each have
+// multiple versions depending on any version of the next product.
+func benchIndex(numProducts, numVersions int) []Dependency {
+	index := make([]Dependency, 0, numProducts*numVersions)
+	for i := 0; i < numProducts; i++ {
+		var next Packages
+		if i < numProducts-1 {
+			for v := 0; v < numVersions; v++ {
+				next = append(next, NewPackage(fmt.Sprintf("P%d", i+1), fmt.Sprintf("%d.0.0", v)))
+			}
+		}
+		for v := 0; v < numVersions; v++ {
+			dep := Dependency{NewPackage(fmt.Sprintf("P%d", i), fmt.Sprintf("%d.0.0", v)), nil}
+			if next != nil {
+				dep.Requires = []Packages{next}
+			}
+			index = append(index, dep)
+		}
+	}
+	return index
+}
+
+func BenchmarkAddDependency(b *testing.B) {
+	index := benchIndex(100, 10)
+	resolver := NewResolver(Packages{}, index)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		dep := Dependency{NewPackage("P0", fmt.Sprintf("new.%d", i)), []Packages{{NewPackage("P1", "0.0.0")}}}
+		if err := resolver.AddDependency(dep); err != nil {
+			b.Fatal(err.Error())
+		}
+	}
+}
+
+func BenchmarkAddDependencyReinit(b *testing.B) {
+	index := benchIndex(100, 10)
+	resolver := NewResolver(Packages{}, index)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		dep := Dependency{NewPackage("P0", fmt.Sprintf("new.%d", i)), []Packages{{NewPackage("P1", "0.0.0")}}}
+		index = append(index, dep)
+		resolver.SetPackageIndex(index)
+	}
+}
+
+func TestResolvePreferred(t *testing.T) {
+	P := NewPackage
+
+	index := []Dependency{
+		{P("A", "1.0.0"), []Packages{{P("B", "1.0.0"), P("B", "2.0.0"), P("B", "3.0.0")}}},
+		{P("C", "1.0.0"), []Packages{{P("B", "1.0.0"), P("B", "3.0.0")}}},
+	}
+
+	resolver := NewResolver(Packages{P("A", "1.0.0")}, index)
+	resolver.SetPreference(P("B", "2.0.0"), 10)
+	resolver.SetPreference(P("B", "3.0.0"), 5)
+	resolver.SetPreference(P("B", "1.0.0"), 1)
+
+	solved, err := resolver.ResolvePreferred()
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if !solved {
+		t.Fatal("Resolver was expected to succeed, but failed.")
+	}
+	vers, _ := resolver.SolutionMap()
+	if vers["B"] != "2.0.0" {
+		t.Fatalf("Expected preferred B-2.0.0 to be chosen, but got B-%s", vers["B"])
+	}
+
+	// When the highest score is not allowed, the next one is chosen
+	resolver.RequireTemp(P("C", "1.0.0"))
+	if solved, err = resolver.ResolvePreferred(); err != nil {
+		t.Fatal(err.Error())
+	}
+	if !solved {
+		t.Fatal("Resolver was expected to succeed, but failed.")
+	}
+	vers, _ = resolver.SolutionMap()
+	if vers["B"] != "3.0.0" {
+		t.Fatalf("Expected preferred B-3.0.0 to be chosen, but got B-%s", vers["B"])
+	}
+}
+
+func TestRequiredBy(t *testing.T) {
+	P := NewPackage
+
+	index := []Dependency{
+		{P("A", "1.0.0"), []Packages{{P("B", "1.0.0"), P("B", "2.0.0")}, {P("C", "1.0.0")}}},
+		{P("B", "2.0.0"), []Packages{{P("C", "1.0.0")}}},
+		{P("D", "1.0.0"), []Packages{{P("C", "1.0.0")}}},
+	}
+
+	resolver := NewResolver(Packages{P("A", "1.0.0"), P("B", "2.0.0")}, index)
+
+	solved, err := resolver.Resolve()
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if !solved {
+		t.Fatal("Resolver was expected to succeed, but failed.")
+	}
+
+	parents, err := resolver.RequiredBy(P("C", "1.0.0"))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	sort.Sort(parents)
+	if parents.String() != "A-1.0.0, B-2.0.0" {
+		t.Errorf("Expected C-1.0.0 to be required by A-1.0.0, B-2.0.0, but got %s", parents)
+	}
+
+	parents, err = resolver.RequiredBy(P("A", "1.0.0"))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if len(parents) != 0 {
+		t.Errorf("Expected no parents for a root requirement, but got %s", parents)
+	}
+
+	if _, err = resolver.RequiredBy(P("D", "1.0.0")); err == nil {
+		t.Error("Expected an error for a Package that is not in the solution")
+	}
+}
+
+func TestAllSolutions(t *testing.T) {
+	P := NewPackage
+
+	index := []Dependency{
+		{P("A", "1.0.0"), []Packages{{P("B", "1.0.0"), P("B", "2.0.0")}}},
+		{P("B", "1.0.0"), []Packages{{P("C", "1.0.0")}}},
+		{P("B", "2.0.0"), []Packages{{P("C", "1.0.0"), P("C", "2.0.0")}}},
+	}
+
+	resolver := NewResolver(Packages{P("A", "1.0.0")}, index)
+
+	solutions, err := resolver.AllSolutions(0)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	found := make(map[string]bool)
+	for _, solution := range solutions {
+		sort.Sort(solution)
+		t.Log(solution)
+		found[solution.String()] = true
+	}
+
+	expected := []string{
+		"A-1.0.0, B-1.0.0, C-1.0.0",
+		"A-1.0.0, B-2.0.0, C-1.0.0",
+		"A-1.0.0, B-2.0.0, C-2.0.0",
+	}
+	if len(solutions) != len(expected) {
+		t.Fatalf("Expected %d solutions, but got %d", len(expected), len(solutions))
+	}
+	for _, e := range expected {
+		if !found[e] {
+			t.Errorf("Expected solution %q was not found", e)
+		}
+	}
+
+	if solutions, err = resolver.AllSolutions(2); err != nil {
+		t.Fatal(err.Error())
+	}
+	if len(solutions) != 2 {
+		t.Errorf("Expected a max of 2 solutions, but got %d", len(solutions))
+	}
+
+	// The Resolver itself is unaffected by the blocked solutions
+	solved, err := resolver.Resolve()
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if !solved {
+		t.Fatal("Resolver was expected to succeed, but failed.")
+	}
+}
+
+func TestRedundantRequirements(t *testing.T) {
+	P := NewPackage
+
+	index := []Dependency{
+		{P("A", "1.0.0"), []Packages{{P("B", "1.0.0")}}},
+		{P("B", "1.0.0"), []Packages{{P("C", "1.0.0"), P("C", "2.0.0")}}},
+		{P("D", "1.0.0"), nil},
+	}
+
+	requires := Packages{P("A", "1.0.0"), P("B", "1.0.0"), P("C", "2.0.0"), P("D", "1.0.0")}
+	resolver := NewResolver(requires, index)
+
+	if _, err := resolver.RedundantRequirements(); err == nil {
+		t.Error("Expected an error before resolving")
+	}
+
+	solved, err := resolver.Resolve()
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if !solved {
+		t.Fatal("Resolver was expected to succeed, but failed.")
+	}
+
+	redundant, err := resolver.RedundantRequirements()
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if redundant.String() != "B-1.0.0" {
+		t.Errorf("Expected only B-1.0.0 to be redundant, but got %q", redundant)
+	}
+
+	if !resolver.Solved() {
+		t.Error("Expected the Resolver to be solved after checking for redundant requirements")
+	}
+	if len(resolver.Solution()) != 4 {
+		t.Errorf("Expected the solution to be restored, but got %s", resolver.Solution())
+	}
+
+	// The pushed frames and temporary requirements of the last solve
+	// are part of the remaining requirements
+	for _, temp := range []bool{false, true} {
+		resolver.SetRequirements(Packages{P("B", "1.0.0"), P("D", "1.0.0")})
+		if temp {
+			resolver.RequireTemp(P("A", "1.0.0"))
+		} else {
+			resolver.PushAssumptions(Packages{P("A", "1.0.0")})
+		}
+		if solved, _ := resolver.Resolve(); !solved {
+			t.Fatal("Resolver was expected to succeed, but failed.")
+		}
+		if redundant, err = resolver.RedundantRequirements(); err != nil {
+			t.Fatal(err.Error())
+		}
+		if redundant.String() != "B-1.0.0" {
+			t.Errorf("Expected B-1.0.0 to be redundant with A-1.0.0 (temp=%v), but got %q", temp, redundant)
+		}
+		if !resolver.Solved() || !strings.Contains(resolver.Solution().String(), "A-1.0.0") {
+			t.Errorf("Expected the solution with A-1.0.0 to be kept, but got %s", resolver.Solution())
+		}
+		resolver.PopAssumptions()
+	}
+}
+
+func TestCompareVersions(t *testing.T) {
+	cases := []struct {
+		a, b     string
+		expected int
+	}{
+		{"1.0.0", "1.0.0", 0},
+		{"1.0.0", "2.0.0", -1},
+		{"1.10.0", "1.9.0", 1},
+		{"1.2.10", "1.2.9", 1},
+		{"1.0", "1.0.0", -1},
+		{"1.0.0-beta", "1.0.0-alpha", 1},
+		{"1.0.0", "1.0.0-rc1", 1},
+		{"1.0.0-rc1", "1.0.0-rc2", -1},
+		{"1.0.0-rc.1", "1.0.0-rc.1.1", -1},
+		{"1.0.0-1", "1.0.0-alpha", -1},
+		{"1.0.1-rc1", "1.0.0", 1},
+		{"1.a", "1.1", 1},
+	}
+	for _, c := range cases {
+		if actual := CompareVersions(c.a, c.b); actual != c.expected {
+			t.Errorf("CompareVersions(%q, %q): expected %d, but got %d", c.a, c.b, c.expected, actual)
+		}
+	}
+}
+
+func TestRequireAtLeast(t *testing.T) {
+	P := NewPackage
+
+	index := []Dependency{
+		{P("A", "1.0"), nil},
+		{P("A", "2.0"), nil},
+		{P("A", "3.0"), nil},
+		{P("B", "1.0"), []Packages{{P("A", "1.0"), P("A", "2.0"), P("A", "3.0")}}},
+	}
+
+	resolver := NewResolver(Packages{P("B", "1.0")}, index)
+
+	if err := resolver.RequireAtLeast("A", "4.0"); err == nil {
+		t.Error("Expected an error when no version satisfies the minimum version")
+	}
+	if err := resolver.RequireAtLeast("Q", "1.0"); err == nil {
+		t.Error("Expected an error for an unknown product")
+	}
+	if err := resolver.RequireAtLeast("A", "2.0"); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	solutions, err := resolver.AllSolutions(0)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if len(solutions) != 2 {
+		t.Fatalf("Expected 2 solutions, but got %d", len(solutions))
+	}
+	for _, solution := range solutions {
+		vers := make(map[string]string)
+		for _, p := range solution {
+			vers[p.ProductName()] = p.Version()
+		}
+		if vers["A"] != "2.0" && vers["A"] != "3.0" {
+			t.Errorf("Expected A-2.0 or A-3.0, but got solution %s", solution)
+		}
+	}
+
+	// Requiring a lower version conflicts with the minimum version
+	resolver.RequireTemp(P("A", "1.0"))
+	solved, err := resolver.Resolve()
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if solved {
+		t.Fatal("Resolver was expected to fail, but succeeded.")
+	}
+	detailed, err := resolver.DetailedConflicts()
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	t.Log(detailed)
+
+	// The minimum version is kept across a new index
+	resolver.SetPackageIndex(index)
+	resolver.RequireTemp(P("A", "1.0"))
+	if solved, err = resolver.Resolve(); err != nil {
+		t.Fatal(err.Error())
+	}
+	if solved {
+		t.Fatal("Resolver was expected to fail after reinitializing, but succeeded.")
+	}
+
+	// Replacing the minimum version keeps the temporary requirements
+	resolver.RequireTemp(P("A", "1.0"))
+	if err = resolver.RequireAtLeast("A", "3.0"); err != nil {
+		t.Fatal(err.Error())
+	}
+	if solved, err = resolver.Resolve(); err != nil {
+		t.Fatal(err.Error())
+	}
+	if solved {
+		t.Fatal("Resolver was expected to fail with the temporary requirement, but succeeded.")
+	}
+
+	// A new index without the Product skips the minimum version
+	if err = resolver.SetPackageIndex([]Dependency{{P("B", "1.0"), nil}}); err != nil {
+		t.Fatalf("Expected the minimum version to be skipped, but got: %s", err.Error())
+	}
+	if solved, err = resolver.Resolve(); err != nil {
+		t.Fatal(err.Error())
+	}
+	if !solved {
+		t.Fatal("Resolver was expected to succeed, but failed.")
+	}
+}
+
+func TestPresolve(t *testing.T) {
+	P := NewPackage
+
+	index := []Dependency{
+		{P("A", "1.0.0"), []Packages{{P("B", "1.0.0")}, {P("D", "1.0.0"), P("D", "2.0.0")}}},
+		{P("B", "1.0.0"), []Packages{{P("C", "1.0.0")}}},
+		{P("D", "2.0.0"), []Packages{{P("E", "1.0.0")}}},
+	}
+
+	resolver := NewResolver(Packages{P("A", "1.0.0")}, index)
+	before, err := resolver.buildFormula()
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	resolver.SetPresolve(true)
+	after, err := resolver.buildFormula()
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if len(after) != len(before) {
+		t.Errorf("Expected presolve to keep %d clauses, but got %d", len(before), len(after))
+	}
+
+	forced, _ := resolver.forcedIds(resolver.requires)
+	names := make([]string, len(forced))
+	for i, id := range forced {
+		names[i] = resolver.idMap.IdToString(id)
+	}
+	sort.Strings(names)
+	if strings.Join(names, ", ") != "B-1.0.0, C-1.0.0" {
+		t.Errorf("Expected B-1.0.0 and C-1.0.0 to be forced, but got %v", names)
+	}
+
+	solutions, err := resolver.AllSolutions(0)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if len(solutions) != 2 {
+		t.Errorf("Expected presolve to keep 2 valid solutions, but got %d", len(solutions))
+	}
+}
+
+func TestPresolveAssumptions(t *testing.T) {
+	P := NewPackage
+
+	index := []Dependency{
+		{P("A", "1.0"), []Packages{{P("B", "1.0")}}},
+		{P("B", "1.0"), nil},
+		{P("X", "1.0"), []Packages{{P("B", "2.0")}}},
+		{P("B", "2.0"), nil},
+	}
+
+	resolver := NewResolver(Packages{P("A", "1.0")}, index)
+	resolver.SetPresolve(true)
+	solver := resolver.solver
+
+	// Changing the requirements keeps the solver warm
+	resolver.Require(P("X", "1.0"))
+	if resolver.solver != solver {
+		t.Fatal("Expected Require() to keep the solver")
+	}
+	ok, err := resolver.Resolve()
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if ok {
+		t.Fatal("Resolver was expected to fail, but succeeded.")
+	}
+	conflicts := resolver.Conflicts()
+	sort.Sort(conflicts)
+	if conflicts.String() != "A-1.0, X-1.0" {
+		t.Errorf("Expected the requirements A-1.0 and X-1.0 to conflict, but got %s", conflicts)
+	}
+
+	// The forced Packages of a previous solve are not kept
+	ok, err = resolver.SolveIncremental(Packages{P("X", "1.0")})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if !ok {
+		t.Fatal("Resolver was expected to succeed, but failed.")
+	}
+}
+
+func benchmarkResolveChain(b *testing.B, presolve bool) {
+	index := benchIndex(200, 1)
+	resolver := NewResolver(Packages{NewPackage("P0", "0.0.0")}, index)
+	resolver.SetPresolve(presolve)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		solved, err := resolver.Resolve()
+		if err != nil {
+			b.Fatal(err.Error())
+		}
+		if !solved {
+			b.Fatal("Resolver was expected to succeed, but failed.")
+		}
+	}
+}
+
+func BenchmarkResolveChain(b *testing.B) {
+	benchmarkResolveChain(b, false)
+}
+
+func BenchmarkResolveChainPresolve(b *testing.B) {
+	benchmarkResolveChain(b, true)
+}
+
+func TestClonePreservesConstraints(t *testing.T) {
+	P := NewPackage
+
+	index := []Dependency{
+		{P("A", "1.0.0"), []Packages{{P("B", "1.0.0"), P("B", "2.0.0")}}},
+		{P("C", "1.0.0"), nil},
+		{P("C", "2.0.0"), nil},
+	}
+
+	resolver := NewResolver(Packages{P("A", "1.0.0")}, index)
+	if err := resolver.Forbid(P("B", "1.0.0")); err != nil {
+		t.Fatal(err.Error())
+	}
+	if err := resolver.Pin(P("C", "2.0.0")); err != nil {
+		t.Fatal(err.Error())
+	}
+	if err := resolver.Forbid(P("Q", "1.0.0")); err == nil {
+		t.Error("Expected an error when forbidding an unknown package")
+	}
+
+	clone, err := resolver.Clone()
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	for _, r := range []*Resolver{resolver, clone} {
+		solved, err := r.Resolve()
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		if !solved {
+			t.Fatal("Resolver was expected to succeed, but failed.")
+		}
+		vers, _ := r.SolutionMap()
+		if vers["B"] != "2.0.0" {
+			t.Errorf("Expected forbidden B-1.0.0 to be avoided, but got B-%s", vers["B"])
+		}
+		if vers["C"] != "2.0.0" {
+			t.Errorf("Expected pinned C-2.0.0 to be chosen, but got C-%q", vers["C"])
+		}
+	}
+
+	// The clone forbids the package as well
+	clone.RequireTemp(P("B", "1.0.0"))
+	solved, err := clone.Resolve()
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if solved {
+		t.Fatal("Clone was expected to fail with a forbidden package, but succeeded.")
+	}
+
+	// Further changes to the clone do not affect the original
+	if err = clone.Forbid(P("B", "2.0.0")); err != nil {
+		t.Fatal(err.Error())
+	}
+	if solved, err = resolver.Resolve(); err != nil {
+		t.Fatal(err.Error())
+	}
+	if !solved {
+		t.Fatal("Original Resolver was affected by changes to the clone.")
+	}
+
+	// A new index without the pinned and forbidden Packages skips both
+	if err = resolver.SetPackageIndex([]Dependency{{P("A", "1.0.0"), nil}}); err != nil {
+		t.Fatalf("Expected a missing pinned package to be skipped, but got: %s", err.Error())
+	}
+	if solved, err = resolver.Resolve(); err != nil {
+		t.Fatal(err.Error())
+	}
+	if !solved {
+		t.Fatal("Resolver was expected to succeed, but failed.")
+	}
+}
+
+func TestLayeredIndex(t *testing.T) {
+	P := NewPackage
+
+	base := []Dependency{
+		{P("A", "1.0"), []Packages{{P("B", "1.0")}}},
+		{P("B", "1.0"), []Packages{{P("C", "1.0")}}},
+	}
+	overlay := []Dependency{
+		{P("A", "1.0"), []Packages{{P("B", "2.0")}}},
+		{P("D", "1.0"), nil},
+	}
+
+	index := LayerIndexes(base, overlay)
+	names := make([]string, len(index))
+	for i, dep := range index {
+		names[i] = dep.Target.PackageName()
+	}
+	if strings.Join(names, ", ") != "A-1.0, B-1.0, D-1.0" {
+		t.Fatalf("Expected targets A-1.0, B-1.0, D-1.0 in the combined index, but got %v", names)
+	}
+
+	resolver := NewResolver(Packages{P("A", "1.0")}, nil)
+	resolver.SetLayeredIndex(base, overlay)
+
+	formula, err := dumpFormula(resolver)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	t.Log(formula)
+
+	a, _ := resolver.idMap.GetId("A-1.0")
+	b1, _ := resolver.idMap.GetId("B-1.0")
+	b2, _ := resolver.idMap.GetId("B-2.0")
+	if !strings.Contains(formula, fmt.Sprintf("\n%d %d 0\n", -a, b2)) {
+		t.Error("Expected the overlay requirement of A-1.0 on B-2.0 in the formula")
+	}
+	if strings.Contains(formula, fmt.Sprintf("\n%d %d 0\n", -a, b1)) {
+		t.Error("Expected the base requirement of A-1.0 on B-1.0 to be replaced")
+	}
+
+	solved, err := resolver.Resolve()
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if !solved {
+		t.Fatal("Resolver was expected to succeed, but failed.")
+	}
+	vers, _ := resolver.SolutionMap()
+	if vers["B"] != "2.0" {
+		t.Errorf("Expected B-2.0 from the overlay, but got B-%s", vers["B"])
+	}
+}
+
+func TestTransition(t *testing.T) {
+	P := NewPackage
+
+	index := []Dependency{
+		{P("A", "2.0"), []Packages{{P("B", "2.0")}}},
+		{P("B", "2.0"), []Packages{{P("C", "1.0")}}},
+		{P("B", "3.0"), nil},
+	}
+
+	installed := Packages{P("A", "1.0"), P("B", "2.0"), P("D", "1.0")}
+	resolver := NewResolver(Packages{P("A", "2.0")}, index)
+
+	install, remove, err := resolver.Transition(installed)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	sort.Sort(install)
+	sort.Sort(remove)
+
+	if install.String() != "A-2.0, C-1.0" {
+		t.Errorf("Expected to install A-2.0, C-1.0, but got %s", install)
+	}
+	if remove.String() != "A-1.0, D-1.0" {
+		t.Errorf("Expected to remove A-1.0, D-1.0, but got %s", remove)
+	}
+
+	resolver.SetRequirements(Packages{P("A", "2.0"), P("B", "3.0")})
+	if _, _, err = resolver.Transition(installed); err == nil {
+		t.Error("Expected an error when the requirements cannot be satisfied")
+	}
+}
+
+func TestSetTracing(t *testing.T) {
+	P := NewPackage
+
+	index := []Dependency{
+		{P("A", "1.0.0"), []Packages{{P("C", "1.0.0")}}},
+		{P("B", "1.0.0"), []Packages{{P("C", "2.0.0")}}},
+	}
+
+	resolver := NewResolver(Packages{P("A", "1.0.0"), P("B", "1.0.0")}, index)
+	resolver.SetTracing(false)
+
+	solved, err := resolver.Resolve()
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if solved {
+		t.Fatal("Resolver was expected to fail, but succeeded.")
+	}
+	if len(resolver.Conflicts()) != 2 {
+		t.Errorf("Expected 2 conflicts without tracing, but got %s", resolver.Conflicts())
+	}
+	if _, err = resolver.DetailedConflicts(); err == nil {
+		t.Error("Expected an error from DetailedConflicts with tracing disabled")
+	}
+
+	resolver.SetTracing(true)
+	if solved, err = resolver.Resolve(); err != nil {
+		t.Fatal(err.Error())
+	}
+	if solved {
+		t.Fatal("Resolver was expected to fail, but succeeded.")
+	}
+	detailed, err := resolver.DetailedConflicts()
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if len(detailed) == 0 {
+		t.Error("Expected detailed conflicts with tracing enabled")
+	}
+}
+
+func benchmarkResolveTracing(b *testing.B, tracing bool) {
+	index := benchIndex(100, 10)
+	resolver := NewResolver(Packages{NewPackage("P0", "0.0.0")}, index)
+	resolver.SetTracing(tracing)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		solved, err := resolver.Resolve()
+		if err != nil {
+			b.Fatal(err.Error())
+		}
+		if !solved {
+			b.Fatal("Resolver was expected to succeed, but failed.")
+		}
+	}
+}
+
+func BenchmarkResolveTracing(b *testing.B) {
+	benchmarkResolveTracing(b, true)
+}
+
+func BenchmarkResolveNoTracing(b *testing.B) {
+	benchmarkResolveTracing(b, false)
+}
+
+func TestPushPopAssumptions(t *testing.T) {
+	P := NewPackage
+
+	index := []Dependency{
+		{P("A", "1.0.0"), []Packages{{P("C", "1.0.0"), P("C", "2.0.0")}}},
+		{P("B", "1.0.0"), []Packages{{P("C", "1.0.0")}}},
+		{P("B", "2.0.0"), []Packages{{P("C", "2.0.0")}}},
+	}
+
+	resolver := NewResolver(Packages{P("A", "1.0.0")}, index)
+
+	resolve := func() map[string]string {
+		solved, err := resolver.Resolve()
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		if !solved {
+			return nil
+		}
+		vers, _ := resolver.SolutionMap()
+		return vers
+	}
+
+	resolver.PushAssumptions(Packages{P("B", "1.0.0")})
+	if vers := resolve(); vers == nil || vers["C"] != "1.0.0" {
+		t.Fatalf("Expected C-1.0.0 with B-1.0.0 assumed, but got %v", vers)
+	}
+
+	// Assumptions persist across solves until popped
+	if vers := resolve(); vers == nil || vers["B"] != "1.0.0" {
+		t.Fatalf("Expected B-1.0.0 to still be assumed, but got %v", vers)
+	}
+
+	resolver.PushAssumptions(Packages{P("C", "2.0.0")})
+	if vers := resolve(); vers != nil {
+		t.Fatalf("Expected B-1.0.0 and C-2.0.0 to conflict, but got %v", vers)
+	}
+
+	if popped := resolver.PopAssumptions(); popped.String() != "C-2.0.0" {
+		t.Errorf("Expected to pop C-2.0.0, but got %s", popped)
+	}
+	if popped := resolver.PopAssumptions(); popped.String() != "B-1.0.0" {
+		t.Errorf("Expected to pop B-1.0.0, but got %s", popped)
+	}
+	if popped := resolver.PopAssumptions(); popped != nil {
+		t.Errorf("Expected nil from an empty stack, but got %s", popped)
+	}
+
+	resolver.PushAssumptions(Packages{P("B", "2.0.0")})
+	if vers := resolve(); vers == nil || vers["C"] != "2.0.0" {
+		t.Fatalf("Expected C-2.0.0 with B-2.0.0 assumed, but got %v", vers)
+	}
+}
+
+func TestTables(t *testing.T) {
+	P := NewPackage
+
+	pkgs := Packages{P("A", "1.0.0"), P("long-product-name", "2.0"), P("B", "10.0.0-beta")}
+	expected := "" +
+		"PRODUCT            VERSION\n" +
+		"A                  1.0.0\n" +
+		"long-product-name  2.0\n" +
+		"B                  10.0.0-beta\n"
+	if actual := pkgs.Table(); actual != expected {
+		t.Errorf("Expected table:\n%s\nbut got:\n%s", expected, actual)
+	}
+
+	rels := PackageRelations{
+		{Packages{P("A", "1.0.0"), P("C", "1.0.0"), P("C", "2.0.0")}, Depends},
+		{Packages{P("C", "2.0.0"), P("C", "1.0.0")}, Conflicts},
+		{Packages{P("B", "1.0.0")}, Required},
+	}
+	expected = "" +
+		"RELATION   PACKAGE  WITH\n" +
+		"Depends    A-1.0.0  C-1.0.0, C-2.0.0\n" +
+		"Conflicts  C-2.0.0  C-1.0.0\n" +
+		"Required   B-1.0.0\n"
+	if actual := rels.Table(); actual != expected {
+		t.Errorf("Expected table:\n%s\nbut got:\n%s", expected, actual)
+	}
+}
+
+func TestPackageNameCollision(t *testing.T) {
+	P := NewPackage
+
+	// Both of these produce the PackageName "A-1-0.0"
+	a := P("A-1", "0.0")
+	b := P("A", "1-0.0")
+
+	prodMap := NewProductMap()
+	if err := prodMap.add(a); err != nil {
+		t.Fatal(err.Error())
+	}
+	if err := prodMap.add(P("A-1", "0.0")); err != nil {
+		t.Errorf("Expected no error adding an equal Package again, but got: %s", err.Error())
+	}
+	if err := prodMap.add(b); err == nil {
+		t.Error("Expected an error adding a colliding Package")
+	}
+
+	resolver := NewResolver(nil, []Dependency{{P("C", "1.0"), []Packages{{a}}}})
+	if err := resolver.AddDependency(Dependency{P("D", "1.0"), []Packages{{b}}}); err == nil {
+		t.Error("Expected an error adding a Dependency with a colliding Package")
+	}
+	if _, err := resolver.PackageByName("D-1.0"); err == nil {
+		t.Error("Expected a failed AddDependency to leave the Resolver unchanged")
+	}
+
+	bad := []Dependency{{P("C", "1.0"), []Packages{{a}, {b}}}}
+	if _, err := NewResolverE(nil, bad); err == nil {
+		t.Error("Expected an error creating a Resolver with colliding Packages")
+	}
+
+	// A rejected index leaves the previous index in place
+	if err := resolver.SetPackageIndex(bad); err == nil {
+		t.Error("Expected an error setting an index with colliding Packages")
+	}
+	resolver.SetRequirements(Packages{P("C", "1.0")})
+	if ok, err := resolver.Resolve(); err != nil || !ok {
+		t.Errorf("Expected the previous index to be kept, but got %v, %v", ok, err)
+	}
+}
+
+func TestAtMostK(t *testing.T) {
+	lits := []pigosat.Literal{1, 2, 3, 4, 5}
+	next := pigosat.Literal(len(lits))
+	newLit := func() pigosat.Literal {
+		next++
+		return next
+	}
+
+	for k := 0; k <= len(lits); k++ {
+		next = pigosat.Literal(len(lits))
+		formula := atMostK(lits, k, newLit)
+
+		// Check every assignment of the literals against the encoding
+		for mask := 0; mask < 1<<len(lits); mask++ {
+			solver, err := pigosat.New(nil)
+			if err != nil {
+				t.Fatal(err.Error())
+			}
+			solver.AddClauses(formula)
+
+			count := 0
+			for i, lit := range lits {
+				if mask&(1<<i) != 0 {
+					solver.Assume(lit)
+					count++
+				} else {
+					solver.Assume(-lit)
+				}
+			}
+			status, _ := solver.Solve()
+			if (status == pigosat.Satisfiable) != (count <= k) {
+				t.Fatalf("k=%d: assignment with %d true literals had status %v", k, count, status)
+			}
+			solver.Delete()
+		}
+	}
+}
+
+func TestResolveFewestProducts(t *testing.T) {
+	P := NewPackage
+
+	// The optional feature of A can be satisfied either by C, which
+	// needs a new product E, or by D, which reuses the product B
+	index := []Dependency{
+		{P("A", "1.0"), []Packages{{P("B", "1.0")}, {P("C", "1.0"), P("D", "1.0")}}},
+		{P("C", "1.0"), []Packages{{P("E", "1.0")}}},
+		{P("D", "1.0"), []Packages{{P("B", "1.0"), P("B", "2.0")}}},
+	}
+
+	resolver := NewResolver(Packages{P("A", "1.0")}, index)
+
+	solution, err := resolver.ResolveFewestProducts()
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	sort.Sort(solution)
+	if solution.String() != "A-1.0, B-1.0, D-1.0" {
+		t.Errorf("Expected solution A-1.0, B-1.0, D-1.0, but got %s", solution)
+	}
+
+	resolver.RequireTemp(P("B", "2.0"))
+	if _, err = resolver.ResolveFewestProducts(); err == nil {
+		t.Error("Expected an error when the requirements cannot be satisfied")
+	}
+}
+
+func TestWarnings(t *testing.T) {
+	P := NewPackage
+
+	index := []Dependency{
+		{P("A", "1.0"), []Packages{{P("B", "1.0"), P("B", "3.0")}}},
+		{P("A", "2.0"), []Packages{{P("B", "2.0")}}},
+		{P("B", "1.0"), nil},
+		{P("B", "2.0"), nil},
+	}
+
+	resolver := NewResolver(nil, index)
+	warnings := resolver.Warnings()
+	if len(warnings) != 1 {
+		t.Fatalf("Expected exactly 1 warning, but got %d: %v", len(warnings), warnings)
+	}
+	if !strings.Contains(warnings[0], "B-3.0 is required by A-1.0") {
+		t.Errorf("Expected a warning about the undefined B-3.0, but got %q", warnings[0])
+	}
+
+	index = append(index,
+		Dependency{P("C", "1.0"), []Packages{{P("A", "1.0"), P("A", "1.0")}}},
+		Dependency{P("B", "3.0"), nil},
+	)
+	resolver.SetPackageIndex(index)
+	warnings = resolver.Warnings()
+	if len(warnings) != 2 {
+		t.Fatalf("Expected 2 warnings, but got %d: %v", len(warnings), warnings)
+	}
+	if !strings.Contains(warnings[0], "lists A-1.0 more than once") {
+		t.Errorf("Expected a warning about the duplicate A-1.0, but got %q", warnings[0])
+	}
+	if !strings.Contains(warnings[1], "Product C has only one version") {
+		t.Errorf("Expected a warning about the single version of C, but got %q", warnings[1])
+	}
+}
+
+func TestWildcardRequirement(t *testing.T) {
+	P := NewPackage
+
+	index := []Dependency{
+		{P("A", "1.0"), []Packages{{P("B", "1.0")}}},
+		{P("A", "2.0"), []Packages{{P("B", "2.0")}}},
+		{P("B", "1.0"), nil},
+		{P("B", "2.0"), nil},
+		{P("C", "1.0"), nil},
+	}
+
+	wild, err := ParseRequirement("A-*")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if wild.ProductName() != "A" || wild.Version() != AnyVersion {
+		t.Fatalf("Expected to parse A-* as product A with any version, but got %s", wild)
+	}
+
+	for _, req := range []string{"B-2.0", "B-1.0"} {
+		other, _ := ParseRequirement(req)
+		resolver := NewResolver(Packages{wild, other}, index)
+
+		ok, err := resolver.Resolve()
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		if !ok {
+			t.Fatal("Resolver was expected to succeed, but failed.")
+		}
+
+		solution := resolver.Solution()
+		sort.Sort(solution)
+		expected := "A-" + other.Version() + ", " + req
+		if solution.String() != expected {
+			t.Errorf("Expected solution %s, but got %s", expected, solution)
+		}
+	}
+
+	// A wildcard requirement that cannot be satisfied
+	// is reported as the conflicting requirement
+	index = append(index, Dependency{P("C", "2.0"), []Packages{{P("B", "1.0")}, {P("B", "2.0")}}})
+	resolver := NewResolver(Packages{P("C", "*")}, index)
+	resolver.Forbid(P("C", "1.0"))
+	resolver.RequireTemp(wild)
+
+	ok, err := resolver.Resolve()
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if ok {
+		t.Fatal("Resolver was expected to fail, but succeeded.")
+	}
+
+	conflicts := resolver.Conflicts()
+	if len(conflicts) != 1 || conflicts[0].PackageName() != "C-*" {
+		t.Errorf("Expected the wildcard C-* to conflict, but got %s", conflicts)
+	}
+	if !resolver.IsPackageConflict(P("C", "*")) {
+		t.Error("Expected C-* to be reported as a package conflict")
+	}
+
+	if _, err := resolver.DetailedConflicts(); err != nil {
+		t.Errorf("Expected detailed conflicts for a wildcard requirement, but got: %s", err.Error())
+	}
+
+	for _, bad := range []string{"A", "-1.0", "A-"} {
+		if _, err := ParseRequirement(bad); err == nil {
+			t.Errorf("Expected an error parsing requirement %q", bad)
+		}
+	}
+}
+
+func TestCompareSolutions(t *testing.T) {
+	P := NewPackage
+
+	index := []Dependency{
+		{P("A", "1.0"), []Packages{{P("B", "1.0"), P("B", "2.0")}}},
+		{P("A", "2.0"), []Packages{{P("B", "1.0"), P("B", "2.0")}, {P("C", "1.0")}}},
+		{P("B", "1.0"), nil},
+		{P("B", "2.0"), nil},
+		{P("C", "1.0"), nil},
+		{P("D", "1.0"), nil},
+	}
+	reqs := Packages{P("A", "1.0"), P("D", "1.0")}
+
+	resolve := func(sortMode resolveSort, reqs Packages) Packages {
+		resolver := NewSortResolver(reqs, index, sortMode)
+		if ok, err := resolver.Resolve(); err != nil {
+			t.Fatal(err.Error())
+		} else if !ok {
+			t.Fatal("Resolver was expected to succeed, but failed.")
+		}
+		return resolver.Solution()
+	}
+
+	low := resolve(ResolveSortLow, reqs)
+	high := resolve(ResolveSortHigh, Packages{P("A", "2.0")})
+
+	cmp := CompareSolutions(low, high)
+	if cmp.Equal() {
+		t.Fatal("Expected the solutions to differ")
+	}
+	if len(cmp.Changed) != 2 {
+		t.Fatalf("Expected 2 changed products, but got %v", cmp.Changed)
+	}
+	for i, expected := range []string{"A 1.0 2.0", "B 1.0 2.0"} {
+		ch := cmp.Changed[i]
+		if actual := ch.Product + " " + ch.A.Version() + " " + ch.B.Version(); actual != expected {
+			t.Errorf("Expected change %q, but got %q", expected, actual)
+		}
+	}
+	if cmp.OnlyA.String() != "D-1.0" {
+		t.Errorf("Expected D-1.0 only in the first solution, but got %s", cmp.OnlyA)
+	}
+	if cmp.OnlyB.String() != "C-1.0" {
+		t.Errorf("Expected C-1.0 only in the second solution, but got %s", cmp.OnlyB)
+	}
+	if len(cmp.Same) != 0 {
+		t.Errorf("Expected no unchanged packages, but got %s", cmp.Same)
+	}
+
+	expected := "PRODUCT  A    B\nA        1.0  2.0\nB        1.0  2.0\nC        -    1.0\nD        1.0  -\n"
+	if cmp.String() != expected {
+		t.Errorf("Expected table:\n%s\nbut got:\n%s", expected, cmp.String())
+	}
+
+	if !CompareSolutions(low, resolve(ResolveSortLow, reqs)).Equal() {
+		t.Error("Expected the same solutions to compare as equal")
+	}
+}
+
+func TestNameFormatter(t *testing.T) {
+	P := NewPackage
+
+	orig := NameFormatter
+	defer func() { NameFormatter = orig }()
+
+	NameFormatter = func(product, version string) string {
+		return product + "==" + version
+	}
+
+	// Hyphens in the names can no longer collide
+	index := []Dependency{
+		{P("a-b", "1.0"), []Packages{{P("c", "1.0")}}},
+		{P("a", "b-1.0"), nil},
+		{P("c", "1.0"), nil},
+	}
+
+	resolver := NewResolver(Packages{P("a-b", "1.0"), P("a", "b-1.0")}, index)
+	ok, err := resolver.Resolve()
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if !ok {
+		t.Fatal("Resolver was expected to succeed, but failed.")
+	}
+
+	solution := resolver.Solution()
+	sort.Sort(solution)
+	if expected := "a-b==1.0, a==b-1.0, c==1.0"; solution.String() != expected {
+		t.Errorf("Expected solution %s, but got %s", expected, solution)
+	}
+}
+
+func TestFreeze(t *testing.T) {
+	P := NewPackage
+
+	index := []Dependency{
+		{P("A", "1.0"), []Packages{{P("B", "1.0"), P("B", "2.0")}}},
+		{P("A", "2.0"), []Packages{{P("B", "2.0")}, {P("C", "1.0"), P("C", "2.0")}}},
+		{P("B", "1.0"), nil},
+		{P("B", "2.0"), []Packages{{P("C", "1.0")}}},
+		{P("C", "1.0"), nil},
+		{P("C", "2.0"), nil},
+		{P("D", "1.0"), []Packages{{P("E", "1.0")}}},
+	}
+	reqs := Packages{P("A", "*"), P("B", "2.0")}
+
+	for _, sortMode := range []resolveSort{ResolveSortNone, ResolveSortLow, ResolveSortHigh} {
+		resolver := NewSortResolver(reqs, index, sortMode)
+		if err := resolver.Forbid(P("A", "2.0")); err != nil {
+			t.Fatal(err.Error())
+		}
+
+		var buf bytes.Buffer
+		if err := resolver.Freeze(&buf); err != nil {
+			t.Fatal(err.Error())
+		}
+		if !strings.HasPrefix(buf.String(), "pakr-frozen 2\n") {
+			t.Fatalf("Expected a version header, but got:\n%s", buf.String())
+		}
+
+		loaded, err := LoadFrozen(bytes.NewReader(buf.Bytes()))
+		if err != nil {
+			t.Fatalf("Failed to load frozen Resolver: %s\n%s", err.Error(), buf.String())
+		}
+
+		ok, err := resolver.Resolve()
+		if err != nil || !ok {
+			t.Fatalf("Resolver was expected to succeed, but failed. %v", err)
+		}
+		ok, err = loaded.Resolve()
+		if err != nil || !ok {
+			t.Fatalf("Resolver was expected to succeed, but failed. %v", err)
+		}
+		if expected, actual := resolver.Solution().String(), loaded.Solution().String(); actual != expected {
+			t.Errorf("Expected loaded solution %s, but got %s", expected, actual)
+		}
+
+		// Freezing the loaded Resolver gives the same artifact
+		var again bytes.Buffer
+		if err := loaded.Freeze(&again); err != nil {
+			t.Fatal(err.Error())
+		}
+		if again.String() != buf.String() {
+			t.Errorf("Expected a frozen Resolver to round-trip:\n%s\nbut got:\n%s", buf.String(), again.String())
+		}
+
+		if err := loaded.AddDependency(Dependency{P("F", "1.0"), nil}); err == nil {
+			t.Error("Expected an error adding a Dependency to a frozen Resolver")
+		}
+	}
+
+	for _, bad := range []string{"", "pakr-frozen 3\n", "not-frozen\n", "pakr-frozen 1\np cnf 1 2\n1 0\n"} {
+		if _, err := LoadFrozen(strings.NewReader(bad)); err == nil {
+			t.Errorf("Expected an error loading frozen Resolver %q", bad)
+		}
+	}
+}
+
+func TestExcludeProduct(t *testing.T) {
+	P := NewPackage
+
+	index := []Dependency{
+		{P("A", "1.0"), []Packages{{P("B", "1.0"), P("B", "2.0")}}},
+		{P("B", "1.0"), []Packages{{P("C", "1.0"), P("C", "2.0")}}},
+		{P("B", "2.0"), []Packages{{P("C", "2.0")}}},
+		{P("C", "1.0"), nil},
+		{P("C", "2.0"), nil},
+		{P("D", "1.0"), nil},
+	}
+
+	resolver := NewResolver(Packages{P("A", "1.0")}, index)
+	if err := resolver.ExcludeProduct("C"); err != nil {
+		t.Fatal(err.Error())
+	}
+	if err := resolver.ExcludeProduct("X"); err == nil {
+		t.Error("Expected an error excluding an unknown product")
+	}
+
+	ok, err := resolver.Resolve()
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if ok {
+		t.Fatal("Resolver was expected to fail, but succeeded.")
+	}
+
+	rels, err := resolver.DetailedConflicts()
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	restricted := make(map[string]bool)
+	for _, rel := range rels {
+		if rel.Relates == Restricts {
+			restricted[rel.Packages[0].PackageName()] = true
+		}
+	}
+	if !restricted["C-1.0"] || !restricted["C-2.0"] {
+		t.Errorf("Expected both versions of C to be restricted, but got:\n%s", rels)
+	}
+
+	// Unrelated requirements still solve, with the
+	// product still excluded after initializing again
+	resolver.SetRequirements(Packages{P("D", "1.0")})
+	ok, err = resolver.Resolve()
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if !ok {
+		t.Fatal("Resolver was expected to succeed, but failed.")
+	}
+	if actual := resolver.Solution().String(); actual != "D-1.0" {
+		t.Errorf("Expected solution D-1.0, but got %s", actual)
+	}
+}
+
+func TestSolveIncremental(t *testing.T) {
+	index := benchIndex(10, 3)
+	warm := NewResolver(nil, index)
+
+	for i := 0; i < 10; i++ {
+		reqs := Packages{NewPackage(fmt.Sprintf("P%d", i), fmt.Sprintf("%d.0.0", i%3))}
+		if i%4 == 3 {
+			// An unsatisfiable variation, between the satisfiable ones
+			reqs = append(reqs, NewPackage(fmt.Sprintf("P%d", i), "0.0.0"))
+		}
+
+		ok, err := warm.SolveIncremental(reqs)
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+
+		cold := NewResolver(reqs, index)
+		expected, err := cold.Resolve()
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+
+		if ok != expected {
+			t.Fatalf("Requirements %s: expected solved == %v, but got %v", reqs, expected, ok)
+		}
+		if ok && warm.Solution().String() != cold.Solution().String() {
+			t.Errorf("Requirements %s: expected solution %s, but got %s",
+				reqs, cold.Solution(), warm.Solution())
+		}
+	}
+}
+
+func benchmarkRelatedSolves(b *testing.B, incremental bool) {
+	index := benchIndex(200, 10)
+	reqs := make([]Packages, 50)
+	for i := range reqs {
+		reqs[i] = Packages{NewPackage(fmt.Sprintf("P%d", i*4), fmt.Sprintf("%d.0.0", i%10))}
+	}
+	resolver := NewResolver(nil, index)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		var (
+			ok  bool
+			err error
+		)
+		if incremental {
+			ok, err = resolver.SolveIncremental(reqs[i%len(reqs)])
+		} else {
+			resolver.SetRequirements(reqs[i%len(reqs)])
+			ok, err = resolver.Resolve()
+		}
+		if err != nil {
+			b.Fatal(err.Error())
+		}
+		if !ok {
+			b.Fatal("Resolver was expected to succeed, but failed.")
+		}
+	}
+}
+
+func BenchmarkSolveIncremental(b *testing.B) {
+	benchmarkRelatedSolves(b, true)
+}
+
+func BenchmarkSolveCold(b *testing.B) {
+	benchmarkRelatedSolves(b, false)
+}
+
+func TestSmallestConflict(t *testing.T) {
+	P := NewPackage
+
+	// A deep chain A -> B -> C -> D-1.0, which conflicts with
+	// the requirement of E on D-2.0
+	index := []Dependency{
+		{P("A", "1.0"), []Packages{{P("B", "1.0")}, {P("F", "1.0"), P("F", "2.0")}}},
+		{P("B", "1.0"), []Packages{{P("C", "1.0")}}},
+		{P("C", "1.0"), []Packages{{P("D", "1.0")}}},
+		{P("D", "1.0"), nil},
+		{P("D", "2.0"), nil},
+		{P("E", "1.0"), []Packages{{P("D", "2.0")}}},
+		{P("F", "1.0"), nil},
+		{P("F", "2.0"), nil},
+	}
+
+	resolver := NewResolver(Packages{P("A", "1.0"), P("E", "1.0")}, index)
+	ok, err := resolver.Resolve()
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if ok {
+		t.Fatal("Resolver was expected to fail, but succeeded.")
+	}
+
+	detailed, err := resolver.DetailedConflicts()
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	smallest, err := resolver.SmallestConflict()
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if len(smallest) == 0 || len(smallest) > len(detailed) {
+		t.Fatalf("Expected a non-empty conflict no larger than the detailed conflicts (%d), but got:\n%s",
+			len(detailed), smallest)
+	}
+
+	expected := []string{
+		"Package A-1.0 depends on one of (B-1.0)",
+		"Package B-1.0 depends on one of (C-1.0)",
+		"Package C-1.0 depends on one of (D-1.0)",
+		"Package E-1.0 depends on one of (D-2.0)",
+		"Package D-2.0 conflicts with (D-1.0)",
+	}
+	for _, line := range expected {
+		if !strings.Contains(smallest.String(), line) {
+			t.Errorf("Expected the conflict to contain %q, but got:\n%s", line, smallest)
+		}
+	}
+	if strings.Contains(smallest.String(), "F-") {
+		t.Errorf("Expected the unrelated F dependency to be removed, but got:\n%s", smallest)
+	}
+
+	resolver.SetTracing(false)
+	resolver.Resolve()
+	if _, err = resolver.SmallestConflict(); err == nil {
+		t.Error("Expected an error when tracing is disabled")
+	}
+}
+
+func TestMergeIndexes(t *testing.T) {
+	P := NewPackage
+
+	a := []Dependency{
+		{P("A", "1.0"), []Packages{{P("B", "1.0")}}},
+		{P("A", "1.0"), []Packages{{P("C", "1.0")}}},
+	}
+	b := []Dependency{{P("B", "1.0"), nil}}
+	c := []Dependency{{P("C", "1.0"), nil}, {P("A", "1.0"), nil}}
+
+	merged, err := MergeIndexes(a, b)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if len(merged) != 3 {
+		t.Errorf("Expected 3 merged dependencies, but got %d", len(merged))
+	}
+
+	_, err = MergeIndexes(a, b, c)
+	conflict, ok := err.(*IndexConflictError)
+	if !ok {
+		t.Fatalf("Expected an *IndexConflictError, but got %v", err)
+	}
+	if conflict.Package != "A-1.0" || conflict.First != 0 || conflict.Second != 2 {
+		t.Errorf("Expected A-1.0 to conflict between index 0 and 2, but got %+v", conflict)
+	}
+}
+
+func TestDuplicateRequirements(t *testing.T) {
+	P := NewPackage
+
+	index := []Dependency{
+		{P("A", "1.0"), []Packages{{P("B", "1.0")}}},
+		{P("B", "1.0"), nil},
+		{P("B", "2.0"), nil},
+	}
+
+	resolver := NewResolver(Packages{P("A", "1.0"), P("B", "2.0"), P("A", "1.0")}, index)
+	resolver.RequireTemp(P("B", "2.0"))
+
+	ok, err := resolver.Resolve()
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if ok {
+		t.Fatal("Resolver was expected to fail, but succeeded.")
+	}
+
+	conflicts := resolver.Conflicts()
+	sort.Sort(conflicts)
+	if expected := "A-1.0, B-2.0"; conflicts.String() != expected {
+		t.Errorf("Expected conflicts %s, but got %s", expected, conflicts)
+	}
+}
+
+func TestAllowMultipleVersions(t *testing.T) {
+	P := NewPackage
+
+	index := []Dependency{
+		{P("A", "1.0"), []Packages{{P("L", "1.0")}, {P("B", "1.0"), P("B", "2.0")}}},
+		{P("C", "1.0"), []Packages{{P("L", "2.0")}, {P("B", "2.0")}}},
+		{P("L", "1.0"), nil},
+		{P("L", "2.0"), nil},
+		{P("B", "1.0"), nil},
+		{P("B", "2.0"), nil},
+	}
+	reqs := Packages{P("A", "1.0"), P("C", "1.0")}
+
+	resolver := NewResolver(reqs, index)
+	ok, err := resolver.Resolve()
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if ok {
+		t.Fatal("Resolver was expected to fail, but succeeded.")
+	}
+
+	resolver.AllowMultipleVersions("L")
+	ok, err = resolver.Resolve()
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if !ok {
+		t.Fatal("Resolver was expected to succeed, but failed.")
+	}
+
+	if actual := resolver.SolutionVersions("L").String(); actual != "L-1.0, L-2.0" {
+		t.Errorf("Expected both versions of L, but got %s", actual)
+	}
+	if actual := resolver.SolutionVersions("B").String(); actual != "B-2.0" {
+		t.Errorf("Expected a single version of B, but got %s", actual)
+	}
+
+	// Other products still conflict
+	resolver.RequireTemp(P("B", "1.0"))
+	if ok, _ = resolver.Resolve(); ok {
+		t.Error("Expected multiple versions of B to conflict")
+	}
+
+	// Versions added incrementally are not conflicted either
+	if err = resolver.AddDependency(Dependency{P("L", "3.0"), nil}); err != nil {
+		t.Fatal(err.Error())
+	}
+	resolver.RequireTemp(P("L", "3.0"))
+	if ok, _ = resolver.Resolve(); !ok {
+		t.Fatal("Resolver was expected to succeed, but failed.")
+	}
+	if actual := resolver.SolutionVersions("L").String(); actual != "L-1.0, L-2.0, L-3.0" {
+		t.Errorf("Expected all versions of L, but got %s", actual)
+	}
+}
+
+func TestSuggestRelaxations(t *testing.T) {
+	P := NewPackage
+
+	index := []Dependency{
+		{P("A", "1.0"), []Packages{{P("C", "1.0")}}},
+		{P("B", "1.0"), []Packages{{P("C", "2.0")}}},
+		{P("B", "2.0"), []Packages{{P("C", "1.0"), P("C", "2.0")}}},
+		{P("C", "1.0"), nil},
+		{P("C", "2.0"), nil},
+		{P("F", "1.0"), []Packages{{P("D", "1.0")}}},
+		{P("D", "1.0"), nil},
+	}
+
+	resolver := NewResolver(Packages{P("A", "1.0"), P("B", "1.0")}, index)
+	if err := resolver.Forbid(P("D", "1.0")); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	ok, err := resolver.Resolve()
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if ok {
+		t.Fatal("Resolver was expected to fail, but succeeded.")
+	}
+
+	relaxations, err := resolver.SuggestRelaxations()
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	actual := make([]string, len(relaxations))
+	for i, relax := range relaxations {
+		actual[i] = relax.String()
+	}
+	expected := []string{
+		"Require B-2.0 instead of B-1.0",
+		"Drop the requirement A-1.0",
+		"Drop the requirement B-1.0",
+		"Allow multiple versions of product C",
+	}
+	if strings.Join(actual, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("Expected relaxations:\n%s\nbut got:\n%s", strings.Join(expected, "\n"), strings.Join(actual, "\n"))
+	}
+
+	// A permanent constraint can be relaxed too
+	resolver.SetRequirements(Packages{P("F", "1.0")})
+	if ok, _ = resolver.Resolve(); ok {
+		t.Fatal("Resolver was expected to fail, but succeeded.")
+	}
+	relaxations, err = resolver.SuggestRelaxations()
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if len(relaxations) != 2 || relaxations[0].Kind != DropRequirement || relaxations[1].Kind != AllowForbidden {
+		t.Errorf("Expected to drop F-1.0 or allow D-1.0, but got %v", relaxations)
+	}
+
+	resolver.SetRequirements(Packages{P("A", "1.0")})
+	resolver.Resolve()
+	if relaxations, _ = resolver.SuggestRelaxations(); len(relaxations) != 0 {
+		t.Errorf("Expected no relaxations for solved requirements, but got %v", relaxations)
+	}
+}
+
+func TestAtMostWeighted(t *testing.T) {
+	lits := []pigosat.Literal{1, 2, 3, 4}
+	weights := []int{3, 1, 0, 2}
+	next := pigosat.Literal(len(lits))
+	newLit := func() pigosat.Literal {
+		next++
+		return next
+	}
+
+	for k := 0; k <= 7; k++ {
+		next = pigosat.Literal(len(lits))
+		formula := atMostWeighted(lits, weights, k, newLit)
+
+		// Check every assignment of the literals against the encoding
+		for mask := 0; mask < 1<<len(lits); mask++ {
+			solver, err := pigosat.New(nil)
+			if err != nil {
+				t.Fatal(err.Error())
+			}
+			solver.AddClauses(formula)
+
+			sum := 0
+			for i, lit := range lits {
+				if mask&(1<<i) != 0 {
+					solver.Assume(lit)
+					sum += weights[i]
+				} else {
+					solver.Assume(-lit)
+				}
+			}
+			status, _ := solver.Solve()
+			if (status == pigosat.Satisfiable) != (sum <= k) {
+				t.Fatalf("k=%d: assignment with a sum of %d had status %v", k, sum, status)
+			}
+			solver.Delete()
+		}
+	}
+}
+
+func TestResolveCheapest(t *testing.T) {
+	P := NewPackage
+
+	index := []Dependency{
+		{P("A", "1.0"), []Packages{{P("C", "1.0"), P("B", "1.0")}}},
+		{P("B", "1.0"), []Packages{{P("D", "1.0")}}},
+		{P("C", "1.0"), nil},
+		{P("D", "1.0"), nil},
+	}
+
+	resolver := NewResolver(Packages{P("A", "1.0")}, index)
+	resolver.SetCost(P("C", "1.0"), 10)
+
+	// A, B and D cost 3 in total, and A and C cost 11
+	solution, err := resolver.ResolveCheapest()
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	sort.Sort(solution)
+	if expected := "A-1.0, B-1.0, D-1.0"; solution.String() != expected {
+		t.Errorf("Expected the cheapest solution %s, but got %s", expected, solution)
+	}
+
+	// A, B and D cost 60 in total, and A and C cost 30
+	resolver.SetBaseCost(20)
+	if solution, err = resolver.ResolveCheapest(); err != nil {
+		t.Fatal(err.Error())
+	}
+	sort.Sort(solution)
+	if expected := "A-1.0, C-1.0"; solution.String() != expected {
+		t.Errorf("Expected the cheapest solution %s, but got %s", expected, solution)
+	}
+
+	resolver.SetRequirements(Packages{P("A", "1.0")})
+	resolver.Forbid(P("C", "1.0"))
+	resolver.Forbid(P("B", "1.0"))
+	if _, err = resolver.ResolveCheapest(); err == nil {
+		t.Error("Expected an error for unsatisfiable requirements")
+	}
+}
+
+func TestLastTrace(t *testing.T) {
+	P := NewPackage
+
+	index := []Dependency{
+		{P("A", "1.0"), []Packages{{P("B", "1.0")}}},
+		{P("B", "1.0"), []Packages{{P("C", "1.0")}}},
+		{P("C", "1.0"), nil},
+		{P("C", "2.0"), nil},
+		{P("E", "1.0"), []Packages{{P("C", "2.0")}}},
+	}
+
+	resolver := NewResolver(Packages{P("A", "1.0")}, index)
+	if ok, _ := resolver.Resolve(); !ok {
+		t.Fatal("Resolver was expected to succeed, but failed.")
+	}
+	trace := resolver.LastTrace()
+	if len(trace) != 1 || trace[0].String() != "decide A-1.0 = true" {
+		t.Errorf("Expected only the assumed requirement in the trace, but got %v", trace)
+	}
+
+	resolver.RequireTemp(P("E", "1.0"))
+	if ok, _ := resolver.Resolve(); ok {
+		t.Fatal("Resolver was expected to fail, but succeeded.")
+	}
+
+	actual := make([]string, len(resolver.LastTrace()))
+	for i, step := range resolver.LastTrace() {
+		actual[i] = step.String()
+	}
+	if len(actual) < 2 || actual[0] != "decide A-1.0 = true" || actual[1] != "decide E-1.0 = true" {
+		t.Fatalf("Expected the trace to begin with the assumed requirements, but got %v", actual)
+	}
+	for _, expected := range []string{"propagate B-1.0 = true", "propagate C-1.0 = true"} {
+		found := false
+		for _, step := range actual {
+			found = found || step == expected
+		}
+		if !found {
+			t.Errorf("Expected the trace to contain %q, but got %v", expected, actual)
+		}
+	}
+}
+
+func TestAddExactlyN(t *testing.T) {
+	P := NewPackage
+
+	plugins := Packages{P("P1", "1.0"), P("P2", "1.0"), P("P3", "1.0"), P("P4", "1.0")}
+	index := []Dependency{
+		{P("A", "1.0"), nil},
+		{P("P1", "1.0"), nil},
+		{P("P2", "1.0"), nil},
+		{P("P3", "1.0"), nil},
+		{P("P4", "1.0"), nil},
+	}
+
+	resolver := NewResolver(Packages{P("A", "1.0")}, index)
+	if err := resolver.AddExactlyN(plugins, 2); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	countPlugins := func() int {
+		ok, err := resolver.Resolve()
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		if !ok {
+			t.Fatal("Resolver was expected to succeed, but failed.")
+		}
+		count := 0
+		for _, p := range resolver.Solution() {
+			if p == nil {
+				t.Fatal("Solution contains a nil Package")
+			}
+			if strings.HasPrefix(p.ProductName(), "P") {
+				count++
+			}
+		}
+		return count
+	}
+
+	if count := countPlugins(); count != 2 {
+		t.Errorf("Expected exactly 2 plugins in the solution, but got %d: %s", count, resolver.Solution())
+	}
+
+	// Requiring a third plugin cannot be satisfied
+	resolver.RequireTemp(P("P1", "1.0"))
+	resolver.RequireTemp(P("P2", "1.0"))
+	resolver.RequireTemp(P("P3", "1.0"))
+	if ok, _ := resolver.Resolve(); ok {
+		t.Error("Expected more than 2 plugins to fail")
+	}
+
+	// The constraint survives initializing again
+	resolver.SetRequirements(Packages{P("P4", "1.0")})
+	if count := countPlugins(); count != 2 {
+		t.Errorf("Expected exactly 2 plugins in the solution, but got %d: %s", count, resolver.Solution())
+	}
+
+	if err := resolver.AddExactlyN(plugins, 5); err == nil {
+		t.Error("Expected an error requiring more packages than the group has")
+	}
+	if err := resolver.AddExactlyN(Packages{P("Q", "1.0")}, 1); err == nil {
+		t.Error("Expected an error for an unknown package")
+	}
+}
+
+func TestAddExactlyNConflicts(t *testing.T) {
+	P := NewPackage
+
+	index := []Dependency{
+		{P("P1", "1.0"), nil},
+		{P("P2", "1.0"), nil},
+		{P("P3", "1.0"), nil},
+	}
+
+	resolver := NewResolver(Packages{P("P1", "1.0"), P("P2", "1.0")}, index)
+	if err := resolver.AddExactlyN(Packages{P("P1", "1.0"), P("P2", "1.0"), P("P3", "1.0")}, 1); err != nil {
+		t.Fatal(err.Error())
+	}
+	if ok, _ := resolver.Resolve(); ok {
+		t.Fatal("Resolver was expected to fail, but succeeded.")
+	}
+
+	// The auxiliary literals of the encoding are left out of the report
+	rels, err := resolver.DetailedConflicts()
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	for _, rel := range rels {
+		for _, p := range rel.Packages {
+			if p == nil {
+				t.Fatalf("Expected only Packages in the conflicts, but got:\n%s", rels)
+			}
+		}
+	}
+}
+
+// A Packager for the same version of a product built for
+// different platforms, which differ only by their PackageName
+type platformPackage struct {
+	product, version, platform string
+}
+
+func (p platformPackage) ProductName() string { return p.product }
+func (p platformPackage) Version() string     { return p.version }
+func (p platformPackage) PackageName() string {
+	return p.product + "-" + p.version + "-" + p.platform
+}
+
+func TestSortTieBreak(t *testing.T) {
+	P := NewPackage
+
+	// The same PackageName, told apart only by the key
+	linux := keyedPackage{P("B", "1.0"), "linux"}
+	darwin := keyedPackage{P("B", "1.0"), "darwin"}
+
+	for _, sortMode := range []resolveSort{ResolveSortLow, ResolveSortHigh} {
+		var first string
+		for i := 0; i < 20; i++ {
+			// Vary the order of the index, to vary the
+			// order in which the Packages are flattened
+			index := []Dependency{
+				{P("A", "1.0"), []Packages{{linux, darwin}}},
+				{linux, nil},
+				{darwin, nil},
+			}
+			if i%2 == 1 {
+				index = []Dependency{
+					{P("A", "1.0"), []Packages{{darwin, linux}}},
+					{darwin, nil},
+					{linux, nil},
+				}
+			}
+
+			resolver := NewSortResolver(Packages{P("A", "1.0")}, index, sortMode)
+			if ok, err := resolver.Resolve(); err != nil || !ok {
+				t.Fatalf("Resolver was expected to succeed, but failed. %v", err)
+			}
+			chosen := resolver.SolutionVersions("B")
+			if len(chosen) != 1 {
+				t.Fatalf("Expected a single version of B, but got %s", chosen)
+			}
+
+			if i == 0 {
+				first = packageKey(chosen[0])
+			} else if packageKey(chosen[0]) != first {
+				t.Fatalf("Sort mode %d: expected %s to be preferred on every run, but got %s",
+					sortMode, first, packageKey(chosen[0]))
+			}
+		}
+	}
+
+	// The primary order is the PackageName, as with Packages.Less()
+	pkgs := Packages{P("A", "1.9"), P("A", "1.10")}
+	if compareSortOrder(pkgs[0], pkgs[1]) <= 0 || pkgs.Less(0, 1) {
+		t.Error("Expected the sort order to follow Packages.Less()")
+	}
+	if compareSortOrder(linux, darwin) <= 0 || compareSortOrder(linux, linux) != 0 {
+		t.Error("Expected Packages with the same name to be ordered by their keys")
+	}
+}
+
+// An IndexSource that generates its Dependencies on demand
+type generatedIndexSource struct {
+	products int
+	calls    int
+}
+
+func (s *generatedIndexSource) Dependencies() iter.Seq[Dependency] {
+	s.calls++
+	return func(yield func(Dependency) bool) {
+		for i := 0; i < s.products; i++ {
+			for _, ver := range []string{"1.0", "2.0"} {
+				dep := Dependency{Target: NewPackage(fmt.Sprintf("P%d", i), ver)}
+				if i+1 < s.products {
+					dep.Requires = []Packages{{NewPackage(fmt.Sprintf("P%d", i+1), ver)}}
+				}
+				if !yield(dep) {
+					return
+				}
+			}
+		}
+	}
+}
+
+func TestIndexSource(t *testing.T) {
+	P := NewPackage
+
+	source := &generatedIndexSource{products: 5}
+	resolver := NewResolverFromSource(Packages{P("P0", "2.0")}, source)
+	if source.calls == 0 {
+		t.Fatal("Expected the IndexSource to be read by Initialize")
+	}
+
+	ok, err := resolver.Resolve()
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if !ok {
+		t.Fatal("Resolver was expected to succeed, but failed.")
+	}
+
+	var index []Dependency
+	for dep := range source.Dependencies() {
+		index = append(index, dep)
+	}
+	expected := NewResolver(Packages{P("P0", "2.0")}, index)
+	if _, err := expected.Resolve(); err != nil {
+		t.Fatal(err.Error())
+	}
+	if !resolver.Solution().Equal(expected.Solution()) {
+		t.Fatalf("Expected solution %s, but got %s", expected.Solution(), resolver.Solution())
+	}
+
+	// A SliceIndexSource behaves like the original slice
+	resolver.SetIndexSource(SliceIndexSource(index))
+	resolver.SetRequirements(Packages{P("P0", "1.0"), P("P4", "2.0")})
+	if ok, _ := resolver.Resolve(); ok {
+		t.Fatal("Resolver was expected to fail, but succeeded.")
+	}
+
+	// Dependencies added to a source Resolver extend the source
+	resolver = NewResolverFromSource(Packages{P("P4", "1.0")}, &generatedIndexSource{products: 5})
+	if err := resolver.AddDependency(Dependency{P("P4", "1.0"), []Packages{{P("Q", "1.0")}}}); err != nil {
+		t.Fatal(err.Error())
+	}
+	if err := resolver.Forbid(P("Q", "1.0")); err != nil {
+		t.Fatal(err.Error())
+	}
+	// Re-initializing keeps the added Dependency
+	if err := resolver.Initialize(); err != nil {
+		t.Fatal(err.Error())
+	}
+	if ok, _ := resolver.Resolve(); ok {
+		t.Fatal("Resolver was expected to fail, but succeeded.")
+	}
+}
+
+func TestDeadPackages(t *testing.T) {
+	P := NewPackage
+
+	index := []Dependency{
+		// B-1.0 and C-1.0 both need a different version of D
+		{P("A", "1.0"), []Packages{{P("B", "1.0")}, {P("C", "1.0")}}},
+		{P("A", "2.0"), []Packages{{P("B", "1.0")}}},
+		{P("B", "1.0"), []Packages{{P("D", "1.0")}}},
+		{P("C", "1.0"), []Packages{{P("D", "2.0")}}},
+		{P("D", "1.0"), nil},
+		{P("D", "2.0"), nil},
+		// E-1.0 is dead, because it needs A-1.0
+		{P("E", "1.0"), []Packages{{P("A", "1.0")}}},
+	}
+
+	dead, err := DeadPackages(index)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if dead.String() != "A-1.0, E-1.0" {
+		t.Fatalf("Expected dead packages A-1.0, E-1.0, but got %s", dead)
+	}
+
+	explained, err := ExplainDeadPackages(index)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	for _, d := range explained {
+		if len(d.Conflicts) == 0 {
+			t.Errorf("Expected the dead package %s to report its conflicts", d.Package)
+		}
+	}
+
+	if dead, err = DeadPackages(index[1:6]); err != nil {
+		t.Fatal(err.Error())
+	} else if len(dead) != 0 {
+		t.Errorf("Expected no dead packages, but got %s", dead)
+	}
+
+	// An invalid index is an error
+	invalid := []Dependency{{P("A-1", "0.0"), nil}, {P("A", "1-0.0"), nil}}
+	if _, err = DeadPackages(invalid); err == nil {
+		t.Error("Expected an error for an invalid index")
+	}
+}
+
+func TestResolveWithHint(t *testing.T) {
+	P := NewPackage
+
+	index := []Dependency{
+		{P("A", "1.0"), []Packages{{P("B", "1.0"), P("B", "2.0")}}},
+		{P("A", "2.0"), []Packages{{P("B", "2.0"), P("B", "3.0")}}},
+		{P("B", "1.0"), nil},
+		{P("B", "2.0"), nil},
+		{P("B", "3.0"), nil},
+	}
+	resolver := NewSortResolver(Packages{P("A", "2.0")}, index, ResolveSortHigh)
+
+	// A valid hint is solved unchanged
+	hint := Packages{P("A", "2.0"), P("B", "2.0")}
+	ok, solution, err := resolver.ResolveWithHint(hint)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if !ok {
+		t.Fatal("Resolver was expected to succeed, but failed.")
+	}
+	if c := CompareSolutions(hint, solution); !c.Equal() {
+		t.Fatalf("Expected the hint to be solved unchanged:\n%s", c)
+	}
+
+	// An invalid hint is repaired, keeping what it can
+	hint = Packages{P("A", "1.0"), P("B", "2.0")}
+	ok, solution, err = resolver.ResolveWithHint(hint)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if !ok {
+		t.Fatal("Resolver was expected to succeed, but failed.")
+	}
+	vers, err := resolver.SolutionMap()
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if vers["A"] != "2.0" || vers["B"] != "2.0" {
+		t.Fatalf("Expected the repaired solution A-2.0, B-2.0, but got %s", solution)
+	}
+
+	// Unsolvable requirements still report their conflicts
+	resolver.SetRequirements(Packages{P("A", "2.0"), P("B", "1.0")})
+	ok, _, err = resolver.ResolveWithHint(hint)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if ok {
+		t.Fatal("Resolver was expected to fail, but succeeded.")
+	}
+	if len(resolver.Conflicts()) == 0 {
+		t.Fatal("Expected the failed resolve to report conflicts")
+	}
+}
+
+func TestCanCoexist(t *testing.T) {
+	P := NewPackage
+
+	index := []Dependency{
+		{P("A", "1.0"), []Packages{{P("C", "1.0")}}},
+		{P("A", "2.0"), nil},
+		{P("B", "1.0"), []Packages{{P("C", "2.0")}}},
+		{P("B", "2.0"), []Packages{{P("C", "1.0")}}},
+		{P("C", "1.0"), nil},
+		{P("C", "2.0"), nil},
+	}
+	resolver := NewResolver(Packages{P("A", "1.0"), P("B", "1.0")}, index)
+	if ok, _ := resolver.Resolve(); ok {
+		t.Fatal("Resolver was expected to fail, but succeeded.")
+	}
+
+	tests := []struct {
+		a, b     *Package
+		expected bool
+	}{
+		{P("A", "1.0"), P("A", "2.0"), false},
+		{P("A", "1.0"), P("B", "1.0"), false},
+		{P("A", "1.0"), P("B", "2.0"), true},
+		{P("A", "2.0"), P("B", "1.0"), true},
+	}
+	for _, test := range tests {
+		ok, err := resolver.CanCoexist(test.a, test.b)
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		if ok != test.expected {
+			t.Errorf("Expected CanCoexist(%s, %s) to be %v", test.a, test.b, test.expected)
+		}
+	}
+
+	// The failed resolve is unchanged
+	if resolver.Solved() || !resolver.IsPackageConflict(P("B", "1.0")) {
+		t.Error("Expected the state of the last resolve to be restored")
+	}
+
+	if _, err := resolver.CanCoexist(P("A", "1.0"), P("D", "1.0")); err == nil {
+		t.Error("Expected an error for an unknown package")
+	}
+
+	// The check does not add anything to the solver of the Resolver
+	resolver.SetPresolve(true)
+	clauses := resolver.solver.AddedOriginalClauses()
+	if _, err := resolver.CanCoexist(P("A", "2.0"), P("B", "1.0")); err != nil {
+		t.Fatal(err.Error())
+	}
+	if n := resolver.solver.AddedOriginalClauses(); n != clauses {
+		t.Errorf("Expected CanCoexist to keep %d clauses in the solver, but got %d", clauses, n)
+	}
+	if resolver.solver.Res() != pigosat.Unknown {
+		t.Error("Expected CanCoexist to leave a fresh solver unsolved")
+	}
+}
+
+func TestPackagesNamesProducts(t *testing.T) {
+	P := NewPackage
+
+	pkgs := Packages{P("B", "1.0"), P("A", "2.0"), P("B", "2.0")}
+	if names := pkgs.Names(); strings.Join(names, " ") != "B-1.0 A-2.0 B-2.0" {
+		t.Errorf("Expected names in order, but got %v", names)
+	}
+	if products := pkgs.Products(); strings.Join(products, " ") != "A B" {
+		t.Errorf("Expected unique sorted products, but got %v", products)
+	}
+
+	var empty Packages
+	if len(empty.Names()) != 0 || len(empty.Products()) != 0 {
+		t.Error("Expected no names or products for nil Packages")
+	}
+}
+
+func TestMaximalSatisfiableSubset(t *testing.T) {
+	P := NewPackage
+
+	index := []Dependency{
+		{P("A", "1.0"), []Packages{{P("D", "1.0")}}},
+		{P("B", "1.0"), []Packages{{P("D", "1.0")}}},
+		{P("C", "1.0"), []Packages{{P("D", "2.0")}}},
+		{P("D", "1.0"), nil},
+		{P("D", "2.0"), nil},
+	}
+	requires := Packages{P("A", "1.0"), P("C", "1.0"), P("B", "1.0")}
+	resolver := NewResolver(requires, index)
+	if ok, _ := resolver.Resolve(); ok {
+		t.Fatal("Resolver was expected to fail, but succeeded.")
+	}
+
+	subset, err := resolver.MaximalSatisfiableSubset()
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if subset.String() != "A-1.0, B-1.0" {
+		t.Fatalf("Expected the subset A-1.0, B-1.0, but got %s", subset)
+	}
+
+	// The requirements are unchanged
+	if ok, _ := resolver.Resolve(); ok {
+		t.Fatal("Resolver was expected to fail, but succeeded.")
+	}
+
+	resolver.SetRequirements(Packages{P("A", "1.0"), P("B", "1.0"), P("A", "1.0")})
+	subset, err = resolver.MaximalSatisfiableSubset()
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if subset.String() != "A-1.0, B-1.0" {
+		t.Fatalf("Expected every requirement to be kept once, but got %s", subset)
+	}
+}
+
+func TestMaximalOptionalSubset(t *testing.T) {
+	P := NewPackage
+
+	// Keeping B-1.0, the first optional Package, would drop C-1.0 and D-1.0
+	index := []Dependency{
+		{P("A", "1.0"), nil},
+		{P("B", "1.0"), []Packages{{P("E", "1.0")}}},
+		{P("C", "1.0"), []Packages{{P("E", "2.0")}}},
+		{P("D", "1.0"), []Packages{{P("E", "2.0")}}},
+		{P("E", "1.0"), nil},
+		{P("E", "2.0"), nil},
+		{P("F", "1.0"), []Packages{{P("E", "1.0")}}},
+	}
+	resolver := NewResolver(Packages{P("A", "1.0")}, index)
+
+	optional := Packages{P("B", "1.0"), P("C", "1.0"), P("D", "1.0"), P("C", "1.0")}
+	subset, err := resolver.MaximalOptionalSubset(optional)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if subset.String() != "C-1.0, D-1.0" {
+		t.Fatalf("Expected the subset C-1.0, D-1.0, but got %s", subset)
+	}
+
+	// The requirements are always kept
+	resolver.SetRequirements(Packages{P("F", "1.0")})
+	if subset, err = resolver.MaximalOptionalSubset(optional); err != nil {
+		t.Fatal(err.Error())
+	}
+	if subset.String() != "B-1.0" {
+		t.Fatalf("Expected the subset B-1.0, but got %s", subset)
+	}
+
+	resolver.SetRequirements(Packages{P("B", "1.0"), P("C", "1.0")})
+	if _, err = resolver.MaximalOptionalSubset(optional); err == nil {
+		t.Fatal("Expected an error for requirements that cannot be satisfied")
+	}
+}
+
+func TestIndexStats(t *testing.T) {
+	stats := IndexStats(sampleIndex())
+
+	if stats.Products != 9 || stats.Packages != 15 || stats.Dependencies != 5 || stats.Edges != 16 {
+		t.Errorf("Expected 9 products, 15 packages, 5 dependencies and 16 edges, but got:\n%s", stats)
+	}
+	if stats.MaxVersionsProduct != "B" || stats.MaxVersions != 3 {
+		t.Errorf("Expected B to have the most versions (3), but got %s (%d)",
+			stats.MaxVersionsProduct, stats.MaxVersions)
+	}
+	if stats.ConflictPairs != 8 {
+		t.Errorf("Expected 8 conflict pairs, but got %d", stats.ConflictPairs)
+	}
+	if stats.VersionCounts[1] != 5 || stats.VersionCounts[2] != 2 || stats.VersionCounts[3] != 2 {
+		t.Errorf("Unexpected versions per product: %v", stats.VersionCounts)
+	}
+	if !strings.Contains(stats.String(), "Most versions: B (3)") {
+		t.Errorf("Unexpected summary:\n%s", stats)
+	}
+
+	if empty := IndexStats(nil); empty.Products != 0 || empty.MaxVersions != 0 {
+		t.Errorf("Expected empty statistics, but got:\n%s", empty)
+	}
+}
+
+func TestRequire(t *testing.T) {
+	P := NewPackage
+
+	index := []Dependency{
+		{P("A", "1.0"), nil},
+		{P("X", "1.0"), []Packages{{P("Y", "1.0")}}},
+		{P("Y", "1.0"), nil},
+	}
+
+	for _, presolve := range []bool{false, true} {
+		resolver := NewResolver(Packages{P("A", "1.0")}, index)
+		resolver.SetPresolve(presolve)
+		resolver.Require(P("X", "1.0"))
+
+		for i := 0; i < 2; i++ {
+			ok, err := resolver.Resolve()
+			if err != nil {
+				t.Fatal(err.Error())
+			}
+			if !ok {
+				t.Fatal("Resolver was expected to succeed, but failed.")
+			}
+			if vers, _ := resolver.SolutionMap(); vers["X"] != "1.0" || vers["Y"] != "1.0" {
+				t.Fatalf("Resolve %d: expected X-1.0 and Y-1.0 in the solution, but got %s",
+					i+1, resolver.Solution())
+			}
+		}
+	}
+}
+
+func TestRelationTemplates(t *testing.T) {
+	P := NewPackage
+
+	rel := &PackageRelation{Packages{P("A", "1.0"), P("B", "1.0"), P("B", "2.0")}, Conflicts}
+	if s := rel.String(); s != "Package A-1.0 conflicts with (B-1.0, B-2.0)" {
+		t.Fatalf("Unexpected default phrase: %q", s)
+	}
+
+	orig := RelationTemplates[Conflicts]
+	defer func() { RelationTemplates[Conflicts] = orig }()
+
+	RelationTemplates[Conflicts] = "{{.Package}} steht im Konflikt mit {{.Others}}"
+	if s := rel.String(); s != "A-1.0 steht im Konflikt mit B-1.0, B-2.0" {
+		t.Errorf("Unexpected custom phrase: %q", s)
+	}
+
+	// The template is only parsed once
+	cached, ok := relationTemplateCache.Load(RelationTemplates[Conflicts])
+	if !ok {
+		t.Fatal("Expected the custom template to be cached")
+	}
+	if s := rel.String(); s != "A-1.0 steht im Konflikt mit B-1.0, B-2.0" {
+		t.Errorf("Unexpected custom phrase: %q", s)
+	}
+	if again, _ := relationTemplateCache.Load(RelationTemplates[Conflicts]); again.(parsedRelationTemplate).tmpl != cached.(parsedRelationTemplate).tmpl {
+		t.Error("Expected the cached template to be reused")
+	}
+
+	// Other relations keep their defaults
+	dep := &PackageRelation{Packages{P("A", "1.0"), P("C", "1.0")}, Depends}
+	if s := dep.String(); s != "Package A-1.0 depends on one of (C-1.0)" {
+		t.Errorf("Unexpected default phrase: %q", s)
+	}
+
+	// An invalid template falls back to the default
+	RelationTemplates[Conflicts] = "{{.Missing"
+	if s := rel.String(); s != "Package A-1.0 conflicts with (B-1.0, B-2.0)" {
+		t.Errorf("Expected the default phrase for an invalid template, but got %q", s)
+	}
+}
+
+func TestQuickConflictCheck(t *testing.T) {
+	P := NewPackage
+
+	index := []Dependency{
+		{P("A", "1.0"), nil},
+		{P("A", "2.0"), nil},
+		{P("B", "1.0"), nil},
+		{P("C", "1.0"), nil},
+	}
+
+	resolver := NewResolver(Packages{P("A", "1.0"), P("B", "1.0"), P("A", "2.0")}, index)
+	found, pkgs := resolver.QuickConflictCheck()
+	if !found || pkgs.String() != "A-1.0, A-2.0" {
+		t.Fatalf("Expected a conflict between A-1.0, A-2.0, but got %v (%s)", found, pkgs)
+	}
+	if ok, _ := resolver.Resolve(); ok {
+		t.Fatal("Resolver was expected to fail, but succeeded.")
+	}
+
+	// Versions that are allowed together are not a conflict
+	resolver.AllowMultipleVersions("A")
+	if found, pkgs := resolver.QuickConflictCheck(); found {
+		t.Fatalf("Expected no conflict, but got %s", pkgs)
+	}
+
+	resolver = NewResolver(Packages{P("B", "1.0"), P("C", "1.0"), P("B", "1.0")}, index)
+	if found, pkgs := resolver.QuickConflictCheck(); found {
+		t.Fatalf("Expected no conflict, but got %s", pkgs)
+	}
+	if err := resolver.Forbid(P("C", "1.0")); err != nil {
+		t.Fatal(err.Error())
+	}
+	found, pkgs = resolver.QuickConflictCheck()
+	if !found || pkgs.String() != "C-1.0" {
+		t.Fatalf("Expected the forbidden package C-1.0, but got %v (%s)", found, pkgs)
+	}
+}
+
+func TestIndexFingerprint(t *testing.T) {
+	P := NewPackage
+
+	index := sampleIndex()
+	fingerprint := NewResolver(nil, index).IndexFingerprint()
+
+	// Reverse the Dependencies, and the order of their groups and members
+	reordered := make([]Dependency, len(index))
+	for i, dep := range index {
+		groups := make([]Packages, len(dep.Requires))
+		for j, vers := range dep.Requires {
+			members := append(Packages(nil), vers...)
+			sort.Sort(sort.Reverse(members))
+			groups[len(groups)-1-j] = members
+		}
+		reordered[len(index)-1-i] = Dependency{dep.Target, groups}
+	}
+	if fp := NewResolver(nil, reordered).IndexFingerprint(); fp != fingerprint {
+		t.Errorf("Expected a reordered index to have the same fingerprint, %s != %s", fp, fingerprint)
+	}
+
+	modified := append(sampleIndex()[:4], Dependency{P("Z", "1.0.1"), nil})
+	if fp := NewResolver(nil, modified).IndexFingerprint(); fp == fingerprint {
+		t.Error("Expected a modified version to change the fingerprint")
+	}
+
+	modified = sampleIndex()
+	modified[3].Requires = modified[3].Requires[:2]
+	if fp := NewResolver(nil, modified).IndexFingerprint(); fp == fingerprint {
+		t.Error("Expected a modified dependency to change the fingerprint")
+	}
+
+	// The fingerprint is of the index as resolved, with its replacements
+	replaced := NewResolver(nil, sampleIndex())
+	if err := replaced.AddReplacement(P("B", "1.2.3"), P("B", "1.2.5")); err != nil {
+		t.Fatal(err.Error())
+	}
+	if fp := replaced.IndexFingerprint(); fp == fingerprint {
+		t.Error("Expected a replacement to change the fingerprint")
+	}
+}
+
+func TestIgnoreDependency(t *testing.T) {
+	P := NewPackage
+
+	index := []Dependency{
+		{P("A", "1.0"), []Packages{{P("B", "1.0")}, {P("C", "1.0")}}},
+		{P("B", "1.0"), nil},
+		{P("C", "1.0"), nil},
+		{P("C", "2.0"), nil},
+	}
+	resolver := NewResolver(Packages{P("A", "1.0"), P("C", "2.0")}, index)
+	if ok, _ := resolver.Resolve(); ok {
+		t.Fatal("Resolver was expected to fail, but succeeded.")
+	}
+
+	resolver.IgnoreDependency("A", "C")
+	ok, err := resolver.Resolve()
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if !ok {
+		t.Fatal("Resolver was expected to succeed, but failed.")
+	}
+	// The other dependencies of A are kept
+	if vers, _ := resolver.SolutionMap(); vers["B"] != "1.0" || vers["C"] != "2.0" {
+		t.Fatalf("Expected B-1.0 and C-2.0 in the solution, but got %s", resolver.Solution())
+	}
+
+	resolver.ClearIgnored()
+	if ok, _ := resolver.Resolve(); ok {
+		t.Fatal("Resolver was expected to fail, but succeeded.")
+	}
+
+	// A PackageName only ignores the dependency of that version
+	resolver.IgnoreDependency("A-2.0", "C")
+	if ok, _ := resolver.Resolve(); ok {
+		t.Fatal("Resolver was expected to fail, but succeeded.")
+	}
+	resolver.IgnoreDependency("A-1.0", "C")
+	if ok, _ := resolver.Resolve(); !ok {
+		t.Fatal("Resolver was expected to succeed, but failed.")
+	}
+}
+
+func TestParsePackage(t *testing.T) {
+	tests := []struct{ s, product, version string }{
+		{"A-1.0", "A", "1.0"},
+		{"my-lib-1.2.3", "my-lib", "1.2.3"},
+		{"a-b-c-2", "a-b-c", "2"},
+	}
+	for _, test := range tests {
+		p, err := ParsePackage(test.s)
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		if p.ProductName() != test.product || p.Version() != test.version || p.PackageName() != test.s {
+			t.Errorf("Expected %q to parse to product %q, version %q, but got %q, %q",
+				test.s, test.product, test.version, p.ProductName(), p.Version())
+		}
+	}
+
+	for _, bad := range []string{"", "A", "A-", "-1.0", "A-*"} {
+		if _, err := ParsePackage(bad); err == nil {
+			t.Errorf("Expected an error parsing %q", bad)
+		}
+	}
+
+	pkgs, err := ParsePackages([]string{"my-lib-1.2.3", "B-2.0"})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if pkgs.String() != "my-lib-1.2.3, B-2.0" {
+		t.Errorf("Unexpected packages: %s", pkgs)
+	}
+	if _, err := ParsePackages([]string{"B-2.0", "bad"}); err == nil {
+		t.Error("Expected an error for an invalid package string")
+	}
+}
+
+// pigeonIndex builds a pigeonhole index, where each of the pigeons
+// requires a hole, and each hole is a Product whose versions are the
+// pigeons in it, so that a hole can only hold one pigeon. It can only
+// be solved if there are at least as many holes as pigeons.
+func pigeonIndex(pigeons, holes int) ([]Dependency, Packages) {
+	var (
+		index    []Dependency
+		requires Packages
+	)
+	for i := 0; i < pigeons; i++ {
+		var group Packages
+		for j := 0; j < holes; j++ {
+			hole := NewPackage(fmt.Sprintf("H%d", j), fmt.Sprintf("%d", i))
+			group = append(group, hole)
+			index = append(index, Dependency{hole, nil})
+		}
+		pigeon := NewPackage(fmt.Sprintf("P%d", i), "1")
+		index = append(index, Dependency{pigeon, []Packages{group}})
+		requires = append(requires, pigeon)
+	}
+	return index, requires
+}
+
+func TestResolveWithRestarts(t *testing.T) {
+	index, requires := pigeonIndex(5, 5)
+	resolver := NewResolver(requires, index)
+
+	ok, solution, err := resolver.ResolveWithRestarts(3)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if !ok {
+		t.Fatal("Resolver was expected to succeed, but failed.")
+	}
+	holes := make(map[string]bool)
+	for _, p := range solution {
+		if strings.HasPrefix(p.ProductName(), "H") {
+			holes[p.ProductName()] = true
+		}
+	}
+	if len(holes) != 5 {
+		t.Fatalf("Expected every pigeon in its own hole, but got %s", solution)
+	}
+
+	index, requires = pigeonIndex(4, 3)
+	resolver = NewResolver(requires, index)
+	ok, solution, err = resolver.ResolveWithRestarts(3)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if ok || solution != nil {
+		t.Fatal("Resolver was expected to fail, but succeeded.")
+	}
+
+	// The failed attempt is kept, to report its conflicts
+	if resolver.Solved() || len(resolver.Conflicts()) == 0 {
+		t.Fatalf("Expected the conflicts of the failed attempt, but got %s", resolver.Conflicts())
+	}
+	if details, err := resolver.DetailedConflicts(); err != nil || len(details) == 0 {
+		t.Fatalf("Expected the detailed conflicts of the failed attempt, but got %v (%v)", details, err)
+	}
+}
+
+func TestCopyConfig(t *testing.T) {
+	P := NewPackage
+
+	index := []Dependency{
+		{P("A", "1.0"), nil},
+		{P("A", "2.0"), nil},
+	}
+	resolver := NewResolver(Packages{P("A", AnyVersion)}, index)
+	resolver.SetRequirementPriority(P("A", "1.0"), 1)
+	if err := resolver.RequireAtLeast("A", "1.0"); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	c := resolver.copyConfig()
+	c.bounds["A"] = "2.0"
+	c.priority["A-1.0"] = 5
+	c.index[0] = Dependency{P("B", "1.0"), nil}
+	if resolver.bounds["A"] != "1.0" || resolver.priority["A-1.0"] != 1 || resolver.index[0].Target.PackageName() != "A-1.0" {
+		t.Fatal("Expected changes to a copied configuration to not affect the Resolver")
+	}
+}
+
+func benchmarkRestarts(b *testing.B, attempts int) {
+	index, requires := pigeonIndex(8, 8)
+	resolver := NewResolver(requires, index)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if ok, _, err := resolver.ResolveWithRestarts(attempts); err != nil || !ok {
+			b.Fatal("Resolver was expected to succeed, but failed.")
+		}
+	}
+}
+
+func BenchmarkResolveSingleAttempt(b *testing.B) {
+	benchmarkRestarts(b, 1)
+}
+
+func BenchmarkResolveWithRestarts(b *testing.B) {
+	benchmarkRestarts(b, 4)
+}
+
+func TestWriteIndexDOT(t *testing.T) {
+	var buf bytes.Buffer
+	if err := WriteIndexDOT(&buf, sampleIndex()); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if lines[0] != "digraph index {" || lines[len(lines)-1] != "}" {
+		t.Fatalf("Expected a digraph, but got:\n%s", buf.String())
+	}
+
+	nodeRe := regexp.MustCompile(`^\t"[^"]+";$`)
+	edgeRe := regexp.MustCompile(`^\t"[^"]+" -> "[^"]+" \[color=\w+, fontcolor=\w+, label="\d+"\];$`)
+	var nodes, edges int
+	for _, line := range lines[1 : len(lines)-1] {
+		switch {
+		case nodeRe.MatchString(line):
+			nodes++
+		case edgeRe.MatchString(line):
+			edges++
+		default:
+			t.Errorf("Invalid DOT statement: %q", line)
+		}
+	}
+	if nodes != 15 || edges != 16 {
+		t.Errorf("Expected 15 nodes and 16 edges, but got %d and %d", nodes, edges)
+	}
+
+	if !strings.Contains(buf.String(), `"A-1.0.0" -> "C-2.0.0" [color=blue, fontcolor=blue, label="2"];`) {
+		t.Errorf("Expected the second requires-group of A-1.0.0 to be blue:\n%s", buf.String())
+	}
+}
+
+func TestCheckVersionOrdering(t *testing.T) {
+	P := NewPackage
+
+	index := []Dependency{
+		{P("A", "1.0"), []Packages{{P("B", "1.0"), P("B", "1.10"), P("B", "1.9")}}},
+		{P("A", "2.0"), []Packages{{P("C", "rock"), P("C", "paper"), P("C", "scissors")}}},
+		{P("D", "1.0"), nil},
+		{P("D", "1_0"), nil},
+	}
+
+	// The default comparator can order everything but D
+	errs := CheckVersionOrdering(index, nil)
+	if len(errs) != 1 || !strings.Contains(errs[0].Error(), "Product D") {
+		t.Fatalf("Expected a single error for product D, but got %v", errs)
+	}
+
+	// A comparator where each version beats the next one
+	beats := map[string]string{"rock": "scissors", "scissors": "paper", "paper": "rock"}
+	cyclic := func(a, b string) int {
+		switch {
+		case a == b:
+			return 0
+		case beats[a] == b:
+			return 1
+		case beats[b] == a:
+			return -1
+		}
+		return CompareVersions(a, b)
+	}
+	errs = CheckVersionOrdering(index[:2], cyclic)
+	if len(errs) != 1 || !strings.Contains(errs[0].Error(), "Product C") {
+		t.Fatalf("Expected a single error for product C, but got %v", errs)
+	}
+
+	if errs := CheckVersionOrdering(index[:1], nil); errs != nil {
+		t.Fatalf("Expected no errors, but got %v", errs)
+	}
+}
+
+func TestStreamTransition(t *testing.T) {
+	P := NewPackage
+
+	index := []Dependency{
+		{P("A", "2.0"), []Packages{{P("B", "2.0")}}},
+		{P("B", "2.0"), []Packages{{P("C", "1.0")}}},
+		{P("B", "3.0"), nil},
+	}
+
+	installed := Packages{P("A", "1.0"), P("B", "2.0"), P("D", "1.0")}
+	resolver := NewResolver(Packages{P("A", "2.0")}, index)
+
+	install, remove, err := resolver.Transition(installed)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	var streamInstall, streamRemove Packages
+	err = resolver.StreamTransition(installed,
+		func(p Packager) { streamInstall = append(streamInstall, p) },
+		func(p Packager) { streamRemove = append(streamRemove, p) })
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if streamInstall.String() != install.String() || streamRemove.String() != remove.String() {
+		t.Errorf("Expected the streamed install (%s) and remove (%s) to match (%s) and (%s)",
+			streamInstall, streamRemove, install, remove)
+	}
+
+	if err := resolver.StreamTransition(installed, nil, nil); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	resolver.SetRequirements(Packages{P("A", "2.0"), P("B", "3.0")})
+	if err := resolver.StreamTransition(installed, nil, nil); err == nil {
+		t.Error("Expected an error when the requirements cannot be satisfied")
+	}
+}
+
+func TestPackagesEqual(t *testing.T) {
+	P := NewPackage
+
+	a := Packages{P("A", "1.0"), P("B", "2.0"), P("C", "1.0")}
+	b := Packages{P("C", "1.0"), P("A", "1.0"), P("B", "2.0")}
+	if !a.Equal(b) || !b.Equal(a) {
+		t.Errorf("Expected (%s) to equal (%s)", a, b)
+	}
+
+	c := Packages{P("C", "1.0"), P("A", "1.0"), P("B", "1.0")}
+	if a.Equal(c) || c.Equal(a) {
+		t.Errorf("Expected (%s) to not equal (%s)", a, c)
+	}
+	if a.Equal(a[:2]) || a[:2].Equal(a) {
+		t.Error("Expected a subset to not be equal")
+	}
+
+	var empty Packages
+	if !empty.Equal(Packages{}) || !(Packages{}).Equal(nil) {
+		t.Error("Expected nil Packages to equal empty Packages")
+	}
+	if empty.Equal(a) {
+		t.Error("Expected nil Packages to not equal a non-empty list")
+	}
+}
+
+func TestSetConflictSource(t *testing.T) {
+	P := NewPackage
+
+	index := []Dependency{
+		{P("A", "1.0"), nil},
+		{P("B", "1.0"), nil},
+		{P("B", "2.0"), nil},
+	}
+	resolver := NewResolver(Packages{P("A", "1.0"), P("B", "1.0")}, index)
+	if ok, _ := resolver.Resolve(); !ok {
+		t.Fatal("Resolver was expected to succeed, but failed.")
+	}
+
+	pairs := [][2]Packager{{P("A", "1.0"), P("B", "1.0")}, {P("A", "1.0"), P("X", "1.0")}}
+	resolver.SetConflictSource(func() [][2]Packager { return pairs })
+	if ok, _ := resolver.Resolve(); ok {
+		t.Fatal("Resolver was expected to fail, but succeeded.")
+	}
+
+	rels, err := resolver.DetailedConflicts()
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	found := false
+	for _, rel := range rels {
+		if rel.Relates == Conflicts && rel.Packages.Equal(Packages{P("A", "1.0"), P("B", "1.0")}) {
+			found = true
+		}
+	}
+	if !found {
+		t.Fatalf("Expected a conflict between A-1.0 and B-1.0, but got:\n%s", rels)
+	}
+
+	// Other versions are not affected
+	resolver.SetRequirements(Packages{P("A", "1.0"), P("B", "2.0")})
+	if ok, _ := resolver.Resolve(); !ok {
+		t.Fatal("Resolver was expected to succeed, but failed.")
+	}
+
+	// Updated conflicts are picked up by the next Initialize
+	pairs = nil
+	resolver.SetRequirements(Packages{P("A", "1.0"), P("B", "1.0")})
+	if ok, _ := resolver.Resolve(); !ok {
+		t.Fatal("Resolver was expected to succeed, but failed.")
+	}
+}
+
+func TestDescribe(t *testing.T) {
+	P := NewPackage
+
+	resolver := NewResolver(nil, sampleIndex())
+	resolver.SetConflictSource(func() [][2]Packager {
+		return [][2]Packager{{P("F", "0.5.5"), P("Z", "1.0.0")}}
+	})
+	if err := resolver.Forbid(P("Y", "2.0.0")); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	var buf bytes.Buffer
+	if err := resolver.Describe(&buf); err != nil {
+		t.Fatal(err.Error())
+	}
+	out := buf.String()
+
+	expected := []string{
+		"A-1.0.0\n" +
+			"    depends on one of (B-1.2.3, B-1.2.5, B-1.2.9)\n" +
+			"    depends on one of (C-2.0.0, C-2.1.0, C-2.2.0)\n",
+		"B-1.2.5\n" +
+			"    conflicts with (B-1.2.3, B-1.2.9)\n",
+		"F-0.5.5\n" +
+			"    depends on one of (C-2.1.0)\n" +
+			"    depends on one of (X-1.5.0)\n" +
+			"    depends on one of (Y-2.0.0)\n" +
+			"    conflicts with (Z-1.0.0)\n",
+		"Y-2.0.0\n" +
+			"    is not allowed\n",
+	}
+	for _, e := range expected {
+		if !strings.Contains(out, e) {
+			t.Errorf("Expected the description to contain:\n%s\ngot:\n%s", e, out)
+		}
+	}
+}
+
+func TestEstimatedClauseCount(t *testing.T) {
+	resolver := NewResolver(nil, sampleIndex())
+
+	counts := resolver.ProductVersionCount()
+	if len(counts) != 9 || counts["B"] != 3 || counts["D"] != 2 || counts["Z"] != 1 {
+		t.Errorf("Unexpected version counts: %v", counts)
+	}
+
+	actual := int64(resolver.solver.AddedOriginalClauses())
+	if estimate := resolver.EstimatedClauseCount(); estimate != actual {
+		t.Errorf("Expected an estimate of %d clauses, but got %d", actual, estimate)
+	}
+
+	resolver.AllowMultipleVersions("B")
+	actual = int64(resolver.solver.AddedOriginalClauses())
+	if estimate := resolver.EstimatedClauseCount(); estimate != actual {
+		t.Errorf("Expected an estimate of %d clauses, but got %d", actual, estimate)
+	}
+}
+
+func TestResolveNewest(t *testing.T) {
+	P := NewPackage
+
+	index := []Dependency{
+		// The newest A needs a B that does not exist
+		{P("A", "1.9"), []Packages{{P("B", "1.0")}}},
+		{P("A", "1.10"), []Packages{{P("B", "2.0")}}},
+		{P("A", "1.11"), []Packages{{P("B", "3.0")}}},
+		{P("B", "1.0"), nil},
+		{P("B", "2.0"), nil},
+		{P("C", "1.0"), nil},
+		{P("C", "2.0"), nil},
+	}
+	resolver := NewSortResolver(nil, index, ResolveSortLow)
+	if err := resolver.Forbid(P("B", "3.0")); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	solution, err := resolver.ResolveNewest([]string{"A", "C"})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	vers, err := resolver.SolutionMap()
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if vers["A"] != "1.10" || vers["B"] != "2.0" || vers["C"] != "2.0" {
+		t.Fatalf("Expected A-1.10, B-2.0 and C-2.0, but got %s", solution)
+	}
+
+	if _, err := resolver.ResolveNewest([]string{"X"}); err == nil {
+		t.Error("Expected an error for an unknown product")
+	}
+
+	resolver.SetRequirements(Packages{P("B", "1.0")})
+	if _, err := resolver.ResolveNewest([]string{"A"}); err != nil {
+		t.Fatal(err.Error())
+	}
+	if vers, _ := resolver.SolutionMap(); vers["A"] != "1.9" {
+		t.Fatalf("Expected A-1.9, but got %s", resolver.Solution())
+	}
+	if err := resolver.Forbid(P("A", "1.9")); err != nil {
+		t.Fatal(err.Error())
+	}
+	if _, err := resolver.ResolveNewest([]string{"A"}); err == nil {
+		t.Error("Expected an error when no version of A can be satisfied")
+	}
+}
+
+func TestWriteProof(t *testing.T) {
+	P := NewPackage
+
+	index := []Dependency{
+		{P("A", "1.0"), []Packages{{P("B", "1.0")}}},
+		{P("B", "1.0"), nil},
+		{P("B", "2.0"), nil},
+	}
+	resolver := NewResolver(Packages{P("A", "1.0")}, index)
+	if ok, _ := resolver.Resolve(); !ok {
+		t.Fatal("Resolver was expected to succeed, but failed.")
+	}
+	if err := resolver.WriteProof(io.Discard); err == nil {
+		t.Error("Expected an error writing a proof for a satisfiable resolve")
+	}
+
+	resolver.SetRequirements(Packages{P("A", "1.0"), P("B", "2.0")})
+	if ok, _ := resolver.Resolve(); ok {
+		t.Fatal("Resolver was expected to fail, but succeeded.")
+	}
+	var buf bytes.Buffer
+	if err := resolver.WriteProof(&buf); err != nil {
+		t.Fatal(err.Error())
+	}
+	if buf.Len() == 0 {
+		t.Error("Expected a non-empty proof")
+	}
+
+	resolver.SetTracing(false)
+	resolver.Resolve()
+	if err := resolver.WriteProof(io.Discard); err == nil {
+		t.Error("Expected an error writing a proof without tracing")
+	}
+}
+
+func TestSetProductImportance(t *testing.T) {
+	P := NewPackage
+
+	// A and B can not be installed together
+	index := []Dependency{
+		{P("R", "1.0"), []Packages{{P("A", "1.0"), P("A", "2.0"), P("B", "1.0")}}},
+		{P("A", "1.0"), []Packages{{P("C", "1.0")}}},
+		{P("A", "2.0"), []Packages{{P("C", "1.0")}}},
+		{P("B", "1.0"), []Packages{{P("C", "2.0")}}},
+		{P("C", "1.0"), nil},
+		{P("C", "2.0"), nil},
+	}
+
+	tests := []struct {
+		sortMode resolveSort
+		a, b     int
+		expected string
+	}{
+		{ResolveSortHigh, 2, 1, "A-2.0"},
+		{ResolveSortNone, 2, 1, "A-2.0"},
+		{ResolveSortLow, 2, 1, "A-1.0"},
+		{ResolveSortHigh, 1, 2, "B-1.0"},
+		{ResolveSortLow, -1, 1, "B-1.0"},
+	}
+	for _, test := range tests {
+		resolver := NewSortResolver(Packages{P("R", "1.0")}, index, test.sortMode)
+		resolver.SetProductImportance("A", test.a)
+		resolver.SetProductImportance("B", test.b)
+
+		ok, err := resolver.ResolvePreferred()
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		if !ok {
+			t.Fatal("Resolver was expected to succeed, but failed.")
+		}
+
+		found := false
+		for _, p := range resolver.Solution() {
+			if p.PackageName() == test.expected {
+				found = true
+			}
+		}
+		if !found {
+			t.Errorf("Sort mode %d, importance A=%d B=%d: expected %s, but got %s",
+				test.sortMode, test.a, test.b, test.expected, resolver.Solution())
+		}
+	}
+
+	// A package preference takes precedence over its Product importance
+	resolver := NewSortResolver(Packages{P("R", "1.0")}, index, ResolveSortHigh)
+	resolver.SetProductImportance("A", 2)
+	resolver.SetPreference(P("A", "1.0"), 3)
+	if ok, _ := resolver.ResolvePreferred(); !ok {
+		t.Fatal("Resolver was expected to succeed, but failed.")
+	}
+	if vers, _ := resolver.SolutionMap(); vers["A"] != "1.0" {
+		t.Errorf("Expected A-1.0, but got %s", resolver.Solution())
+	}
+}
+
+func TestRootRequirements(t *testing.T) {
+	P := NewPackage
+
+	index := []Dependency{
+		{P("A", "1.0"), []Packages{{P("B", "1.0")}}},
+		{P("B", "1.0"), []Packages{{P("C", "1.0")}}},
+		{P("C", "1.0"), nil},
+		{P("D", "1.0"), []Packages{{P("E", "1.0")}}},
+		{P("E", "1.0"), []Packages{{P("D", "1.0")}}},
+	}
+	resolver := NewResolver(Packages{P("A", "1.0")}, index)
+	if ok, _ := resolver.Resolve(); !ok {
+		t.Fatal("Resolver was expected to succeed, but failed.")
+	}
+
+	roots := resolver.RootRequirements(resolver.Solution())
+	if roots.String() != "A-1.0" {
+		t.Fatalf("Expected the only root to be A-1.0, but got %s", roots)
+	}
+
+	// The roots reproduce the solution
+	solution := resolver.Solution()
+	resolver.SetRequirements(roots)
+	if ok, _ := resolver.Resolve(); !ok || !resolver.Solution().Equal(solution) {
+		t.Fatalf("Expected the roots to reproduce (%s), but got (%s)", solution, resolver.Solution())
+	}
+
+	// A cycle has one of its Packages as a root
+	roots = resolver.RootRequirements(Packages{P("C", "1.0"), P("E", "1.0"), P("D", "1.0")})
+	if roots.String() != "C-1.0, E-1.0" {
+		t.Fatalf("Expected the roots C-1.0, E-1.0, but got %s", roots)
+	}
+}
+
+func TestResolveBatch(t *testing.T) {
+	P := NewPackage
+
+	index := []Dependency{
+		{P("A", "1.0"), []Packages{{P("B", "1.0")}}},
+		{P("B", "1.0"), nil},
+		{P("B", "2.0"), nil},
+		{P("C", "1.0"), []Packages{{P("B", "2.0")}}},
+	}
+	sets := []Packages{
+		{P("A", "1.0")},
+		{P("A", "1.0"), P("C", "1.0")},
+		{P("C", "1.0")},
+	}
+
+	resolver := NewResolver(Packages{P("B", "1.0")}, index)
+
+	results, failed, err := resolver.ResolveBatch(sets, ResolveBatchOptions{})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if len(results) != 3 || failed != 1 {
+		t.Fatalf("Expected 3 results failing at 1, but got %d failing at %d", len(results), failed)
+	}
+	if !results[0].Solved || results[1].Solved || !results[2].Solved {
+		t.Fatalf("Expected only the second set to fail, but got %v", results)
+	}
+	if results[1].Conflicts.String() != "A-1.0, C-1.0" {
+		t.Fatalf("Expected the conflicts A-1.0, C-1.0, but got %s", results[1].Conflicts)
+	}
+	if results[2].Solution.String() != "B-2.0, C-1.0" {
+		t.Fatalf("Expected the solution B-2.0, C-1.0, but got %s", results[2].Solution)
+	}
+
+	results, failed, err = resolver.ResolveBatch(sets, ResolveBatchOptions{StopOnFirstConflict: true})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if len(results) != 2 || failed != 1 || results[1].Solved {
+		t.Fatalf("Expected 2 results failing at 1, but got %d failing at %d", len(results), failed)
+	}
+
+	// The requirements are restored
+	if ok, _ := resolver.Resolve(); !ok || resolver.Solution().String() != "B-1.0" {
+		t.Fatalf("Expected the original requirements to be restored, but got %s", resolver.Solution())
+	}
+}
+
+func TestRedundantWithPins(t *testing.T) {
+	P := NewPackage
+
+	index := []Dependency{
+		{P("A", "1.0"), nil},
+		{P("B", "1.0"), nil},
+		{P("B", "2.0"), nil},
+		{P("C", "1.0"), nil},
+	}
+	resolver := NewResolver(Packages{P("A", "1.0"), P("B", "1.0"), P("C", AnyVersion)}, index)
+	if redundant := resolver.RedundantWithPins(); len(redundant) != 0 {
+		t.Fatalf("Expected no redundant requirements without pins, but got %s", redundant)
+	}
+
+	if err := resolver.Pin(P("B", "1.0")); err != nil {
+		t.Fatal(err.Error())
+	}
+	if err := resolver.Pin(P("C", "1.0")); err != nil {
+		t.Fatal(err.Error())
+	}
+	redundant := resolver.RedundantWithPins()
+	if redundant.String() != "B-1.0, C-*" {
+		t.Fatalf("Expected the redundant requirements B-1.0, C-*, but got %s", redundant)
+	}
+}
+
+func TestDIMACSRoundTrip(t *testing.T) {
+	P := NewPackage
+
+	index := []Dependency{
+		{P("A", "1.0"), []Packages{{P("B", "1.0"), P("B", "2.0")}}},
+		{P("B", "1.0"), nil},
+		{P("B", "2.0"), []Packages{{P("C", "1.0")}}},
+		{P("C", "1.0"), nil},
+	}
+	resolver := NewResolver(Packages{P("A", "1.0")}, index)
+	if err := resolver.Forbid(P("B", "1.0")); err != nil {
+		t.Fatal(err.Error())
+	}
+	if ok, _ := resolver.Resolve(); !ok {
+		t.Fatal("Resolver was expected to succeed, but failed.")
+	}
+
+	var buf bytes.Buffer
+	if err := resolver.WriteDIMACS(&buf); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	loaded, err := NewResolverFromDIMACS(bytes.NewReader(buf.Bytes()), nil)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	loaded.SetRequirements(Packages{P("A", "1.0")})
+	if ok, _ := loaded.Resolve(); !ok {
+		t.Fatal("Resolver was expected to succeed, but failed.")
+	}
+	if !loaded.Solution().Equal(resolver.Solution()) {
+		t.Fatalf("Expected the solution (%s), but got (%s)", resolver.Solution(), loaded.Solution())
+	}
+
+	// Explicit names for a formula that is not about packages
+	loaded, err = NewResolverFromDIMACS(strings.NewReader("p cnf 2 2\n1 2 0\n-1 0\n"),
+		map[int]string{1: "x", 2: "y"})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if ok, _ := loaded.Resolve(); !ok {
+		t.Fatal("Resolver was expected to succeed, but failed.")
+	}
+	if loaded.Solution().String() != "y" {
+		t.Fatalf("Expected the solution y, but got %s", loaded.Solution())
+	}
+
+	// The header counts the auxiliary literals of the compact encoding
+	var many []Dependency
+	for i := 1; i <= 8; i++ {
+		many = append(many, Dependency{P("D", fmt.Sprintf("%d.0", i)), nil})
+	}
+	buf.Reset()
+	if err = NewResolver(nil, many).WriteDIMACS(&buf); err != nil {
+		t.Fatal(err.Error())
+	}
+	var vars, largest int
+	for _, line := range strings.Split(buf.String(), "\n") {
+		if strings.HasPrefix(line, "p cnf ") {
+			fmt.Sscanf(line, "p cnf %d", &vars)
+			continue
+		}
+		if line == "" || line[0] == 'c' {
+			continue
+		}
+		for _, field := range strings.Fields(line) {
+			lit, _ := strconv.Atoi(field)
+			largest = max(largest, lit, -lit)
+		}
+	}
+	if largest <= 8 || vars != largest {
+		t.Fatalf("Expected the header to count %d variables, but got %d:\n%s", largest, vars, buf.String())
+	}
+	if _, err = NewResolverFromDIMACS(bytes.NewReader(buf.Bytes()), nil); err != nil {
+		t.Fatal(err.Error())
+	}
+}
+
+func TestOnConflict(t *testing.T) {
+	P := NewPackage
+
+	index := []Dependency{
+		{P("A", "1.0"), []Packages{{P("B", "1.0")}}},
+		{P("B", "1.0"), nil},
+		{P("B", "2.0"), nil},
+		{P("C", "1.0"), []Packages{{P("B", "2.0")}}},
+	}
+	resolver := NewResolver(Packages{P("A", "1.0")}, index)
+
+	var (
+		calls     int
+		conflicts Packages
+		details   PackageRelations
+	)
+	resolver.OnConflict(func(c Packages, d PackageRelations) {
+		calls++
+		conflicts, details = c, d
+	})
+
+	if ok, _ := resolver.Resolve(); !ok {
+		t.Fatal("Resolver was expected to succeed, but failed.")
+	}
+	if calls != 0 {
+		t.Fatalf("Expected no OnConflict call for a successful resolve, but got %d", calls)
+	}
+
+	resolver.RequireTemp(P("C", "1.0"))
+	if ok, _ := resolver.Resolve(); ok {
+		t.Fatal("Resolver was expected to fail, but succeeded.")
+	}
+	if calls != 1 {
+		t.Fatalf("Expected 1 OnConflict call, but got %d", calls)
+	}
+	if conflicts.String() != "A-1.0, C-1.0" {
+		t.Fatalf("Expected the conflicts A-1.0, C-1.0, but got %s", conflicts)
+	}
+	for _, expected := range []string{
+		"Package A-1.0 depends on one of (B-1.0)",
+		"Package C-1.0 depends on one of (B-2.0)",
+	} {
+		if !strings.Contains(details.String(), expected) {
+			t.Errorf("Expected the details to contain %q, but got:\n%s", expected, details)
+		}
+	}
+
+	resolver.OnConflict(nil)
+	resolver.RequireTemp(P("C", "1.0"))
+	if ok, _ := resolver.Resolve(); ok {
+		t.Fatal("Resolver was expected to fail, but succeeded.")
+	}
+	if calls != 1 {
+		t.Fatalf("Expected no more OnConflict calls, but got %d", calls)
+	}
+}
+
+// A Packager that reports the repository it was defined by
+type sourcedPackage struct {
+	*Package
+	source string
+}
+
+func (p sourcedPackage) Source() string { return p.source }
+
+func TestSolutionBySource(t *testing.T) {
+	P := NewPackage
+	S := func(product, version, source string) sourcedPackage {
+		return sourcedPackage{NewPackage(product, version), source}
+	}
+
+	index := []Dependency{
+		{S("A", "1.0", "main"), []Packages{{P("B", "1.0")}, {P("C", "1.0")}}},
+		{S("B", "1.0", "extra"), nil},
+		{P("C", "1.0"), nil},
+	}
+	resolver := NewResolver(Packages{P("A", "1.0")}, index)
+	if ok, _ := resolver.Resolve(); !ok {
+		t.Fatal("Resolver was expected to succeed, but failed.")
+	}
+
+	sources := resolver.SolutionBySource()
+	if len(sources) != 3 {
+		t.Fatalf("Expected 3 sources, but got %v", sources)
+	}
+	for src, expected := range map[string]string{"main": "A-1.0", "extra": "B-1.0", "": "C-1.0"} {
+		if sources[src].String() != expected {
+			t.Errorf("Expected source %q to have %s, but got %s", src, expected, sources[src])
+		}
+	}
+
+	// The same Package from two sources is an error
+	index = append(index, Dependency{S("B", "1.0", "main"), nil})
+	resolver = &Resolver{}
+	resolver.index = index
+	if err := resolver.Initialize(); err == nil {
+		t.Fatal("Expected an error for a Package defined by two sources")
+	}
+}
+
+func TestSecurityFloor(t *testing.T) {
+	P := NewPackage
+
+	index := []Dependency{
+		{P("A", "1.0"), []Packages{{P("C", "1.0"), P("C", "2.0")}}},
+		{P("B", "1.0"), []Packages{{P("C", "1.0")}}},
+		{P("C", "1.0"), nil},
+		{P("C", "2.0"), nil},
+		{P("D", "1.0"), nil},
+	}
+	resolver := NewSortResolver(Packages{P("A", "1.0")}, index, ResolveSortLow)
+	if ok, _ := resolver.Resolve(); !ok {
+		t.Fatal("Resolver was expected to succeed, but failed.")
+	}
+	if vers := resolver.SolutionVersions("C"); vers.String() != "C-1.0" {
+		t.Fatalf("Expected C-1.0 without a floor, but got %s", vers)
+	}
+
+	// A dependency that allows the floor escalates to it
+	resolver.SetSecurityFloor("C", "2.0")
+	if ok, _ := resolver.Resolve(); !ok {
+		t.Fatal("Resolver was expected to succeed, but failed.")
+	}
+	if vers := resolver.SolutionVersions("C"); vers.String() != "C-2.0" {
+		t.Fatalf("Expected C-2.0 with a floor, but got %s", vers)
+	}
+
+	// The floor does not require the Product
+	resolver.SetRequirements(Packages{P("D", "1.0")})
+	if ok, _ := resolver.Resolve(); !ok || resolver.Solution().String() != "D-1.0" {
+		t.Fatalf("Expected the solution D-1.0, but got %s", resolver.Solution())
+	}
+
+	// A dependency that needs a version below the floor fails
+	resolver.SetRequirements(Packages{P("B", "1.0")})
+	if ok, _ := resolver.Resolve(); ok {
+		t.Fatal("Resolver was expected to fail, but succeeded.")
+	}
+	detailed, err := resolver.DetailedConflicts()
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if !strings.Contains(detailed.String(), "Package C-1.0 is not allowed") {
+		t.Fatalf("Expected C-1.0 to be reported as not allowed, but got:\n%s", detailed)
+	}
+
+	// The floor applies to versions added after it was set
+	resolver.SetRequirements(Packages{P("E", "1.0")})
+	if err := resolver.AddDependency(Dependency{P("C", "1.5"), nil}); err != nil {
+		t.Fatal(err.Error())
+	}
+	if err := resolver.AddDependency(Dependency{P("E", "1.0"), []Packages{{P("C", "1.5")}}}); err != nil {
+		t.Fatal(err.Error())
+	}
+	if ok, _ := resolver.Resolve(); ok {
+		t.Fatalf("Expected the added C-1.5 below the floor to fail, but got %s", resolver.Solution())
+	}
+
+	// The floor is removed with an empty version
+	resolver.SetSecurityFloor("C", "")
+	if ok, _ := resolver.Resolve(); !ok {
+		t.Fatal("Resolver was expected to succeed, but failed.")
+	}
+}
+
+func TestPackagesJSON(t *testing.T) {
+	P := NewPackage
+
+	solution := Packages{P("A", "1.0"), platformPackage{"B", "2.0", "linux"}}
+	data, err := json.Marshal(solution)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	expected := `[{"product":"A","version":"1.0"},{"product":"B","version":"2.0"}]`
+	if string(data) != expected {
+		t.Fatalf("Expected %s, but got %s", expected, data)
+	}
+
+	var decoded Packages
+	if err = json.Unmarshal(data, &decoded); err != nil {
+		t.Fatal(err.Error())
+	}
+	if decoded.String() != "A-1.0, B-2.0" {
+		t.Fatalf("Expected A-1.0, B-2.0, but got %s", decoded)
+	}
+	if _, ok := decoded[1].(*Package); !ok {
+		t.Fatalf("Expected a *Package, but got %T", decoded[1])
+	}
+
+	if data, _ = json.Marshal(Packages(nil)); string(data) != "null" {
+		t.Fatalf("Expected null, but got %s", data)
+	}
+	if _, err = json.Marshal(Packages{nil}); err == nil {
+		t.Fatal("Expected an error marshaling a nil Package")
+	}
+}
+
+func TestAssumeExternalAvailable(t *testing.T) {
+	P := NewPackage
+
+	index := []Dependency{
+		{P("A", "1.0"), []Packages{{P("libc", "2.0")}, {P("B", "1.0")}}},
+		{P("B", "1.0"), []Packages{{P("C", "1.0")}}},
+		{P("C", "2.0"), nil},
+	}
+	resolver := NewResolver(Packages{P("A", "1.0")}, index)
+	undefined := func() []string {
+		var found []string
+		for _, warning := range resolver.Warnings() {
+			if strings.Contains(warning, "libc") || strings.Contains(warning, "C-1.0") {
+				found = append(found, warning)
+			}
+		}
+		return found
+	}
+	if warnings := undefined(); len(warnings) != 3 {
+		t.Fatalf("Expected 3 warnings about libc and C-1.0, but got %v", warnings)
+	}
+
+	resolver.AssumeExternalAvailable(true)
+	if ok, _ := resolver.Resolve(); !ok {
+		t.Fatal("Resolver was expected to succeed, but failed.")
+	}
+	if vers := resolver.SolutionVersions("libc"); vers.String() != "libc-2.0" {
+		t.Fatalf("Expected the external libc-2.0 in the solution, but got %s", vers)
+	}
+
+	// C-1.0 is not external, because C has another version in the index
+	warnings := undefined()
+	if len(warnings) != 1 || !strings.Contains(warnings[0], "C-1.0") {
+		t.Fatalf("Expected only a warning about C-1.0, but got %v", warnings)
+	}
+}
+
+func TestTopVersions(t *testing.T) {
+	P := NewPackage
+
+	index := []Dependency{
+		{P("A", "1.2.9"), nil},
+		{P("A", "1.10.0"), nil},
+		{P("A", "1.2.10"), nil},
+		{P("B", "1.0"), nil},
+	}
+	resolver := NewResolver(Packages{P("B", "1.0")}, index)
+
+	top := resolver.TopVersions("A", 2)
+	if len(top) != 2 || top[0].Version() != "1.10.0" || top[1].Version() != "1.2.10" {
+		t.Fatalf("Expected A-1.10.0, A-1.2.10, but got %v", top)
+	}
+
+	top = resolver.TopVersions("A", 10)
+	if len(top) != 3 || top[2].Version() != "1.2.9" {
+		t.Fatalf("Expected all 3 versions of A, but got %v", top)
+	}
+
+	if top = resolver.TopVersions("X", 2); top != nil {
+		t.Fatalf("Expected nil for an unknown product, but got %v", top)
+	}
+}
+
+func TestResolveSortSameMajor(t *testing.T) {
+	P := NewPackage
+
+	index := []Dependency{
+		{P("A", "1.0"), []Packages{{P("B", "1.0"), P("B", "1.9"), P("B", "2.0")}}},
+		{P("B", "1.0"), nil},
+		{P("B", "1.9"), nil},
+		{P("B", "2.0"), nil},
+		{P("C", "1.0"), []Packages{{P("B", "2.0")}}},
+	}
+	resolver := NewSortResolver(Packages{P("A", "1.0")}, index, ResolveSortSameMajor)
+
+	// Without a preferred version, the highest version is chosen
+	if ok, _ := resolver.Resolve(); !ok {
+		t.Fatal("Resolver was expected to succeed, but failed.")
+	}
+	if vers := resolver.SolutionVersions("B"); vers.String() != "B-2.0" {
+		t.Fatalf("Expected B-2.0 without a preferred version, but got %s", vers)
+	}
+
+	resolver.SetSameMajorVersions(Packages{P("B", "v1.2")})
+	if ok, _ := resolver.Resolve(); !ok {
+		t.Fatal("Resolver was expected to succeed, but failed.")
+	}
+	if vers := resolver.SolutionVersions("B"); vers.String() != "B-1.9" {
+		t.Fatalf("Expected B-1.9 with a preferred B-1.x, but got %s", vers)
+	}
+
+	// Another major version is chosen when it is needed
+	resolver.RequireTemp(P("C", "1.0"))
+	if ok, _ := resolver.Resolve(); !ok {
+		t.Fatal("Resolver was expected to succeed, but failed.")
+	}
+	if vers := resolver.SolutionVersions("B"); vers.String() != "B-2.0" {
+		t.Fatalf("Expected B-2.0 when required by C-1.0, but got %s", vers)
+	}
+}
+
+func TestSetsCompatible(t *testing.T) {
+	P := NewPackage
+
+	index := []Dependency{
+		{P("A", "1.0"), []Packages{{P("C", "1.0")}}},
+		{P("B", "1.0"), []Packages{{P("C", "2.0")}}},
+		{P("C", "1.0"), nil},
+		{P("C", "2.0"), nil},
+		{P("D", "1.0"), nil},
+	}
+	resolver := NewResolver(Packages{P("D", "1.0")}, index)
+	if ok, _ := resolver.Resolve(); !ok {
+		t.Fatal("Resolver was expected to succeed, but failed.")
+	}
+
+	check := func(a, b Packages, expected bool) {
+		t.Helper()
+		ok, err := resolver.SetsCompatible(a, b)
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		if ok != expected {
+			t.Errorf("Expected SetsCompatible(%s; %s) to be %v", a, b, expected)
+		}
+	}
+
+	check(Packages{P("C", "1.0")}, Packages{P("C", "2.0")}, false)
+	check(Packages{P("A", "1.0")}, Packages{P("B", "1.0")}, false)
+	check(Packages{P("A", "1.0")}, Packages{P("D", "1.0"), P("C", AnyVersion)}, true)
+
+	// A set that conflicts on its own is compatible with nothing
+	check(Packages{P("A", "1.0"), P("B", "1.0")}, Packages{}, false)
+
+	if _, err := resolver.SetsCompatible(Packages{P("X", "1.0")}, nil); err == nil {
+		t.Error("Expected an error for an unknown Package")
+	}
+
+	// The last solve is unchanged
+	if !resolver.Solved() {
+		t.Fatal("Expected the last solve to be unchanged")
+	}
+
+	// The check does not add anything to the solver of the Resolver
+	clauses := resolver.solver.AddedOriginalClauses()
+	check(Packages{P("A", "1.0")}, Packages{P("D", "1.0"), P("C", AnyVersion)}, true)
+	if n := resolver.solver.AddedOriginalClauses(); n != clauses {
+		t.Errorf("Expected SetsCompatible to keep %d clauses in the solver, but got %d", clauses, n)
+	}
+}
+
+func TestFailedClauses(t *testing.T) {
+	P := NewPackage
+
+	index := []Dependency{
+		{P("A", "1.0"), []Packages{{P("B", "1.0")}}},
+		{P("B", "1.0"), nil},
+		{P("B", "2.0"), nil},
+		{P("C", "1.0"), []Packages{{P("B", "2.0")}}},
+	}
+	resolver := NewResolver(Packages{P("A", "1.0"), P("C", "1.0")}, index)
+	if ok, _ := resolver.Resolve(); ok {
+		t.Fatal("Resolver was expected to fail, but succeeded.")
+	}
+
+	clauses, err := resolver.FailedClauses()
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	detailed, err := resolver.DetailedConflicts()
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if len(clauses) == 0 || len(clauses) != len(detailed) {
+		t.Fatalf("Expected %d failed clauses, but got %v", len(detailed), clauses)
+	}
+
+	// The detailed conflicts are sorted, so match the clauses in any order
+	expected := make(map[string]bool, len(detailed))
+	for _, rel := range detailed {
+		names := rel.Packages.Names()
+		sort.Strings(names)
+		expected[strings.Join(names, ",")] = true
+	}
+	for _, clause := range clauses {
+		var names []string
+		for _, lit := range clause {
+			names = append(names, resolver.LiteralName(lit))
+		}
+		sort.Strings(names)
+		if !expected[strings.Join(names, ",")] {
+			t.Errorf("Expected clause %v to name one of the detailed conflicts %v, but got %v", clause, detailed, names)
+		}
+	}
+
+	if name := resolver.LiteralName(pigosat.Literal(1000)); name != "" {
+		t.Errorf("Expected no name for an unknown literal, but got %q", name)
+	}
+}
+
+func TestConflictEncodingThreshold(t *testing.T) {
+	P := NewPackage
+
+	index := []Dependency{
+		{P("A", "1.0"), []Packages{{P("B", "1.0")}}},
+		{P("C", "1.0"), []Packages{{P("B", "8.0")}}},
+	}
+	for i := 1; i <= 8; i++ {
+		index = append(index, Dependency{P("B", fmt.Sprintf("%d.0", i)), nil})
+	}
+	for i := 1; i <= 3; i++ {
+		index = append(index, Dependency{P("D", fmt.Sprintf("%d.0", i)), nil})
+	}
+
+	// 2 dependencies, 3 pairs of D, and 8 versions of B
+	resolver := NewResolver(Packages{P("A", "1.0"), P("C", "1.0")}, index)
+	if count := resolver.solver.AddedOriginalClauses(); count != 2+3+(3*8-4) {
+		t.Errorf("Expected the compact encoding of B above the default threshold, but got %d clauses", count)
+	}
+	if count := resolver.EstimatedClauseCount(); count != 2+3+(3*8-4) {
+		t.Errorf("Expected an estimate of %d clauses, but got %d", 2+3+(3*8-4), count)
+	}
+
+	check := func(resolver *Resolver) {
+		t.Helper()
+		if ok, _ := resolver.Resolve(); ok {
+			t.Fatal("Resolver was expected to fail, but succeeded.")
+		}
+		detailed, err := resolver.DetailedConflicts()
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		found := false
+		for _, rel := range detailed {
+			if rel.Relates == Conflicts && rel.Packages.Equal(Packages{P("B", "1.0"), P("B", "8.0")}) {
+				found = true
+			}
+		}
+		if !found {
+			t.Errorf("Expected B-1.0 to conflict with B-8.0, but got:\n%s", detailed)
+		}
+	}
+	check(resolver)
+
+	// The compact conflicts survive a frozen Resolver
+	var buf bytes.Buffer
+	if err := resolver.Freeze(&buf); err != nil {
+		t.Fatal(err.Error())
+	}
+	frozen, err := LoadFrozen(&buf)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	check(frozen)
+
+	// Raising the threshold uses the pairwise clauses for every Product
+	resolver.SetConflictEncodingThreshold(8)
+	if count := resolver.solver.AddedOriginalClauses(); count != 2+3+28 {
+		t.Errorf("Expected the pairwise clauses of B, but got %d clauses", count)
+	}
+	check(resolver)
+
+	// Lowering it uses the compact encoding for D as well
+	resolver.SetConflictEncodingThreshold(2)
+	if count := resolver.solver.AddedOriginalClauses(); count != 2+(3*3-4)+(3*8-4) {
+		t.Errorf("Expected the compact encoding of B and D, but got %d clauses", count)
+	}
+	check(resolver)
+}
+
+func TestRequireOneOf(t *testing.T) {
+	P := NewPackage
+
+	index := []Dependency{
+		{P("A", "1.0"), nil},
+		{P("B", "1.0"), nil},
+		{P("C", "1.0"), []Packages{{P("D", "1.0")}}},
+		{P("D", "1.0"), nil},
+		{P("D", "2.0"), nil},
+	}
+	resolver := NewResolver(Packages{P("C", "1.0")}, index)
+	if err := resolver.RequireOneOf(Packages{P("A", "1.0"), P("B", "1.0")}); err != nil {
+		t.Fatal(err.Error())
+	}
+	if ok, _ := resolver.Resolve(); !ok {
+		t.Fatal("Resolver was expected to succeed, but failed.")
+	}
+	a := resolver.SolutionVersions("A")
+	b := resolver.SolutionVersions("B")
+	if len(a)+len(b) == 0 {
+		t.Fatalf("Expected A-1.0 or B-1.0 in the solution, but got %s", resolver.Solution())
+	}
+
+	// The group is blamed as a whole
+	if err := resolver.Forbid(P("A", "1.0")); err != nil {
+		t.Fatal(err.Error())
+	}
+	if err := resolver.Forbid(P("B", "1.0")); err != nil {
+		t.Fatal(err.Error())
+	}
+	if ok, _ := resolver.Resolve(); ok {
+		t.Fatal("Resolver was expected to fail, but succeeded.")
+	}
+	if conflicts := resolver.Conflicts(); conflicts.String() != "one of (A-1.0, B-1.0)" {
+		t.Fatalf("Expected the group to conflict, but got %s", conflicts)
+	}
+	detailed, err := resolver.DetailedConflicts()
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if !strings.Contains(detailed.String(), "One of (A-1.0, B-1.0) is required") {
+		t.Fatalf("Expected the group to be required, but got:\n%s", detailed)
+	}
+
+	if err := resolver.RequireOneOf(Packages{P("X", "1.0")}); err == nil {
+		t.Error("Expected an error for an unknown Package")
+	}
+	if err := resolver.RequireOneOf(nil); err == nil {
+		t.Error("Expected an error for an empty group")
+	}
+	if err := resolver.Freeze(io.Discard); err == nil {
+		t.Error("Expected an error freezing a one-of requirement")
+	}
+}
+
+// A Packager that is displayed without its platform,
+// but is identified to the solver with it
+type keyedPackage struct {
+	*Package
+	platform string
+}
+
+func (p keyedPackage) SatKey() string { return p.PackageName() + "-" + p.platform }
+
+func TestSatKey(t *testing.T) {
+	P := NewPackage
+	K := func(product, version, platform string) keyedPackage {
+		return keyedPackage{NewPackage(product, version), platform}
+	}
+
+	linux, mac := K("X", "1.0", "linux"), K("X", "1.0", "mac")
+	index := []Dependency{
+		{P("A", "1.0"), []Packages{{linux, mac}}},
+		{linux, nil},
+		{mac, nil},
+	}
+	resolver := NewResolver(Packages{P("A", "1.0")}, index)
+	if n := resolver.ProductVersionCount()["X"]; n != 2 {
+		t.Fatalf("Expected 2 distinct packages of X, but got %d", n)
+	}
+
+	// Without the keys, forbidding one would forbid both
+	if err := resolver.Forbid(linux); err != nil {
+		t.Fatal(err.Error())
+	}
+	if ok, _ := resolver.Resolve(); !ok {
+		t.Fatal("Resolver was expected to succeed, but failed.")
+	}
+	vers := resolver.SolutionVersions("X")
+	if len(vers) != 1 || vers[0].(keyedPackage).platform != "mac" {
+		t.Fatalf("Expected the mac package of X, but got %v", vers)
+	}
+	if vers.String() != "X-1.0" {
+		t.Fatalf("Expected the display name X-1.0, but got %s", vers)
+	}
+	if by, err := resolver.RequiredBy(mac); err != nil || by.String() != "A-1.0" {
+		t.Fatalf("Expected the mac package of X to be required by A-1.0, but got %v, %v", by, err)
+	}
+	if _, err := resolver.RequiredBy(linux); err == nil {
+		t.Fatal("Expected an error for the linux package of X, which is not in the solution")
+	}
+
+	// Packages with the same name but different keys are different versions
+	resolver.SetRequirements(Packages{linux, mac})
+	if conflict, _ := resolver.QuickConflictCheck(); !conflict {
+		t.Error("Expected the linux and mac packages of X to conflict")
+	}
+	if err := resolver.Pin(mac); err != nil {
+		t.Fatal(err.Error())
+	}
+	if redundant := resolver.RedundantWithPins(); len(redundant) != 1 || redundant[0] != mac {
+		t.Errorf("Expected only the pinned mac package to be redundant, but got %v", redundant)
+	}
+
+	a := NewResolver(nil, []Dependency{{P("A", "1.0"), []Packages{{linux}}}})
+	b := NewResolver(nil, []Dependency{{P("A", "1.0"), []Packages{{mac}}}})
+	if a.IndexFingerprint() == b.IndexFingerprint() {
+		t.Error("Expected indexes with different keys to have different fingerprints")
+	}
+}
+
+func TestCompact(t *testing.T) {
+	P := NewPackage
+
+	index := []Dependency{
+		{P("A", "1.0"), []Packages{{P("B", "1.0")}}},
+		{P("B", "1.0"), nil},
+		{P("B", "2.0"), nil},
+		{P("C", "1.0"), []Packages{{P("B", "2.0")}}},
+	}
+	resolver := NewResolver(Packages{P("A", "1.0")}, index)
+	if ok, _ := resolver.Resolve(); !ok {
+		t.Fatal("Resolver was expected to succeed, but failed.")
+	}
+
+	resolver.Compact()
+	if !resolver.Solved() || resolver.Solution().String() != "A-1.0, B-1.0" {
+		t.Fatalf("Expected the solution to be kept, but got %s", resolver.Solution())
+	}
+	if _, err := resolver.Resolve(); err == nil {
+		t.Fatal("Expected an error resolving after Compact")
+	}
+	if resolver.Solution().String() != "A-1.0, B-1.0" {
+		t.Fatalf("Expected the solution to be kept, but got %s", resolver.Solution())
+	}
+
+	// A failed solve keeps its conflicts
+	resolver.SetRequirements(Packages{P("A", "1.0"), P("C", "1.0")})
+	if ok, _ := resolver.Resolve(); ok {
+		t.Fatal("Resolver was expected to fail, but succeeded.")
+	}
+	detailed, err := resolver.DetailedConflicts()
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	resolver.Compact()
+	if resolver.Solved() {
+		t.Fatal("Expected the failed solve to be kept")
+	}
+	if conflicts := resolver.Conflicts(); conflicts.String() != "A-1.0, C-1.0" {
+		t.Fatalf("Expected the conflicts A-1.0, C-1.0, but got %s", conflicts)
+	}
+	kept, err := resolver.DetailedConflicts()
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if kept.String() != detailed.String() {
+		t.Fatalf("Expected the detailed conflicts to be kept, but got:\n%s", kept)
+	}
+
+	// The methods that need the solver fail without panicking
+	if err := resolver.Forbid(P("B", "1.0")); err == nil {
+		t.Fatal("Expected an error forbidding after Compact")
+	}
+	if err := resolver.Pin(P("B", "1.0")); err == nil {
+		t.Fatal("Expected an error pinning after Compact")
+	}
+	if err := resolver.RequireNoneOf(Packages{P("B", "1.0")}); err == nil {
+		t.Fatal("Expected an error forbidding after Compact")
+	}
+	if err := resolver.RequireOneOf(Packages{P("B", "1.0")}); err == nil {
+		t.Fatal("Expected an error requiring after Compact")
+	}
+	if err := resolver.ExcludeProduct("B"); err == nil {
+		t.Fatal("Expected an error excluding after Compact")
+	}
+	if _, err := resolver.FailedClauses(); err == nil {
+		t.Fatal("Expected an error for the failed clauses after Compact")
+	}
+	if _, err := resolver.SmallestConflict(); err == nil {
+		t.Fatal("Expected an error for the smallest conflict after Compact")
+	}
+	if _, err := resolver.PackageByName("B-1.0"); err == nil {
+		t.Fatal("Expected an error looking up a Package after Compact")
+	}
+	if _, err := resolver.DependencyDepth(P("A", "1.0")); err == nil {
+		t.Fatal("Expected an error for the dependency depth after Compact")
+	}
+	if errs := resolver.ValidateRequirements(); len(errs) == 0 {
+		t.Fatal("Expected an error validating after Compact")
+	}
+	if resolver.IsPackageConflict(P("A", "1.0")) || resolver.IsPackageNameConflict("A-1.0") {
+		t.Fatal("Expected no Package conflicts to be looked up after Compact")
+	}
+	if name := resolver.LiteralName(1); name != "" {
+		t.Fatalf("Expected no literal name after Compact, but got %q", name)
+	}
+	if mapping := resolver.LiteralMapping(); len(mapping) != 0 {
+		t.Fatalf("Expected an empty literal mapping after Compact, but got %v", mapping)
+	}
+	if _, ok := resolver.PackageLiteral(P("A", "1.0")); ok {
+		t.Fatal("Expected no Package literal after Compact")
+	}
+	if vers := resolver.TopVersions("B", -1); vers != nil {
+		t.Fatalf("Expected no versions after Compact, but got %s", vers)
+	}
+	if counts := resolver.ProductVersionCount(); len(counts) != 0 {
+		t.Fatalf("Expected no version counts after Compact, but got %v", counts)
+	}
+
+	// Initializing again restores the solver
+	if err := resolver.Initialize(); err != nil {
+		t.Fatal(err.Error())
+	}
+	if ok, _ := resolver.Resolve(); ok {
+		t.Fatal("Resolver was expected to fail, but succeeded.")
+	}
+}
+
+func TestRequireAllNoneOf(t *testing.T) {
+	P := NewPackage
+
+	index := []Dependency{
+		{P("A", "1.0"), []Packages{{P("C", "1.0"), P("C", "2.0"), P("C", "3.0")}}},
+		{P("B", "1.0"), nil},
+		{P("C", "1.0"), nil},
+		{P("C", "2.0"), nil},
+		{P("C", "3.0"), nil},
+	}
+	resolver := NewResolver(Packages{P("A", "1.0")}, index)
+
+	resolver.RequireAll(Packages{P("A", "1.0"), P("B", "1.0"), P("B", "1.0")})
+	if len(resolver.requires) != 2 {
+		t.Fatalf("Expected 2 deduplicated requirements, but got %s", resolver.requires)
+	}
+
+	solver := resolver.solver
+	if err := resolver.RequireNoneOf(Packages{P("C", "1.0"), P("C", "3.0")}); err != nil {
+		t.Fatal(err.Error())
+	}
+	if resolver.solver != solver {
+		t.Fatal("Expected RequireNoneOf to keep the solver")
+	}
+
+	if ok, _ := resolver.Resolve(); !ok {
+		t.Fatal("Resolver was expected to succeed, but failed.")
+	}
+	if sol := resolver.Solution(); !sol.Equal(Packages{P("A", "1.0"), P("B", "1.0"), P("C", "2.0")}) {
+		t.Fatalf("Expected the solution A-1.0, B-1.0, C-2.0, but got %s", sol)
+	}
+
+	// An unknown Package forbids nothing
+	if err := resolver.RequireNoneOf(Packages{P("C", "2.0"), P("X", "1.0")}); err == nil {
+		t.Fatal("Expected an error for an unknown Package")
+	}
+	if ok, _ := resolver.Resolve(); !ok {
+		t.Fatal("Resolver was expected to succeed, but failed.")
+	}
+}
+
+func TestLiteralMapping(t *testing.T) {
+	P := NewPackage
+
+	index := sampleIndex()
+	resolver := NewResolver(Packages{P("A", "1.0.0")}, index)
+	mapping := resolver.LiteralMapping()
+
+	known := flattenDependencies(slices.Values(index))
+	names := make(map[string]bool)
+	for _, p := range known {
+		names[p.PackageName()] = true
+	}
+	if len(mapping) != len(names) {
+		t.Fatalf("Expected %d literals, but got %d", len(names), len(mapping))
+	}
+
+	for _, p := range known {
+		id, ok := resolver.PackageLiteral(p)
+		if !ok {
+			t.Fatalf("Expected a literal for %s", p)
+		}
+		name, ok := mapping[id]
+		if !ok || name != p.PackageName() {
+			t.Fatalf("Expected literal %d to map to %s, but got %q", id, p, name)
+		}
+		found, err := resolver.PackageByName(name)
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		if found.PackageName() != p.PackageName() {
+			t.Fatalf("Expected PackageByName(%q) to return %s, but got %s", name, p, found)
+		}
+	}
+
+	if _, ok := resolver.PackageLiteral(P("X", "1.0")); ok {
+		t.Error("Expected no literal for an unknown Package")
+	}
+
+	// The mapping is a copy
+	delete(mapping, 1)
+	if _, ok := resolver.LiteralMapping()[1]; !ok {
+		t.Error("Expected the mapping to be a copy")
+	}
+}
+
+func TestAnnotatedSolution(t *testing.T) {
+	P := NewPackage
+
+	index := []Dependency{
+		{P("A", "1.0"), []Packages{{P("B", "2.0")}}},
+		{P("B", "1.0"), nil},
+		{P("B", "1.5"), nil},
+		{P("B", "2.0"), nil},
+	}
+	resolver := NewResolver(Packages{P("A", "1.0")}, index)
+	if _, err := resolver.AnnotatedSolution(); err == nil {
+		t.Fatal("Expected an error before resolving")
+	}
+	if ok, _ := resolver.Resolve(); !ok {
+		t.Fatal("Resolver was expected to succeed, but failed.")
+	}
+
+	annotated, err := resolver.AnnotatedSolution()
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if len(annotated) != 2 {
+		t.Fatalf("Expected 2 products, but got %v", annotated)
+	}
+	b := annotated["B"]
+	if b.Chosen.PackageName() != "B-2.0" {
+		t.Fatalf("Expected B-2.0 to be chosen, but got %s", b.Chosen)
+	}
+	if b.Alternatives.String() != "B-1.5, B-1.0" {
+		t.Fatalf("Expected the alternatives B-1.5, B-1.0, but got %s", b.Alternatives)
+	}
+	if a := annotated["A"]; len(a.Alternatives) != 0 {
+		t.Fatalf("Expected no alternatives of A, but got %s", a.Alternatives)
+	}
+}
+
+func TestIntern(t *testing.T) {
+	var in Interner
+	a := in.Intern("intern", "1.0.0")
+	b := in.Intern("intern", "1.0.0")
+	if a != b {
+		t.Fatal("Expected the same instance for the same product and version")
+	}
+	if c := in.Intern("intern", "2.0.0"); c == a {
+		t.Fatal("Expected a different instance for a different version")
+	}
+	if a.PackageName() != NewPackage("intern", "1.0.0").PackageName() {
+		t.Fatalf("Expected interned Package to equal intern-1.0.0, got %s", a)
+	}
+
+	var other Interner
+	if c := other.Intern("intern", "1.0.0"); c == a {
+		t.Fatal("Expected separate Interners to not share Packages")
+	}
+
+	// The name is formatted on demand, not when interned
+	p := in.Intern("intern", "3.0.0")
+	prev := NameFormatter
+	defer func() { NameFormatter = prev }()
+	NameFormatter = func(product, version string) string { return product + "@" + version }
+	if p.PackageName() != "intern@3.0.0" {
+		t.Fatalf("Expected the name intern@3.0.0, but got %s", p.PackageName())
+	}
+
+	if Intern("intern", "1.0.0") != Intern("intern", "1.0.0") {
+		t.Fatal("Expected the shared pool to return the same instance")
+	}
+
+	var wg sync.WaitGroup
+	found := make([]*Package, 8)
+	for i := range found {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			found[i] = Intern("intern-concurrent", "1.0.0")
+		}(i)
+	}
+	wg.Wait()
+	for _, p := range found {
+		if p != found[0] {
+			t.Fatal("Expected concurrent callers to share a single instance")
+		}
+	}
+}
+
+func TestValidateRequirements(t *testing.T) {
+	P := NewPackage
+
+	index := []Dependency{
+		{P("A", "1.0"), nil},
+		{P("B", "1.0"), []Packages{{P("Z", "1.0")}}},
+	}
+	// Z-1.0 is only referred to by a requires-group, but is still known
+	resolver := NewResolver(Packages{P("A", "1.0"), P("B", AnyVersion), P("Z", "1.0")}, index)
+	if errs := resolver.ValidateRequirements(); errs != nil {
+		t.Fatalf("Expected no errors for known requirements, but got %v", errs)
+	}
+
+	resolver.SetRequirements(Packages{P("A", "1.0"), P("Q", "9.9.9"), P("A", "2.0"), P("R", AnyVersion)})
+	errs := resolver.ValidateRequirements()
+	expected := []string{
+		`Required package "Q-9.9.9" does not exist in the Resolver`,
+		`Required package "A-2.0" does not exist in the Resolver`,
+		`Required product "R" does not exist in the Resolver`,
+	}
+	if len(errs) != len(expected) {
+		t.Fatalf("Expected %d errors, but got %v", len(expected), errs)
+	}
+	for i, err := range errs {
+		if err.Error() != expected[i] {
+			t.Errorf("Expected error %q, but got %q", expected[i], err.Error())
+		}
+	}
+
+	// A replaced Package is dropped from the index,
+	// but resolves to its replacement
+	resolver.SetRequirements(Packages{P("Z", "1.0")})
+	if err := resolver.AddReplacement(P("Z", "1.0"), P("A", "1.0")); err != nil {
+		t.Fatal(err.Error())
+	}
+	if errs := resolver.ValidateRequirements(); errs != nil {
+		t.Fatalf("Expected no errors for a replaced requirement, but got %v", errs)
+	}
+}
+
+func TestAddReplacement(t *testing.T) {
+	P := NewPackage
+
+	index := []Dependency{
+		{P("A", "1.0"), []Packages{{P("C", "1.0")}}},
+		{P("A2", "1.0"), nil},
+		{P("B", "1.0"), []Packages{{P("A", "1.0")}}},
+		{P("C", "1.0"), nil},
+	}
+	resolver := NewResolver(Packages{P("A", "1.0"), P("B", "1.0")}, index)
+	if err := resolver.AddReplacement(P("A", "1.0"), P("A2", "1.0")); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	ok, err := resolver.Resolve()
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if !ok {
+		t.Fatal("Resolver was expected to succeed, but failed.")
+	}
+	solution := resolver.Solution()
+	names := make([]string, len(solution))
+	for i, p := range solution {
+		names[i] = p.PackageName()
+	}
+	sort.Strings(names)
+	if actual := strings.Join(names, ", "); actual != "A2-1.0, B-1.0" {
+		t.Fatalf("Expected the solution A2-1.0, B-1.0, but got %s", actual)
+	}
+	if index[2].Requires[0][0].PackageName() != "A-1.0" {
+		t.Fatal("Expected the package index to not be modified")
+	}
+
+	if err := resolver.AddReplacement(P("A2", "1.0"), P("A", "1.0")); err == nil {
+		t.Fatal("Expected an error for a replacement cycle")
+	}
+	if err := resolver.AddReplacement(P("C", "1.0"), P("C", "1.0")); err == nil {
+		t.Fatal("Expected an error for a Package replacing itself")
+	}
+
+	// Replacing a pinned Package skips the pin
+	if err := resolver.Pin(P("C", "1.0")); err != nil {
+		t.Fatal(err.Error())
+	}
+	if err := resolver.AddReplacement(P("C", "1.0"), P("A2", "1.0")); err != nil {
+		t.Fatalf("Expected replacing a pinned Package to succeed, but got: %s", err.Error())
+	}
+
+	// A replacement that collides with another Package leaves the Resolver unchanged
+	resolver = NewResolver(Packages{P("B", "1.0")}, append(index, Dependency{P("D-1", "0.0"), nil}))
+	if err := resolver.AddReplacement(P("A", "1.0"), P("D", "1-0.0")); err == nil {
+		t.Fatal("Expected an error for a replacement that collides with another Package")
+	}
+	if len(resolver.replaces) != 0 {
+		t.Fatalf("Expected the failed replacement to be dropped, but got %v", resolver.replaces)
+	}
+	if ok, err := resolver.Resolve(); err != nil || !ok {
+		t.Fatalf("Expected the Resolver to still resolve, but got %v, %v", ok, err)
+	}
+}
+
+func TestReport(t *testing.T) {
+	P := NewPackage
+
+	index := []Dependency{
+		{P("A", "1.0"), []Packages{{P("B", "1.0")}}},
+		{P("B", "1.0"), nil},
+		{P("B", "2.0"), nil},
+	}
+	resolver := NewResolver(Packages{P("A", "1.0")}, index)
+	if _, err := resolver.Resolve(); err != nil {
+		t.Fatal(err.Error())
+	}
+	report := resolver.Report()
+	if !report.Solved {
+		t.Fatal("Resolver was expected to succeed, but failed.")
+	}
+	if !report.Solution.Equal(Packages{P("A", "1.0"), P("B", "1.0")}) {
+		t.Fatalf("Expected the solution A-1.0, B-1.0, but got %s", report.Solution)
+	}
+	if report.Conflicts != nil || report.Details != nil {
+		t.Fatalf("Expected no conflicts, but got %s / %v", report.Conflicts, report.Details)
+	}
+	if report.Stats.Variables == 0 || report.Stats.Clauses == 0 {
+		t.Fatalf("Expected solver statistics, but got %+v", report.Stats)
+	}
+
+	resolver.SetRequirements(Packages{P("A", "1.0"), P("B", "2.0")})
+	if _, err := resolver.Resolve(); err != nil {
+		t.Fatal(err.Error())
+	}
+	report = resolver.Report()
+	if report.Solved {
+		t.Fatal("Resolver was expected to fail, but succeeded.")
+	}
+	if report.Solution != nil {
+		t.Fatalf("Expected no solution, but got %s", report.Solution)
+	}
+	if len(report.Conflicts) == 0 || len(report.Details) == 0 {
+		t.Fatalf("Expected conflicts, but got %s / %v", report.Conflicts, report.Details)
+	}
+
+	js, err := json.Marshal(report)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if !strings.Contains(string(js), `"solved":false`) || !strings.Contains(string(js), `"conflicts":[`) {
+		t.Fatalf("Unexpected serialized report: %s", js)
+	}
+}
+
+func TestSetRequirementPriority(t *testing.T) {
+	P := NewPackage
+
+	// A and B require different versions of X
+	index := []Dependency{
+		{P("A", "1.0"), []Packages{{P("X", "1.0")}}},
+		{P("B", "1.0"), []Packages{{P("X", "2.0")}}},
+		{P("C", "1.0"), nil},
+		{P("X", "1.0"), nil},
+		{P("X", "2.0"), nil},
+	}
+	resolver := NewResolver(Packages{P("A", "1.0"), P("B", "1.0"), P("C", "1.0")}, index)
+
+	if ok, err := resolver.Resolve(); err != nil {
+		t.Fatal(err.Error())
+	} else if ok {
+		t.Fatal("Resolver was expected to fail, but succeeded.")
+	}
+	if actual := resolver.Conflicts().String(); actual != "A-1.0, B-1.0" {
+		t.Fatalf("Expected the conflicts A-1.0, B-1.0, but got %s", actual)
+	}
+
+	resolver.SetRequirementPriority(P("B", "1.0"), 10)
+	if actual := resolver.Conflicts().String(); actual != "B-1.0, A-1.0" {
+		t.Fatalf("Expected the conflicts B-1.0, A-1.0, but got %s", actual)
+	}
+}
+
+func TestStreamedClausalCore(t *testing.T) {
+	P := NewPackage
+
+	// A long chain of dependencies that ends in a conflict,
+	// so that the whole chain is part of the clausal core
+	const n = 40
+	var index []Dependency
+	for i := 0; i < n; i++ {
+		index = append(index, Dependency{P(fmt.Sprintf("C%d", i), "1.0"), []Packages{{P(fmt.Sprintf("C%d", i+1), "1.0")}}})
+	}
+	index = append(index,
+		Dependency{P(fmt.Sprintf("C%d", n), "1.0"), []Packages{{P("X", "1.0")}}},
+		Dependency{P("X", "1.0"), nil},
+		Dependency{P("X", "2.0"), nil},
+	)
+	resolver := NewResolver(Packages{P("C0", "1.0"), P("X", "2.0")}, index)
+	if ok, err := resolver.Resolve(); err != nil {
+		t.Fatal(err.Error())
+	} else if ok {
+		t.Fatal("Resolver was expected to fail, but succeeded.")
+	}
+
+	var buf bytes.Buffer
+	if err := resolver.solver.WriteClausalCore(&buf); err != nil {
+		t.Fatal(err.Error())
+	}
+	expected, err := resolver.cnfToPackageRelations(&buf)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if len(expected) < n {
+		t.Fatalf("Expected a core of at least %d relations, but got %d", n, len(expected))
+	}
+
+	streamed, err := resolver.DetailedConflicts()
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if streamed.String() != expected.String() {
+		t.Fatalf("Expected the streamed core to match the buffered core:\n%s\n\ngot:\n%s", expected, streamed)
+	}
+
+	// Closing the stream before it is read to the end must not block
+	core := resolver.clausalCore()
+	if err := core.Close(); err != nil {
+		t.Fatal(err.Error())
+	}
+}
+
+func TestChangeImpact(t *testing.T) {
+	P := NewPackage
+
+	index := []Dependency{
+		{P("A", "1.0"), []Packages{{P("C", "1.0")}}},
+		{P("B", "1.0"), nil},
+		{P("C", "1.0"), []Packages{{P("Z", "1.0")}}},
+		{P("C", "2.0"), []Packages{{P("Z", "2.0")}}},
+		{P("Z", "1.0"), nil},
+		{P("Z", "2.0"), nil},
+	}
+	resolver := NewResolver(Packages{P("A", "1.0")}, index)
+
+	sets := []Packages{
+		{P("A", "1.0")},
+		{P("A", "1.0"), P("Z", "1.0")},
+		{P("B", "1.0"), P("Z", "1.0")},
+		{P("A", "1.0"), P("Z", "2.0")},
+	}
+	impact, err := resolver.ChangeImpact("C", "1.0", "2.0", sets)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if len(impact) != 1 {
+		t.Fatalf("Expected 1 set to break, but got %v", impact)
+	}
+	if impact[0].Requires.String() != "A-1.0, Z-1.0" {
+		t.Fatalf("Expected the set A-1.0, Z-1.0 to break, but got %s", impact[0].Requires)
+	}
+	if len(impact[0].Conflicts) == 0 {
+		t.Fatal("Expected the conflicts of the broken set")
+	}
+
+	// A new version takes over the dependencies of the old version
+	impact, err = resolver.ChangeImpact("Z", "1.0", "3.0", sets)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if len(impact) != 0 {
+		t.Fatalf("Expected no set to break, but got %v", impact)
+	}
+
+	if _, err = resolver.ChangeImpact("C", "9.0", "10.0", sets); err == nil {
+		t.Fatal("Expected an error for an unknown version")
+	}
+
+	// The Resolver itself is not changed
+	if ok, err := resolver.Resolve(); err != nil {
+		t.Fatal(err.Error())
+	} else if !ok {
+		t.Fatal("Resolver was expected to succeed, but failed.")
+	}
+	if !resolver.Solution().Equal(Packages{P("A", "1.0"), P("C", "1.0"), P("Z", "1.0")}) {
+		t.Fatalf("Expected the solution A-1.0, C-1.0, Z-1.0, but got %s", resolver.Solution())
+	}
+}
+
+func TestMustResolve(t *testing.T) {
+	P := NewPackage
+
+	index := []Dependency{
+		{P("A", "1.0"), []Packages{{P("B", "1.0")}}},
+		{P("B", "1.0"), nil},
+		{P("B", "2.0"), nil},
+	}
+	resolver := NewResolver(Packages{P("A", "1.0")}, index)
+	if solution := resolver.MustResolve(); !solution.Equal(Packages{P("A", "1.0"), P("B", "1.0")}) {
+		t.Fatalf("Expected the solution A-1.0, B-1.0, but got %s", solution)
+	}
+
+	resolver.SetRequirements(Packages{P("A", "1.0"), P("B", "2.0")})
+	defer func() {
+		msg, ok := recover().(string)
+		if !ok {
+			t.Fatal("Expected MustResolve to panic with a message")
+		}
+		if !strings.Contains(msg, "A-1.0, B-2.0") || !strings.Contains(msg, "\n") {
+			t.Fatalf("Expected the detailed conflicts in the panic message, but got %q", msg)
+		}
+	}()
+	resolver.MustResolve()
+	t.Fatal("Resolver was expected to fail, but succeeded.")
+}
+
+func TestSetSeed(t *testing.T) {
+	P := NewPackage
+
+	// Any version of B satisfies A, and there is no sort mode
+	var vers Packages
+	index := []Dependency{}
+	for i := 1; i <= 5; i++ {
+		v := P("B", fmt.Sprintf("%d.0", i))
+		vers = append(vers, v)
+		index = append(index, Dependency{v, nil})
+	}
+	index = append(index, Dependency{P("A", "1.0"), []Packages{vers}})
+
+	solve := func(seed int64) string {
+		resolver := NewResolver(Packages{P("A", "1.0")}, index)
+		resolver.SetSeed(seed)
+		solution := resolver.MustResolve()
+		sort.Sort(solution)
+		return solution.String()
+	}
+
+	solutions := make(map[string]bool)
+	for seed := int64(0); seed < 20; seed++ {
+		first := solve(seed)
+		if second := solve(seed); first != second {
+			t.Fatalf("Expected the same solution for seed %d, but got %s and %s", seed, first, second)
+		}
+		solutions[first] = true
+	}
+	if len(solutions) < 2 {
+		t.Fatalf("Expected different seeds to choose different solutions, but got %v", solutions)
+	}
+}
+
+func TestDependencyDepth(t *testing.T) {
+	P := NewPackage
+
+	index := []Dependency{
+		// A linear chain
+		{P("A", "1.0"), []Packages{{P("B", "1.0")}}},
+		{P("B", "1.0"), []Packages{{P("C", "1.0")}}},
+		{P("C", "1.0"), nil},
+		// A diamond with a longer branch
+		{P("D", "1.0"), []Packages{{P("E", "1.0"), P("F", "1.0")}}},
+		{P("E", "1.0"), []Packages{{P("H", "1.0")}}},
+		{P("F", "1.0"), []Packages{{P("G", "1.0")}}},
+		{P("G", "1.0"), []Packages{{P("H", "1.0")}}},
+		{P("H", "1.0"), nil},
+		// A cycle
+		{P("X", "1.0"), []Packages{{P("Y", "1.0")}}},
+		{P("Y", "1.0"), []Packages{{P("X", "1.0")}}},
+	}
+	resolver := NewResolver(nil, index)
+
+	for name, expected := range map[string]int{"A": 2, "C": 0, "D": 3, "E": 1} {
+		depth, err := resolver.DependencyDepth(P(name, "1.0"))
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		if depth != expected {
+			t.Errorf("Expected a depth of %d for %s-1.0, but got %d", expected, name, depth)
+		}
+	}
+
+	if _, err := resolver.DependencyDepth(P("X", "1.0")); err == nil {
+		t.Error("Expected an error for a dependency cycle")
+	}
+	if _, err := resolver.DependencyDepth(P("Q", "1.0")); err == nil {
+		t.Error("Expected an error for an unknown Package")
+	}
+}
+
+func TestSetCoInstallable(t *testing.T) {
+	P := NewPackage
+
+	index := []Dependency{
+		{P("Py", "3.11"), nil},
+		{P("Py", "3.12"), nil},
+		{P("L", "1.0"), nil},
+		{P("L", "2.0"), nil},
+	}
+	resolver := NewResolver(Packages{P("Py", "3.11"), P("Py", "3.12")}, index)
+	resolver.SetCoInstallable("Py", true)
+	if solution := resolver.MustResolve(); !solution.Equal(Packages{P("Py", "3.11"), P("Py", "3.12")}) {
+		t.Fatalf("Expected both versions of Py in the solution, but got %s", solution)
+	}
+	if vers := resolver.SolutionVersions("Py"); len(vers) != 2 {
+		t.Fatalf("Expected 2 selected versions of Py, but got %s", vers)
+	}
+
+	// Other products still conflict
+	resolver.SetRequirements(Packages{P("Py", "3.11"), P("Py", "3.12"), P("L", "1.0"), P("L", "2.0")})
+	if ok, err := resolver.Resolve(); err != nil {
+		t.Fatal(err.Error())
+	} else if ok {
+		t.Fatal("Resolver was expected to fail, but succeeded.")
+	}
+
+	// Restoring the default makes the versions conflict again
+	resolver.SetCoInstallable("Py", false)
+	resolver.SetRequirements(Packages{P("Py", "3.11"), P("Py", "3.12")})
+	if ok, err := resolver.Resolve(); err != nil {
+		t.Fatal(err.Error())
+	} else if ok {
+		t.Fatal("Resolver was expected to fail, but succeeded.")
+	}
+}
+
+func TestWarmUp(t *testing.T) {
+	P := NewPackage
+
+	index := []Dependency{
+		{P("A", "1.0"), []Packages{{P("B", "1.0")}}},
+		{P("B", "1.0"), nil},
+		{P("B", "2.0"), nil},
+	}
+	resolver := NewResolver(Packages{P("A", "1.0"), P("B", "2.0")}, index)
+	if err := resolver.WarmUp(); err != nil {
+		t.Fatal(err.Error())
+	}
+	if resolver.Solved() {
+		t.Fatal("Expected no solve before the first Resolve")
+	}
+	if conflicts := resolver.Conflicts(); len(conflicts) != 0 {
+		t.Fatalf("Expected no conflicts before the first Resolve, but got %s", conflicts)
+	}
+	if report := resolver.Report(); len(report.Conflicts) != 0 || len(report.Details) != 0 {
+		t.Fatalf("Expected no conflicts in the report before the first Resolve, but got %s", report.Conflicts)
+	}
+
+	if ok, err := resolver.Resolve(); err != nil {
+		t.Fatal(err.Error())
+	} else if ok {
+		t.Fatal("Resolver was expected to fail, but succeeded.")
+	}
+	expected, err := resolver.DetailedConflicts()
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	// Warming up after a solve keeps its conflicts
+	if err := resolver.WarmUp(); err != nil {
+		t.Fatal(err.Error())
+	}
+	if resolver.Solved() || resolver.Conflicts().String() != "A-1.0, B-2.0" {
+		t.Fatalf("Expected the failed solve to be restored, but got conflicts %s", resolver.Conflicts())
+	}
+	resolver.conflicts = nil
+	actual, err := resolver.DetailedConflicts()
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if actual.String() != expected.String() {
+		t.Fatalf("Expected the detailed conflicts:\n%s\n\ngot:\n%s", expected, actual)
+	}
+
+	resolver.SetTracing(false)
+	if err := resolver.WarmUp(); err == nil {
+		t.Fatal("Expected an error with tracing disabled")
+	}
+}
+
+func TestDetailedConflictsStable(t *testing.T) {
+	P := NewPackage
+
+	index := []Dependency{
+		{P("A", "1.0"), []Packages{{P("C", "1.0")}}},
+		{P("B", "1.0"), []Packages{{P("C", "2.0")}}},
+		{P("C", "1.0"), nil},
+		{P("C", "2.0"), nil},
+	}
+
+	var expected string
+	for i := 0; i < 10; i++ {
+		resolver := NewResolver(Packages{P("B", "1.0"), P("A", "1.0")}, index)
+		if ok, err := resolver.Resolve(); err != nil {
+			t.Fatal(err.Error())
+		} else if ok {
+			t.Fatal("Resolver was expected to fail, but succeeded.")
+		}
+		detailed, err := resolver.DetailedConflicts()
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		if i == 0 {
+			expected = detailed.String()
+		} else if actual := detailed.String(); actual != expected {
+			t.Fatalf("Expected the same detailed conflicts on every run:\n%s\n\ngot:\n%s", expected, actual)
+		}
+	}
+
+	lines := strings.Split(expected, "\n")
+	if len(lines) != 3 || !strings.HasPrefix(lines[0], "Package A-1.0 depends") ||
+		!strings.HasPrefix(lines[1], "Package B-1.0 depends") || !strings.Contains(lines[2], "conflicts with") {
+		t.Fatalf("Expected the dependencies sorted by name, followed by the conflict:\n%s", expected)
+	}
+}
+
+func TestClauseBreakdown(t *testing.T) {
+	P := NewPackage
+
+	index := []Dependency{
+		{P("A", "1.0"), []Packages{{P("B", "1.0"), P("B", "2.0")}, {P("C", "1.0")}}},
+		{P("A", "2.0"), []Packages{{P("B", "2.0")}}},
+		{P("B", "1.0"), nil},
+		{P("B", "2.0"), nil},
+		{P("B", "3.0"), nil},
+		{P("C", "1.0"), nil},
+		{P("D", "1.0"), nil},
+	}
+	resolver := NewResolver(Packages{P("A", AnyVersion)}, index)
+	resolver.SetConflictSource(func() [][2]Packager {
+		return [][2]Packager{{P("C", "1.0"), P("D", "1.0")}}
+	})
+	if err := resolver.Forbid(P("B", "3.0")); err != nil {
+		t.Fatal(err.Error())
+	}
+	if ok, err := resolver.Resolve(); err != nil {
+		t.Fatal(err.Error())
+	} else if !ok {
+		t.Fatal("Resolver was expected to succeed, but failed.")
+	}
+
+	breakdown := resolver.ClauseBreakdown()
+	expected := map[ClauseKind]int{
+		DependencyClause:       3,
+		VersionConflictClause:  4,
+		ExplicitConflictClause: 1,
+		ConstraintClause:       1,
+		RequirementClause:      1,
+	}
+	for kind, n := range expected {
+		if breakdown[kind] != n {
+			t.Errorf("Expected %d %s clauses, but got %d", n, kind, breakdown[kind])
+		}
+	}
+
+	total := 0
+	for _, n := range breakdown {
+		total += n
+	}
+	if added := resolver.solver.AddedOriginalClauses(); total != added {
+		t.Fatalf("Expected the breakdown %v to add up to %d clauses, but got %d", breakdown, added, total)
+	}
+}
@@ -0,0 +1,238 @@
+package pakr
+
+import (
+	"slices"
+	"sort"
+)
+
+// Describes the kind of change made by a Relaxation
+type RelaxationKind string
+
+const (
+	// Require another version of the same Product
+	ReplaceRequirement RelaxationKind = `ReplaceRequirement`
+	// Remove a requirement
+	DropRequirement RelaxationKind = `DropRequirement`
+	// Remove the minimum version of a Product
+	DropMinimumVersion RelaxationKind = `DropMinimumVersion`
+	// Allow a forbidden Package
+	AllowForbidden RelaxationKind = `AllowForbidden`
+	// Remove a pinned Package
+	Unpin RelaxationKind = `Unpin`
+	// Stop excluding a Product
+	IncludeProduct RelaxationKind = `IncludeProduct`
+	// Allow multiple versions of a Product in the same solution
+	AllowMultiple RelaxationKind = `AllowMultiple`
+)
+
+// The rank of each kind of Relaxation, from the least disruptive
+var relaxationRanks = map[RelaxationKind]int{
+	ReplaceRequirement: 0,
+	DropRequirement:    1,
+	DropMinimumVersion: 2,
+	AllowForbidden:     3,
+	Unpin:              4,
+	IncludeProduct:     5,
+	AllowMultiple:      6,
+}
+
+// A Relaxation is a single change to the requirements or permanent
+// constraints of a Resolver, that makes a failed resolve satisfiable
+type Relaxation struct {
+	Kind RelaxationKind
+	// The requirement, or constrained Package, that is relaxed.
+	// Nil for the kinds that relax a whole Product.
+	Package Packager
+	// The Package that replaces the requirement,
+	// for a ReplaceRequirement
+	Replacement Packager
+	// The Product that is relaxed
+	Product string
+}
+
+func (r Relaxation) String() string {
+	switch r.Kind {
+	case ReplaceRequirement:
+		return "Require " + r.Replacement.PackageName() + " instead of " + r.Package.PackageName()
+	case DropRequirement:
+		return "Drop the requirement " + r.Package.PackageName()
+	case DropMinimumVersion:
+		return "Drop the minimum version of product " + r.Product
+	case AllowForbidden:
+		return "Allow the forbidden package " + r.Package.PackageName()
+	case Unpin:
+		return "Unpin the package " + r.Package.PackageName()
+	case IncludeProduct:
+		return "Stop excluding product " + r.Product
+	case AllowMultiple:
+		return "Allow multiple versions of product " + r.Product
+	}
+	return string(r.Kind)
+}
+
+// If the previous call to Resolve() returned false, this method suggests
+// the changes that would each make the requirements satisfiable. Every
+// candidate is checked by resolving again with only that change applied:
+//
+//   - Require another version of a conflicting requirement
+//   - Drop a conflicting requirement
+//   - Drop a minimum version set with RequireAtLeast()
+//   - Allow a Package forbidden with Forbid(), or unpin one set with Pin()
+//   - Stop excluding a Product excluded with ExcludeProduct()
+//   - Allow multiple versions of a Product that conflicts with itself
+//
+// The Relaxations are ranked from the least disruptive, in the order of
+// the list above. Each check uses a new solver, so this is much more
+// expensive than Resolve().
+// Returns an empty list if the requirements are solved.
+func (r *Resolver) SuggestRelaxations() ([]Relaxation, error) {
+	if r.solver == nil || r.Solved() {
+		return []Relaxation{}, nil
+	}
+
+	var suggestions []Relaxation
+	try := func(relax Relaxation, mod func(tmp *Resolver)) error {
+		ok, err := r.relaxedSatisfiable(mod)
+		if ok {
+			suggestions = append(suggestions, relax)
+		}
+		return err
+	}
+
+	conflicts := r.Conflicts()
+	for _, req := range conflicts {
+		if req == nil {
+			continue
+		}
+		name := req.PackageName()
+
+		// Other versions of the same Product, from the lowest
+		if req.Version() != AnyVersion {
+			vers := Packages(r.prodMap.Packages(req.ProductName()))
+			sort.Slice(vers, func(i, j int) bool {
+				return CompareVersions(vers[i].Version(), vers[j].Version()) < 0
+			})
+			for _, ver := range vers {
+				if ver.PackageName() == name {
+					continue
+				}
+				err := try(Relaxation{ReplaceRequirement, req, ver, req.ProductName()}, func(tmp *Resolver) {
+					tmp.requires = append(withoutPackage(tmp.requires, name), ver)
+				})
+				if err != nil {
+					return nil, err
+				}
+			}
+		}
+
+		err := try(Relaxation{DropRequirement, req, nil, req.ProductName()}, func(tmp *Resolver) {
+			tmp.requires = withoutPackage(tmp.requires, name)
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	bounds := make([]string, 0, len(r.bounds))
+	for product := range r.bounds {
+		bounds = append(bounds, product)
+	}
+	sort.Strings(bounds)
+	for _, product := range bounds {
+		err := try(Relaxation{DropMinimumVersion, nil, nil, product}, func(tmp *Resolver) {
+			delete(tmp.bounds, product)
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	for _, p := range r.forbidden {
+		name := p.PackageName()
+		err := try(Relaxation{AllowForbidden, p, nil, p.ProductName()}, func(tmp *Resolver) {
+			tmp.forbidden = withoutPackage(tmp.forbidden, name)
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	for _, p := range r.pinned {
+		name := p.PackageName()
+		err := try(Relaxation{Unpin, p, nil, p.ProductName()}, func(tmp *Resolver) {
+			tmp.pinned = withoutPackage(tmp.pinned, name)
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	for i, product := range r.excluded {
+		i := i
+		err := try(Relaxation{IncludeProduct, nil, nil, product}, func(tmp *Resolver) {
+			tmp.excluded = append(tmp.excluded[:i:i], tmp.excluded[i+1:]...)
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// Products with versions that conflict with each other
+	seen := make(map[string]bool)
+	var products []string
+	if rels, err := r.DetailedConflicts(); err == nil {
+		for _, rel := range rels {
+			if rel.Relates != Conflicts || len(rel.Packages) < 2 {
+				continue
+			}
+			product := rel.Packages[0].ProductName()
+			if !seen[product] && !r.multi[product] {
+				seen[product] = true
+				products = append(products, product)
+			}
+		}
+	}
+	sort.Strings(products)
+	for _, product := range products {
+		err := try(Relaxation{AllowMultiple, nil, nil, product}, func(tmp *Resolver) {
+			if tmp.multi == nil {
+				tmp.multi = make(map[string]bool)
+			}
+			tmp.multi[product] = true
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	sort.SliceStable(suggestions, func(i, j int) bool {
+		return relaxationRanks[suggestions[i].Kind] < relaxationRanks[suggestions[j].Kind]
+	})
+	return suggestions, nil
+}
+
+// relaxedSatisfiable checks whether the requirements can be solved
+// by a new Resolver, configured like this Resolver and then changed
+// by a modifier function
+func (r *Resolver) relaxedSatisfiable(mod func(tmp *Resolver)) (bool, error) {
+	tmp := r.tempConfig(slices.Clone(r.allRequires()))
+	tmp.noTrace = true
+
+	mod(tmp)
+	if err := tmp.Initialize(); err != nil {
+		return false, err
+	}
+	return tmp.Resolve()
+}
+
+// withoutPackage returns a copy of the Packages,
+// without any Package with the given name
+func withoutPackage(pkgs Packages, name string) Packages {
+	out := make(Packages, 0, len(pkgs))
+	for _, p := range pkgs {
+		if p.PackageName() != name {
+			out = append(out, p)
+		}
+	}
+	return out
+}
@@ -0,0 +1,51 @@
+package pakr
+
+// SolveStats describes the size of the formula and the time
+// spent by the solver
+type SolveStats struct {
+	// The number of literals known to the solver
+	Variables int `json:"variables"`
+	// The number of clauses added to the solver
+	Clauses int `json:"clauses"`
+	// The total time spent by the solver, in seconds
+	Seconds float64 `json:"seconds"`
+}
+
+// SolveReport collects the results of the last call to Resolve()
+type SolveReport struct {
+	Solved bool `json:"solved"`
+	// The solution, if the requirements were solved
+	Solution Packages `json:"solution"`
+	// The requirements that caused the Resolver to fail,
+	// as reported by Conflicts()
+	Conflicts Packages `json:"conflicts,omitempty"`
+	// The relations of the conflict, as reported by DetailedConflicts().
+	// They are not available if tracing is disabled, or the solver
+	// was released with Compact().
+	Details PackageRelations `json:"details,omitempty"`
+	// The statistics of the solver, which are not
+	// available if the solver was released with Compact()
+	Stats SolveStats `json:"stats"`
+}
+
+// Report returns the results of the last call to Resolve(), including
+// the solution or the conflicts and statistics about the solver, in a
+// single value that can be serialized directly.
+func (r *Resolver) Report() SolveReport {
+	report := SolveReport{Solved: r.Solved()}
+	if report.Solved {
+		report.Solution = r.Solution()
+	} else if r.solver != nil || r.compacted != nil {
+		report.Conflicts = r.Conflicts()
+		report.Details, _ = r.DetailedConflicts()
+	}
+
+	if r.solver != nil {
+		report.Stats = SolveStats{
+			Variables: r.solver.Variables(),
+			Clauses:   r.solver.AddedOriginalClauses(),
+			Seconds:   r.solver.Seconds().Seconds(),
+		}
+	}
+	return report
+}
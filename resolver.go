@@ -6,7 +6,11 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"maps"
+	"math"
 	"math/big"
+	"math/rand"
+	"slices"
 	"sort"
 	"strconv"
 	"strings"
@@ -26,8 +30,17 @@ const (
 	ResolveSortLow
 	// Sort packages to prefer higher versions first
 	ResolveSortHigh
+	// Sort packages to prefer the versions with the same major version
+	// as the preferred versions set by SetSameMajorVersions(), from the
+	// highest, and then higher versions first
+	ResolveSortSameMajor
 )
 
+// The default number of versions of a Product, above which the conflicts
+// between its versions use a compact encoding. See
+// SetConflictEncodingThreshold().
+const defaultConflictThreshold = 6
+
 // A Resolver attempts to solve a package solution from
 // a given set of constraints and assumptions for a package
 // index list
@@ -36,32 +49,79 @@ type Resolver struct {
 	idMap     *stringIdMap
 	prodMap   *ProductMap
 	sortMode  resolveSort
+	seed      *int64
+	majors    map[string]string
 	index     []Dependency
+	ownIndex  bool
+	frozen    *frozenFormula
+	source    IndexSource
 	requires  Packages
+	temps     Packages
+	assumed   []pigosat.Literal
+	forced    map[pigosat.Literal]pigosat.Literal
+	units     map[string][]string
+	frames    []Packages
+	wildcards map[string]*wildcard
+	groups    map[*oneOfRequirement]pigosat.Literal
+	prefs     map[string]int
+	priority  map[string]int
+	important map[string]int
+	costs     map[string]int
+	baseCost  *int
+	bounds    map[string]string
+	floors    map[string]string
+	replaces  map[string]Packager
+	presolve  bool
+	external  bool
+	multi     map[string]bool
+	noTrace   bool
+	propLimit uint64
+	warnings  []string
+	forbidden Packages
+	excluded  []string
+	pinned    Packages
+	exactly   []exactlyN
+	ignored   []ignoredEdge
+	pairs     func() [][2]Packager
+	amoLimit  *int
+	amo       map[pigosat.Literal]string
+	kinds     map[ClauseKind]int
+	onFail    func(Packages, PackageRelations)
+	compacted *compactResult
 	solution  Packages
 	conflicts []*PackageRelation
 }
 
 // NewResolver creates a new Resolver, from a given package dependency list
+// Panics if the package index is invalid, such as two different Packages
+// sharing the same PackageName. Use NewResolverE() to get the error instead.
 func NewResolver(requires Packages, index []Dependency) *Resolver {
-	r := &Resolver{requires: requires, index: index}
-	if err := r.Initialize(); err != nil {
-		// Getting an error here means something is seriously wrong
-		// with the pigosat library support
+	r, err := NewResolverE(requires, index)
+	if err != nil {
 		panic(err)
 	}
 	return r
 }
 
+// NewResolverE creates a new Resolver, from a given package dependency list
+// Returns a non-nil error if the package index is invalid, such as two
+// different Packages sharing the same PackageName.
+func NewResolverE(requires Packages, index []Dependency) (*Resolver, error) {
+	r := &Resolver{requires: requires, index: index}
+	if err := r.Initialize(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
 // NewSortResolver creates a new Resolver, from a given package dependency list.
 // Specify a sort order operation to apply to the packages when
 // intializing the index. Sort order affects the preference in
 // choosing higher vs lower version packages in the solution.
+// Panics if the package index is invalid, as with NewResolver().
 func NewSortResolver(requires Packages, index []Dependency, sortMode resolveSort) *Resolver {
 	r := &Resolver{requires: requires, index: index, sortMode: sortMode}
 	if err := r.Initialize(); err != nil {
-		// Getting an error here means something is seriously wrong
-		// with the pigosat library support
 		panic(err)
 	}
 	return r
@@ -69,31 +129,49 @@ func NewSortResolver(requires Packages, index []Dependency, sortMode resolveSort
 
 // Set the package dependency list.
 // Resets the internal solver and state.
-func (r *Resolver) SetRequirements(requires Packages) {
+// Returns a non-nil error if the Resolver could not be initialized.
+func (r *Resolver) SetRequirements(requires Packages) error {
 	r.requires = requires
-	if err := r.Initialize(); err != nil {
-		// Getting an error here means something is seriously wrong
-		// with the pigosat library support
-		panic(err)
-	}
+	return r.Initialize()
 }
 
 // Set the package dependency list.
+// Replaces the formula of a Resolver loaded with LoadFrozen().
 // Resets the internal solver and state.
-func (r *Resolver) SetPackageIndex(index []Dependency) {
+// Returns a non-nil error if the package index is invalid, such as two
+// different Packages sharing the same PackageName, in which case the
+// Resolver keeps its previous index.
+func (r *Resolver) SetPackageIndex(index []Dependency) error {
+	prevIndex, prevOwn, prevFrozen, prevSource := r.index, r.ownIndex, r.frozen, r.source
 	r.index = index
+	r.ownIndex = false
+	r.frozen = nil
+	r.source = nil
 	if err := r.Initialize(); err != nil {
-		// Getting an error here means something is seriously wrong
-		// with the pigosat library support
-		panic(err)
+		r.index, r.ownIndex, r.frozen, r.source = prevIndex, prevOwn, prevFrozen, prevSource
+		r.reinitialize()
+		return err
 	}
+	return nil
+}
+
+// Set the package dependency list from multiple layers of indexes,
+// such as a base index and an overlay index. See LayerIndexes() for
+// how the layers are combined.
+// Resets the internal solver and state.
+// Returns a non-nil error if the layered index is invalid,
+// as with SetPackageIndex().
+func (r *Resolver) SetLayeredIndex(layers ...[]Dependency) error {
+	return r.SetPackageIndex(LayerIndexes(layers...))
 }
 
 // Resets all internal state, and initializes based
 // on the currently set package dependency requirements
 // This gets called automatically when calling SetRequirements()
+// Returns a non-nil error if the index is invalid, such as
+// two different Packages sharing the same PackageName.
 func (r *Resolver) Initialize() error {
-	opts := &pigosat.Options{EnableTrace: true}
+	opts := &pigosat.Options{EnableTrace: !r.noTrace, PropagationLimit: r.propLimit}
 
 	var err error
 	r.solver, err = pigosat.New(opts)
@@ -103,24 +181,276 @@ func (r *Resolver) Initialize() error {
 
 	r.idMap = newStringIdMap()
 	r.prodMap = NewProductMap()
-
-	if r.index == nil {
+	r.compacted = nil
+	r.temps = nil
+	r.assumed = nil
+	r.forced = nil
+	r.units = nil
+	r.warnings = nil
+	r.wildcards = nil
+	r.groups = nil
+	r.amo = nil
+	r.kinds = nil
+
+	if r.index == nil && r.frozen == nil && r.source == nil {
 		return nil
 	}
 
+	clauses, err := r.buildFormula()
+	if err != nil {
+		return err
+	}
+
+	// Hint the solver at the size of variables, since we
+	// just built up a Package index.
+	r.solver.Adjust(r.idMap.Len())
+
+	// Now actually add all the clauses that we had built up,
+	// into the solver.
+	// fmt.Printf("Clauses: %v\n", clauses)
+	r.solver.AddClauses(clauses)
+
+	// fmt.Printf("# variables == %d\n", r.solver.Variables())
+	// fmt.Printf("# clauses == %d\n", r.solver.AddedOriginalClauses())
+
+	return nil
+}
+
+// reinitialize performs an Initialize() after a change to the
+// configuration of the Resolver. The package index is validated when it
+// is set, and the constraints skip the Packages that are not in it, so
+// an error here means the solver itself failed, and panics.
+func (r *Resolver) reinitialize() {
+	if err := r.Initialize(); err != nil {
+		panic(err)
+	}
+}
+
+// buildFormula maps every Package in the index to a literal id,
+// and builds the SAT clauses for the dependencies and multi-version
+// conflicts, followed by the permanent constraints. A Resolver loaded
+// with LoadFrozen() uses its frozen clauses in place of the index.
+// The clauses are generated in a deterministic order for
+// the same index and sort mode.
+func (r *Resolver) buildFormula() (pigosat.Formula, error) {
+	idMap := r.idMap
+	prodMap := r.prodMap
+
+	var (
+		clauses pigosat.Formula
+		err     error
+	)
+	if r.frozen != nil {
+		clauses, err = r.frozen.load(idMap, prodMap)
+		r.amo = r.frozen.amo
+		r.tally(FrozenClause, len(clauses))
+	} else {
+		clauses, err = r.indexClauses()
+	}
+	if err != nil {
+		return nil, err
+	}
+	start := len(clauses)
+
+	// Add the minimum version requirements
+	names := make([]string, 0, len(r.bounds))
+	for name := range r.bounds {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		if clause, err := r.atLeastClause(name, r.bounds[name]); err == nil {
+			clauses = append(clauses, clause)
+		}
+	}
+
+	// Add the pinned and forbidden Packages as unit clauses
+	for _, p := range r.pinned {
+		if id, err := idMap.GetId(packageKey(p)); err == nil {
+			clauses = append(clauses, []pigosat.Literal{id})
+		}
+	}
+	for _, p := range r.forbidden {
+		if id, err := idMap.GetId(packageKey(p)); err == nil {
+			clauses = append(clauses, []pigosat.Literal{-id})
+		}
+	}
+	floors := make([]string, 0, len(r.floors))
+	for product := range r.floors {
+		floors = append(floors, product)
+	}
+	sort.Strings(floors)
+	for _, product := range floors {
+		clauses = append(clauses, r.floorClauses(product, r.floors[product])...)
+	}
+	for _, product := range r.excluded {
+		clauses = append(clauses, r.excludeClauses(product)...)
+	}
+	for _, group := range r.exactly {
+		more, err := r.exactlyClauses(group.pkgs, group.n)
+		if err != nil {
+			return nil, err
+		}
+		clauses = append(clauses, more...)
+	}
+
+	r.tally(ConstraintClause, len(clauses)-start)
+
+	// Add the externally supplied conflicts
+	if r.pairs != nil {
+		for _, pair := range r.pairs() {
+			a, errA := idMap.GetId(packageKey(pair[0]))
+			b, errB := idMap.GetId(packageKey(pair[1]))
+			if errA == nil && errB == nil && a != b {
+				clauses = append(clauses, []pigosat.Literal{-a, -b})
+				r.tally(ExplicitConflictClause, 1)
+			}
+		}
+	}
+
+	return clauses, nil
+}
+
+// Set the preferred versions of the ResolveSortSameMajor sort mode.
+// The versions of a Product with the same major version as its preferred
+// version, such as the currently installed version, are preferred over
+// the other versions, to avoid breaking upgrades. Within each group,
+// higher versions are preferred. Products without a preferred version
+// prefer higher versions, as with ResolveSortHigh.
+// Resets the internal solver and state.
+func (r *Resolver) SetSameMajorVersions(preferred Packages) {
+	r.majors = make(map[string]string, len(preferred))
+	for _, p := range preferred {
+		r.majors[p.ProductName()] = majorVersion(p.Version())
+	}
+	r.reinitialize()
+}
+
+// compareSameMajor orders two Packages like comparePackages(), except
+// that the versions of a Product with the same major version as its
+// preferred version are ordered after its other versions
+func (r *Resolver) compareSameMajor(a, b Packager) int {
+	major, ok := r.majors[a.ProductName()]
+	if ok && a.ProductName() == b.ProductName() {
+		inA := majorVersion(a.Version()) == major
+		inB := majorVersion(b.Version()) == major
+		if inA != inB {
+			if inA {
+				return 1
+			}
+			return -1
+		}
+	}
+	return comparePackages(a, b)
+}
+
+// Set the number of versions of a Product, above which the conflicts
+// between its versions are built with a compact encoding, instead of a
+// clause for every pair of versions. The compact encoding grows linearly
+// with the number of versions, using auxiliary variables that are never
+// part of the Solution(), while the pairwise clauses grow with the square
+// of the number of versions, but are faster to propagate for a small
+// number of versions. The default threshold is 6. A negative threshold
+// always uses the pairwise clauses.
+// Conflicts of either encoding are reported by DetailedConflicts() as
+// Conflicts relations.
+// Resets the internal solver and state.
+func (r *Resolver) SetConflictEncodingThreshold(n int) {
+	r.amoLimit = &n
+	r.reinitialize()
+}
+
+// conflictThreshold returns the number of versions of a Product,
+// above which the conflicts use the compact encoding, or -1 to always
+// use the pairwise clauses
+func (r *Resolver) conflictThreshold() int {
+	if r.amoLimit == nil {
+		return defaultConflictThreshold
+	}
+	if *r.amoLimit < 0 {
+		return math.MaxInt
+	}
+	return *r.amoLimit
+}
+
+// atMostOneClauses builds the compact encoding of the conflicts between
+// the versions of a Product, and records its auxiliary literals so that
+// a conflict can be reported for the Product
+func (r *Resolver) atMostOneClauses(product string, ids []pigosat.Literal) pigosat.Formula {
+	if r.amo == nil {
+		r.amo = make(map[pigosat.Literal]string)
+	}
+	return atMostK(ids, 1, func() pigosat.Literal {
+		lit := r.idMap.NewAux()
+		r.amo[lit] = product
+		return lit
+	})
+}
+
+// Set the seed of a random order of the Packages in the solver, to vary
+// the choices between equally preferred Packages across Resolvers, while
+// keeping them repeatable: the same seed chooses the same solution for the
+// same index and requirements, and different seeds may choose different
+// solutions. The order of the versions of each Product that is set by the
+// sort mode is kept, so only the free choices are affected, such as which
+// of several Products to satisfy a requires-group with, or any version
+// when there is no sort mode. Choices that are constrained by the index or
+// the requirements are not affected. It has no effect on a Resolver loaded
+// with LoadFrozen().
+// Resets the internal solver and state.
+func (r *Resolver) SetSeed(seed int64) {
+	r.seed = &seed
+	r.reinitialize()
+}
+
+// seededOrder shuffles the Products of a list of Packages, that is sorted
+// by Product, with the seed set by SetSeed(). The versions of each Product
+// stay together, and are only shuffled when there is no sort mode.
+func (r *Resolver) seededOrder(pkgs Packages) Packages {
+	rnd := rand.New(rand.NewSource(*r.seed))
+
+	var products []Packages
+	for i, p := range pkgs {
+		if i == 0 || p.ProductName() != pkgs[i-1].ProductName() {
+			products = append(products, nil)
+		}
+		products[len(products)-1] = append(products[len(products)-1], p)
+	}
+	rnd.Shuffle(len(products), func(i, j int) { products[i], products[j] = products[j], products[i] })
+
+	ordered := make(Packages, 0, len(pkgs))
+	for _, vers := range products {
+		if r.sortMode == ResolveSortNone {
+			rnd.Shuffle(len(vers), func(i, j int) { vers[i], vers[j] = vers[j], vers[i] })
+		}
+		ordered = append(ordered, vers...)
+	}
+	return ordered
+}
+
+// indexClauses maps every Package in the index to a literal id, and
+// builds the clauses for the dependencies and multi-version conflicts
+func (r *Resolver) indexClauses() (pigosat.Formula, error) {
 	idMap := r.idMap
 	prodMap := r.prodMap
 
 	// Preload the stringIdMap
-	if r.sortMode != ResolveSortNone {
-		flat := flattenDependencies(r.index)
-		if r.sortMode == ResolveSortLow {
-			sort.Sort(sort.Reverse(flat))
-		} else {
-			sort.Sort(flat)
+	if r.sortMode != ResolveSortNone || r.seed != nil {
+		flat := flattenDependencies(r.dependencies())
+		switch r.sortMode {
+		case ResolveSortLow:
+			sort.SliceStable(flat, func(i, j int) bool { return compareSortOrder(flat[i], flat[j]) > 0 })
+		case ResolveSortSameMajor:
+			sort.SliceStable(flat, func(i, j int) bool { return r.compareSameMajor(flat[i], flat[j]) < 0 })
+		default:
+			sort.SliceStable(flat, func(i, j int) bool { return compareSortOrder(flat[i], flat[j]) < 0 })
+		}
+		if r.seed != nil {
+			flat = r.seededOrder(flat)
 		}
 		for _, pack := range flat {
-			idMap.StringToId(pack.PackageName())
+			idMap.StringToId(packageKey(pack))
 		}
 	}
 
@@ -130,139 +460,2570 @@ func (r *Resolver) Initialize() error {
 	tid := pigosat.Literal(0)
 	cid := pigosat.Literal(0)
 
+	// Track the defined targets, and the first Package referring
+	// to each required Package, to warn about undefined Packages
+	targets := make(map[string]bool)
+	referrers := make(map[string]string)
+	refs := []string{}
+
 	// Add unit clauses and variable constraints
-	for _, dep := range r.index {
-		tid = idMap.StringToId(dep.Target.PackageName())
-		prodMap.Add(dep.Target)
+	for dep := range r.dependencies() {
+		tid = idMap.StringToId(packageKey(dep.Target))
+		if err := prodMap.add(dep.Target); err != nil {
+			return nil, err
+		}
+		targets[packageKey(dep.Target)] = true
 
 		if dep.Requires == nil {
 			continue
 		}
 
 		for _, constraints := range dep.Requires {
+			if r.isIgnored(dep.Target, constraints) {
+				continue
+			}
+
 			// Add variable constraints
 			clause := make([]pigosat.Literal, len(constraints)+1)
 			clause[0] = -tid
 
 			for i, ver := range constraints {
-				cid = idMap.StringToId(ver.PackageName())
+				cid = idMap.StringToId(packageKey(ver))
 				clause[i+1] = cid
-				prodMap.Add(ver)
+				if err := prodMap.add(ver); err != nil {
+					return nil, err
+				}
+
+				if key := packageKey(ver); referrers[key] == "" {
+					referrers[key] = dep.Target.PackageName()
+					refs = append(refs, key)
+				}
+				for _, prev := range clause[1 : i+1] {
+					if prev == cid {
+						r.warnings = append(r.warnings, fmt.Sprintf(
+							"Package %s lists %s more than once in a requires-group",
+							dep.Target.PackageName(), ver.PackageName()))
+						break
+					}
+				}
 			}
 
 			clauses = append(clauses, clause)
 		}
 	}
+	r.tally(DependencyClause, len(clauses))
+
+	// Products with at least one version defined in the index,
+	// and the external Products that are assumed to be available
+	defined := make(map[string]bool)
+	externals := make(map[string]bool)
+	for name := range targets {
+		p, _ := prodMap.PackageByName(name)
+		defined[p.ProductName()] = true
+	}
+
+	for _, ref := range refs {
+		if targets[ref] {
+			continue
+		}
+		p, _ := prodMap.PackageByName(ref)
+		if r.external && !defined[p.ProductName()] {
+			// An external Package that is assumed to be available
+			externals[p.ProductName()] = true
+			clauses = append(clauses, []pigosat.Literal{idMap.StringToId(ref)})
+			r.tally(ConstraintClause, 1)
+			continue
+		}
+		r.warnings = append(r.warnings, fmt.Sprintf(
+			"Package %s is required by %s, but is not defined in the index", p.PackageName(), referrers[ref]))
+	}
+
+	// Now add multi-version conflicts, walking the products
+	// in a stable order
+	start := len(clauses)
+	names := make([]string, 0, len(prodMap.prods))
+	for name := range prodMap.prods {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		vers := prodMap.Packages(name)
+		if vers == nil {
+			return nil, fmt.Errorf("Resolve init failure: Version list for product %q was nil", name)
+		}
+		if len(vers) == 1 && !externals[name] {
+			r.warnings = append(r.warnings, fmt.Sprintf(
+				"Product %s has only one version, %s", name, vers[0].PackageName()))
+		}
+
+		if r.multi[name] {
+			continue
+		}
+
+		ids := packagesToIds(vers, idMap)
+		sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+		if len(ids) > r.conflictThreshold() {
+			clauses = append(clauses, r.atMostOneClauses(name, ids)...)
+			continue
+		}
+		for _, conflict := range buildConflictClauses(ids) {
+			clauses = append(clauses, conflict)
+		}
+	}
+	r.tally(VersionConflictClause, len(clauses)-start)
+
+	return clauses, nil
+}
+
+// forcedIds performs unit propagation over the package index,
+// starting from the requirements. When a required Package has a
+// requires-group with exactly one member, that member is forced to
+// be part of any solution, along with its own forced members.
+// Returns the literal id of each forced Package, in the order they
+// are found, and the literal id of the requirement that forces each one.
+func (r *Resolver) forcedIds(requires Packages) ([]pigosat.Literal, map[pigosat.Literal]pigosat.Literal) {
+	if r.units == nil {
+		r.units = make(map[string][]string)
+		for dep := range r.dependencies() {
+			name := packageKey(dep.Target)
+			for _, vers := range dep.Requires {
+				if len(vers) == 1 && !r.isIgnored(dep.Target, vers) {
+					r.units[name] = append(r.units[name], packageKey(vers[0]))
+				}
+			}
+		}
+	}
+
+	type edge struct {
+		name   string
+		origin pigosat.Literal
+	}
+	queue := make([]edge, 0, len(requires))
+	for _, p := range requires {
+		if _, ok := p.(*oneOfRequirement); ok || p.Version() == AnyVersion {
+			continue
+		}
+		name := packageKey(r.replacement(p))
+		queue = append(queue, edge{name, r.idMap.StringToId(name)})
+	}
+
+	var ids []pigosat.Literal
+	origins := make(map[pigosat.Literal]pigosat.Literal)
+	for len(queue) > 0 {
+		e := queue[0]
+		queue = queue[1:]
+
+		for _, member := range r.units[e.name] {
+			id := r.idMap.StringToId(member)
+			if _, ok := origins[id]; ok {
+				continue
+			}
+			origins[id] = e.origin
+			ids = append(ids, id)
+			queue = append(queue, edge{member, e.origin})
+		}
+	}
+	return ids, origins
+}
+
+// Set a source of conflicting pairs of Packages, that can not be part of
+// the same solution, in addition to the automatic conflicts between the
+// versions of the same Product. This allows conflicts to be declared
+// separately from the package index, such as from an external feed.
+// The source is called whenever the Resolver is initialized, so that
+// updated conflicts are picked up by the next Initialize(). Pairs with
+// a Package that is not known to the Resolver are ignored. The conflicts
+// are reported by DetailedConflicts() as Conflicts relations.
+// A nil source removes the external conflicts.
+// Resets the internal solver and state.
+func (r *Resolver) SetConflictSource(source func() [][2]Packager) {
+	r.pairs = source
+	r.reinitialize()
+}
+
+// Set a callback that is called whenever Resolve() or SolveIncremental()
+// fails to satisfy the requirements, such as to log or alert about
+// conflicts in one place instead of after every resolve. It is called
+// with the Conflicts() and the DetailedConflicts() of the failed solve.
+// The details are only parsed when a callback is set, and are nil if
+// tracing was disabled with SetTracing().
+// A nil callback removes it.
+func (r *Resolver) OnConflict(callback func(conflicts Packages, details PackageRelations)) {
+	r.onFail = callback
+}
+
+// conflicted calls the OnConflict() callback after a failed solve
+func (r *Resolver) conflicted() {
+	if r.onFail == nil {
+		return
+	}
+	details, _ := r.DetailedConflicts()
+	r.onFail(r.Conflicts(), details)
+}
+
+// An ignoredEdge is a dependency of a target on a Product,
+// that is left out of the formula
+type ignoredEdge struct {
+	target  string
+	product string
+}
+
+// Ignore the dependencies of a target on a Product, as if they were not
+// in the package index, to find out whether that dependency causes a
+// conflict. Every requires-group of the target that refers to a version
+// of the Product is dropped. The target may be a PackageName, or a
+// product name to ignore the dependency for every version of the target.
+// The dependencies are ignored until ClearIgnored() is called.
+// Has no effect on a Resolver loaded with LoadFrozen(), where the
+// dependencies are already compiled into the formula.
+// Resets the internal solver and state.
+func (r *Resolver) IgnoreDependency(target, dependencyProduct string) {
+	r.ignored = append(r.ignored, ignoredEdge{target, dependencyProduct})
+	r.reinitialize()
+}
+
+// Clear the dependencies ignored with IgnoreDependency(),
+// restoring the full package index.
+// Resets the internal solver and state.
+func (r *Resolver) ClearIgnored() {
+	r.ignored = nil
+	r.reinitialize()
+}
+
+// isIgnored returns true if a requires-group of a target
+// is dropped by IgnoreDependency()
+func (r *Resolver) isIgnored(target Packager, vers Packages) bool {
+	for _, edge := range r.ignored {
+		if edge.target != target.PackageName() && edge.target != target.ProductName() {
+			continue
+		}
+		for _, ver := range vers {
+			if ver.ProductName() == edge.product {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Enables or disables a pre-solve pass, which finds the Packages that are
+// forced by the requirements before invoking the solver. A requirement
+// with a requires-group of exactly one member forces that member, and so
+// on through its own dependencies. Forced Packages are assumed on each
+// solve along with the requirements, which can greatly reduce the search
+// on large indexes with long dependency chains. It does not change which
+// solutions are valid. A conflict caused by a forced Package is reported
+// by Conflicts() as the requirement that forces it.
+// Resets the internal solver and state.
+func (r *Resolver) SetPresolve(enabled bool) {
+	r.presolve = enabled
+	r.reinitialize()
+}
+
+// Allow multiple versions of a Product to be part of the same solution,
+// by not adding the automatic conflicts between its versions. This is
+// only useful for indexes where the versions of a Product can be
+// installed side by side. The selected versions can be found with
+// SolutionVersions().
+// Has no effect on a Resolver loaded with LoadFrozen(), where the
+// conflicts are already compiled into the formula.
+// Resets the internal solver and state.
+func (r *Resolver) AllowMultipleVersions(product string) {
+	r.SetCoInstallable(product, true)
+}
+
+// Set whether the versions of a Product are co-installable, meaning that
+// they can be installed side by side, so that more than one of them can be
+// part of the same solution, as with AllowMultipleVersions(). Setting it
+// to false restores the default, where the versions of a Product conflict.
+// Has no effect on a Resolver loaded with LoadFrozen().
+// Resets the internal solver and state.
+func (r *Resolver) SetCoInstallable(product string, co bool) {
+	if co {
+		if r.multi == nil {
+			r.multi = make(map[string]bool)
+		}
+		r.multi[product] = true
+	} else {
+		delete(r.multi, product)
+	}
+	r.reinitialize()
+}
+
+// Enables or disables assuming that external Packages are available.
+// An external Package is referred to by a requires-group, but its Product
+// has no versions defined in the package index, such as a system package
+// that is intentionally left out of the index. When enabled, external
+// Packages are always part of the solution, so that they never block a
+// solve, and they are not reported by Warnings(). When disabled, which is
+// the default, they are free to be chosen like any other Package.
+// A Package that is not defined, but whose Product has other versions in
+// the index, is not external.
+// Resets the internal solver and state.
+func (r *Resolver) AssumeExternalAvailable(enabled bool) {
+	r.external = enabled
+	r.reinitialize()
+}
+
+// Enables or disables tracing in the solver, which is enabled by default.
+// Tracing is required to report DetailedConflicts(), but adds overhead to
+// every solve. Disabling it can speed up workloads where most resolves
+// succeed, or where only Conflicts() is needed to report a failure.
+// Resets the internal solver and state.
+func (r *Resolver) SetTracing(enabled bool) {
+	r.noTrace = !enabled
+	r.reinitialize()
+}
+
+// tempResolver creates a new initialized Resolver, sharing the
+// package index and configuration of this Resolver, but with a
+// different set of requirements.
+func (r *Resolver) tempResolver(requires Packages) (*Resolver, error) {
+	tmp := r.tempConfig(requires)
+	if err := tmp.Initialize(); err != nil {
+		return nil, err
+	}
+	return tmp, nil
+}
+
+// tempConfig creates a new Resolver that is not yet initialized,
+// with a copy of the package index and configuration of this Resolver,
+// but with a different set of requirements.
+func (r *Resolver) tempConfig(requires Packages) *Resolver {
+	c := r.copyConfig()
+	c.requires = requires
+	return c
+}
+
+// copyConfig creates a new Resolver that is not yet initialized, with a
+// copy of the package index, requirements, sort mode, preferences and all
+// permanent constraints of this Resolver, so that either one can be
+// changed without affecting the other. This is the single list of the
+// configuration of a Resolver, that every new field of it belongs to.
+// The solver state, the temporary requirements and the conflict
+// callback are not copied.
+func (r *Resolver) copyConfig() *Resolver {
+	c := &Resolver{
+		requires:  slices.Clone(r.requires),
+		index:     slices.Clone(r.index),
+		ownIndex:  true,
+		frozen:    r.frozen,
+		source:    r.source,
+		sortMode:  r.sortMode,
+		seed:      r.seed,
+		majors:    maps.Clone(r.majors),
+		prefs:     maps.Clone(r.prefs),
+		priority:  maps.Clone(r.priority),
+		important: maps.Clone(r.important),
+		costs:     maps.Clone(r.costs),
+		bounds:    maps.Clone(r.bounds),
+		floors:    maps.Clone(r.floors),
+		replaces:  maps.Clone(r.replaces),
+		presolve:  r.presolve,
+		external:  r.external,
+		multi:     maps.Clone(r.multi),
+		noTrace:   r.noTrace,
+		propLimit: r.propLimit,
+		forbidden: slices.Clone(r.forbidden),
+		excluded:  slices.Clone(r.excluded),
+		pinned:    slices.Clone(r.pinned),
+		exactly:   slices.Clone(r.exactly),
+		ignored:   slices.Clone(r.ignored),
+		pairs:     r.pairs,
+		amoLimit:  r.amoLimit,
+	}
+	if r.baseCost != nil {
+		base := *r.baseCost
+		c.baseCost = &base
+	}
+	return c
+}
+
+// Clone creates a new Resolver with its own solver, that is configured
+// identically to this Resolver. The package index, requirements, sort mode,
+// and all permanent constraints (such as minimum versions, pinned and
+// forbidden Packages, and excluded Products) are copied and applied to
+// the new solver, along with the callback of OnConflict().
+// The last solution and temporary requirements are not copied.
+func (r *Resolver) Clone() (*Resolver, error) {
+	c := r.copyConfig()
+	c.onFail = r.onFail
+	if err := c.Initialize(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// Returns the warnings about the package index, that were found during
+// the last call to Initialize(). Warnings describe problems that do not
+// prevent resolving, but may not be intended by the author of the index:
+// a requires-group referring to a Package that is not defined in the index,
+// a Product with only one version, or a Package listed more than once in
+// the same requires-group.
+func (r *Resolver) Warnings() []string {
+	return r.warnings
+}
+
+// Returns the number of versions of each Product known to the Resolver
+func (r *Resolver) ProductVersionCount() map[string]int {
+	if r.solver == nil {
+		return map[string]int{}
+	}
+	counts := make(map[string]int, r.prodMap.NumProducts())
+	for name, vers := range r.prodMap.prods {
+		counts[name] = len(vers)
+	}
+	return counts
+}
+
+// The kind of a clause in the formula of a Resolver,
+// as counted by ClauseBreakdown()
+type ClauseKind string
+
+const (
+	// The requires-groups of the package index
+	DependencyClause ClauseKind = `Dependency`
+	// The conflicts between the versions of each Product,
+	// including the clauses of the compact encoding
+	VersionConflictClause ClauseKind = `VersionConflict`
+	// The conflicts supplied by SetConflictSource()
+	ExplicitConflictClause ClauseKind = `ExplicitConflict`
+	// The permanent constraints, such as pinned and forbidden Packages,
+	// minimum versions, excluded Products, and external Packages
+	ConstraintClause ClauseKind = `Constraint`
+	// The clauses that stand for wildcard and RequireOneOf() requirements
+	RequirementClause ClauseKind = `Requirement`
+	// The clauses of a Resolver loaded with LoadFrozen(),
+	// which are not broken down any further
+	FrozenClause ClauseKind = `Frozen`
+)
+
+// tally counts a number of clauses of a kind for ClauseBreakdown()
+func (r *Resolver) tally(kind ClauseKind, n int) {
+	if n == 0 {
+		return
+	}
+	if r.kinds == nil {
+		r.kinds = make(map[ClauseKind]int)
+	}
+	r.kinds[kind] += n
+}
+
+// addClauses adds clauses of a kind to the solver, and counts them
+func (r *Resolver) addClauses(kind ClauseKind, clauses pigosat.Formula) {
+	r.tally(kind, len(clauses))
+	r.solver.AddClauses(clauses)
+}
+
+// Returns the exact number of clauses in the solver by their kind, since
+// the Resolver was last initialized, including the clauses that were added
+// since then, such as by Forbid() or AddDependency(). Kinds without any
+// clauses are left out. The counts add up to the number of clauses added
+// to the solver, which shows whether the cost of a package index is driven
+// by its dependencies or by Products with many versions.
+func (r *Resolver) ClauseBreakdown() map[ClauseKind]int {
+	counts := make(map[ClauseKind]int, len(r.kinds))
+	for kind, n := range r.kinds {
+		counts[kind] = n
+	}
+	return counts
+}
+
+// Returns an estimate of the number of clauses that the package index
+// generates, which dominates the memory used by the solver: a clause
+// for every requires-group, and a conflict clause for every pair of
+// versions of the same Product. The pairwise conflicts grow with the
+// square of the number of versions of a Product, up to the threshold of
+// SetConflictEncodingThreshold(), above which the compact encoding grows
+// linearly. The clauses of the
+// permanent constraints are not included. For a Resolver loaded with
+// LoadFrozen(), this is the number of frozen clauses.
+func (r *Resolver) EstimatedClauseCount() int64 {
+	if r.frozen != nil {
+		return int64(len(r.frozen.clauses))
+	}
+
+	var count int64
+	for dep := range r.dependencies() {
+		for _, vers := range dep.Requires {
+			if !r.isIgnored(dep.Target, vers) {
+				count++
+			}
+		}
+	}
+	threshold := r.conflictThreshold()
+	for name, n := range r.ProductVersionCount() {
+		switch {
+		case n < 2 || r.multi[name]:
+		case n > threshold:
+			// The sequential counter of the compact encoding
+			count += int64(3*n - 4)
+		default:
+			count += numCombos(int64(n))
+		}
+	}
+	return count
+}
+
+// AddDependency incrementally adds a Dependency to the package index,
+// without resetting the solver through a full Initialize().
+// The clauses for the requires-groups of the Dependency are added to
+// the existing solver, along with multi-version conflict clauses between
+// any newly seen Packages and the existing versions of their Product.
+// The permanent constraints are applied to the new Packages as well,
+// as with a full Initialize(): replacements, ignored dependencies,
+// pinned, forbidden and excluded Packages, security floors, and the
+// conflicting pairs of a conflict source. When a new version widens a
+// minimum version set with RequireAtLeast(), or external Packages are
+// assumed with AssumeExternalAvailable(), the Resolver is initialized
+// again instead, keeping the temporary requirements.
+//
+// Dependencies can only be added this way. Removing or replacing a
+// Dependency requires setting a new index with SetPackageIndex().
+// Returns a non-nil error for a Resolver loaded with LoadFrozen(),
+// which has no package index to add to.
+func (r *Resolver) AddDependency(dep Dependency) error {
+	if r.solver == nil {
+		return errors.New("Solver not initialized.")
+	}
+	if dep.Target == nil {
+		return errors.New("Dependency has a nil Target")
+	}
+	if r.frozen != nil {
+		return errors.New("Cannot add a Dependency to a frozen Resolver")
+	}
+
+	idMap := r.idMap
+	prodMap := r.prodMap
+
+	// Check for name collisions up front, so that a failure
+	// leaves the Resolver unchanged
+	local := NewProductMap()
+	check := func(p Packager) error {
+		if err := prodMap.checkCollision(p); err != nil {
+			return err
+		}
+		return local.add(p)
+	}
+	if err := check(dep.Target); err != nil {
+		return err
+	}
+	for _, constraints := range dep.Requires {
+		for _, ver := range constraints {
+			if err := check(ver); err != nil {
+				return err
+			}
+		}
+	}
+
+	// The index may be shared with the caller, or with another
+	// Resolver, so it is copied before the first addition
+	if !r.ownIndex {
+		r.index = slices.Clone(r.index)
+		r.ownIndex = true
+	}
+	r.index = append(r.index, dep)
+	r.units = nil
+
+	dep, ok := r.replaceDependency(dep)
+	if !ok {
+		// The Target is replaced, so it is never part of a solution
+		return nil
+	}
+	if r.widensConstraints(dep) {
+		temps := r.temps
+		if err := r.Initialize(); err != nil {
+			r.index = r.index[:len(r.index)-1]
+			r.reinitialize()
+			return err
+		}
+		r.temps = temps
+		return nil
+	}
+
+	clauses := pigosat.Formula{}
+
+	// Track the Packages that were not previously known,
+	// so that they can be conflicted against existing versions
+	added := Packages{}
+	addPackage := func(p Packager) pigosat.Literal {
+		if _, ok := prodMap.pkgs[packageKey(p)]; !ok {
+			added = append(added, p)
+		}
+		prodMap.Add(p)
+		return idMap.StringToId(packageKey(p))
+	}
+
+	tid := addPackage(dep.Target)
+
+	for _, constraints := range dep.Requires {
+		if r.isIgnored(dep.Target, constraints) {
+			continue
+		}
+		clause := make([]pigosat.Literal, len(constraints)+1)
+		clause[0] = -tid
+
+		for i, ver := range constraints {
+			clause[i+1] = addPackage(ver)
+		}
+
+		clauses = append(clauses, clause)
+	}
+	deps := len(clauses)
+
+	// Conflict each new Package with every other version of its
+	// Product. Versions added together in this call only need
+	// to be paired once.
+	pending := make(map[string]bool, len(added))
+	for _, p := range added {
+		pending[packageKey(p)] = true
+	}
+	for _, p := range added {
+		pid := idMap.StringToId(packageKey(p))
+		delete(pending, packageKey(p))
+		if r.multi[p.ProductName()] {
+			continue
+		}
+
+		for _, ver := range prodMap.Packages(p.ProductName()) {
+			if packageKey(ver) == packageKey(p) || pending[packageKey(ver)] {
+				continue
+			}
+			vid := idMap.StringToId(packageKey(ver))
+			clauses = append(clauses, []pigosat.Literal{-pid, -vid})
+		}
+	}
+	conflicts := len(clauses)
+
+	clauses = append(clauses, r.addedConstraintClauses(added)...)
+	constraints := len(clauses)
+	clauses = append(clauses, r.addedPairClauses(added)...)
+
+	r.solver.Adjust(idMap.Len())
+	r.addClauses(DependencyClause, clauses[:deps])
+	r.addClauses(VersionConflictClause, clauses[deps:conflicts])
+	r.addClauses(ConstraintClause, clauses[conflicts:constraints])
+	r.addClauses(ExplicitConflictClause, clauses[constraints:])
+
+	return nil
+}
+
+// widensConstraints returns true if a Dependency, which is about to be
+// added with AddDependency(), adds a version to a Product with a minimum
+// version, whose clause can not be widened in place, or external Packages
+// are assumed, which depend on the whole index
+func (r *Resolver) widensConstraints(dep Dependency) bool {
+	if r.external {
+		return true
+	}
+	if len(r.bounds) == 0 {
+		return false
+	}
+	widens := func(p Packager) bool {
+		_, bounded := r.bounds[p.ProductName()]
+		_, known := r.prodMap.pkgs[packageKey(p)]
+		return bounded && !known
+	}
+	if widens(dep.Target) {
+		return true
+	}
+	for _, vers := range dep.Requires {
+		for _, ver := range vers {
+			if widens(ver) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// addedConstraintClauses returns the unit clauses of the permanent
+// constraints for Packages added with AddDependency(), as built for
+// every Package by a full Initialize(): pinned, forbidden and excluded
+// Packages, and the versions below a security floor
+func (r *Resolver) addedConstraintClauses(added Packages) pigosat.Formula {
+	if len(added) == 0 {
+		return nil
+	}
+	keys := make(map[string]bool, len(added))
+	for _, p := range added {
+		keys[packageKey(p)] = true
+	}
+
+	var clauses pigosat.Formula
+	for _, p := range r.pinned {
+		if keys[packageKey(p)] {
+			clauses = append(clauses, []pigosat.Literal{r.idMap.StringToId(packageKey(p))})
+		}
+	}
+	for _, p := range r.forbidden {
+		if keys[packageKey(p)] {
+			clauses = append(clauses, []pigosat.Literal{-r.idMap.StringToId(packageKey(p))})
+		}
+	}
+	for _, p := range added {
+		id := r.idMap.StringToId(packageKey(p))
+		floor, ok := r.floors[p.ProductName()]
+		if (ok && CompareVersions(p.Version(), floor) < 0) || slices.Contains(r.excluded, p.ProductName()) {
+			clauses = append(clauses, []pigosat.Literal{-id})
+		}
+	}
+	return clauses
+}
+
+// addedPairClauses returns the clauses for the pairs of the conflict
+// source that involve a Package added with AddDependency()
+func (r *Resolver) addedPairClauses(added Packages) pigosat.Formula {
+	if r.pairs == nil || len(added) == 0 {
+		return nil
+	}
+	keys := make(map[string]bool, len(added))
+	for _, p := range added {
+		keys[packageKey(p)] = true
+	}
+
+	var clauses pigosat.Formula
+	for _, pair := range r.pairs() {
+		if !keys[packageKey(pair[0])] && !keys[packageKey(pair[1])] {
+			continue
+		}
+		a, errA := r.idMap.GetId(packageKey(pair[0]))
+		b, errB := r.idMap.GetId(packageKey(pair[1]))
+		if errA == nil && errB == nil && a != b {
+			clauses = append(clauses, []pigosat.Literal{-a, -b})
+		}
+	}
+	return clauses
+}
+
+// addRequires applies the Packages stored as requirements,
+// along with any pushed and temporary requirements, and an extra list
+// of literals, as assumptions to the solver. These assumptions are valid
+// only for one call to Resolve at a time, and are recorded for LastTrace().
+// A Package that is required more than once is only assumed once, so that
+// it is not reported more than once as a conflict. When the pre-solve pass
+// is enabled, the Packages forced by the requirements are also assumed.
+func (r *Resolver) addRequires(extra []pigosat.Literal) {
+	requires := r.allRequires()
+	seen := make(map[pigosat.Literal]bool, len(requires))
+	r.assumed = r.assumed[:0]
+	r.forced = nil
+	for _, p := range requires {
+		id := r.requireId(p)
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+		r.assumed = append(r.assumed, id)
+	}
+	if r.presolve {
+		ids, origins := r.forcedIds(requires)
+		for _, id := range ids {
+			if seen[id] {
+				continue
+			}
+			seen[id] = true
+			if r.forced == nil {
+				r.forced = make(map[pigosat.Literal]pigosat.Literal)
+			}
+			r.forced[id] = origins[id]
+			r.assumed = append(r.assumed, id)
+		}
+	}
+	r.assumed = append(r.assumed, extra...)
+	for _, lit := range r.assumed {
+		r.solver.Assume(lit)
+	}
+}
+
+// A wildcard tracks the literal that stands for a requirement
+// of any version of a product
+type wildcard struct {
+	lit  pigosat.Literal
+	vers int
+	req  Packager
+}
+
+// requireId returns the literal id to assume for a required Package.
+// A wildcard requirement is mapped to an auxiliary literal, which
+// implies one of the versions of the product that are currently known.
+// The literal is replaced when the product gains new versions.
+func (r *Resolver) requireId(p Packager) pigosat.Literal {
+	if g, ok := p.(*oneOfRequirement); ok {
+		return r.groupId(g)
+	}
+	if p.Version() != AnyVersion {
+		return r.idMap.StringToId(packageKey(r.replacement(p)))
+	}
+
+	vers := r.prodMap.Packages(p.ProductName())
+	if w, ok := r.wildcards[p.ProductName()]; ok && w.vers == len(vers) {
+		return w.lit
+	}
+
+	lit := r.idMap.NewAux()
+	clause := make([]pigosat.Literal, len(vers)+1)
+	clause[0] = -lit
+	for i, ver := range vers {
+		clause[i+1] = r.idMap.StringToId(packageKey(ver))
+	}
+	r.addClauses(RequirementClause, pigosat.Formula{clause})
+
+	if r.wildcards == nil {
+		r.wildcards = make(map[string]*wildcard)
+	}
+	r.wildcards[p.ProductName()] = &wildcard{lit, len(vers), p}
+	return lit
+}
+
+// A oneOfRequirement is a requirement of at least one of a group of
+// Packages, which is reported by Conflicts() as a single requirement
+type oneOfRequirement struct {
+	pkgs Packages
+	name string
+}
+
+func (g *oneOfRequirement) ProductName() string { return g.name }
+func (g *oneOfRequirement) PackageName() string { return g.name }
+func (g *oneOfRequirement) Version() string     { return "" }
+
+// groupId returns the auxiliary literal to assume for a requirement
+// of one of a group of Packages, which implies one of the Packages
+func (r *Resolver) groupId(g *oneOfRequirement) pigosat.Literal {
+	if lit, ok := r.groups[g]; ok {
+		return lit
+	}
+
+	lit := r.idMap.NewAux()
+	clause := make([]pigosat.Literal, len(g.pkgs)+1)
+	clause[0] = -lit
+	for i, p := range g.pkgs {
+		clause[i+1] = r.idMap.StringToId(packageKey(r.replacement(p)))
+	}
+	r.addClauses(RequirementClause, pigosat.Formula{clause})
+
+	if r.groups == nil {
+		r.groups = make(map[*oneOfRequirement]pigosat.Literal)
+	}
+	r.groups[g] = lit
+	return lit
+}
+
+// isAux returns true if a literal id is an auxiliary
+// literal, that does not represent a Package
+func (r *Resolver) isAux(id pigosat.Literal) bool {
+	if r.idMap.IdToString(id) != "" {
+		return false
+	}
+	if id < 0 {
+		id = -id
+	}
+	for _, w := range r.wildcards {
+		if w.lit == id {
+			return false
+		}
+	}
+	return true
+}
+
+// literalPackage returns the Package for a literal id,
+// including the wildcard requirement for a wildcard literal
+func (r *Resolver) literalPackage(id pigosat.Literal) (Packager, error) {
+	if r.solver == nil {
+		return nil, errors.New("Solver not initialized.")
+	}
+	if id < 0 {
+		id = -id
+	}
+	for g, lit := range r.groups {
+		if lit == id {
+			return g, nil
+		}
+	}
+	for _, w := range r.wildcards {
+		if w.lit == id {
+			return w.req, nil
+		}
+	}
+	return r.PackageByName(r.idMap.IdToString(id))
+}
+
+// allRequires returns the requirements, followed by the Packages
+// in every pushed assumption frame, and the temporary requirements
+func (r *Resolver) allRequires() Packages {
+	if len(r.frames) == 0 && len(r.temps) == 0 {
+		return r.requires
+	}
+	all := append(Packages(nil), r.requires...)
+	for _, frame := range r.frames {
+		all = append(all, frame...)
+	}
+	return append(all, r.temps...)
+}
+
+// Push a frame of Packages onto a stack of assumptions. Every call to
+// Resolve() requires the Packages in all pushed frames, in addition to
+// the requirements, until the frame is removed with PopAssumptions().
+// This allows an external search to cheaply explore a tree of decisions.
+func (r *Resolver) PushAssumptions(pkgs Packages) {
+	r.frames = append(r.frames, pkgs)
+}
+
+// Pop the last frame of Packages pushed with PushAssumptions().
+// Returns the Packages of the removed frame, or nil if there
+// are no pushed frames.
+func (r *Resolver) PopAssumptions() Packages {
+	if len(r.frames) == 0 {
+		return nil
+	}
+	last := len(r.frames) - 1
+	pkgs := r.frames[last]
+	r.frames = r.frames[:last]
+	return pkgs
+}
+
+// Returns up to n versions of a Product known to the Resolver, from the
+// newest, as compared by CompareVersions(), such as for a version picker.
+// This only looks at the versions in the package index, and does not
+// solve. All versions are returned if there are fewer than n,
+// or n is negative.
+// Returns nil if the Product is not known to the Resolver.
+func (r *Resolver) TopVersions(product string, n int) Packages {
+	if r.solver == nil {
+		return nil
+	}
+	vers := Packages(r.prodMap.Packages(product))
+	if vers == nil {
+		return nil
+	}
+	sort.Slice(vers, func(i, j int) bool { return comparePackages(vers[i], vers[j]) > 0 })
+	if n >= 0 && n < len(vers) {
+		vers = vers[:n]
+	}
+	return vers
+}
+
+// Returns every version of a Product in the last successfully resolved
+// solution, sorted by PackageName. A Product has more than one version
+// in the solution only if it was allowed with AllowMultipleVersions().
+func (r *Resolver) SolutionVersions(product string) Packages {
+	vers := Packages{}
+	for _, p := range r.solution {
+		if p.ProductName() == product {
+			vers = append(vers, p)
+		}
+	}
+	sort.Sort(vers)
+	return vers
+}
+
+// Returns the last successfully resolved solution of packages
+func (r *Resolver) Solution() Packages {
+	return r.solution
+}
+
+// Returns the last successfully resolved solution as a mapping of
+// Product names to the chosen version of each Product.
+// Returns a non-nil error if more than one version of the same Product
+// was found in the solution, which only happens for a Product that was
+// allowed with AllowMultipleVersions(). Use SolutionVersions() instead
+// for such Products.
+func (r *Resolver) SolutionMap() (map[string]string, error) {
+	vers := make(map[string]string, len(r.solution))
+	for _, p := range r.solution {
+		prodName := p.ProductName()
+		if ver, ok := vers[prodName]; ok {
+			return nil, fmt.Errorf("Solution contains multiple versions of product %q: %s, %s",
+				prodName, ver, p.Version())
+		}
+		vers[prodName] = p.Version()
+	}
+	return vers, nil
+}
+
+// A Package of a solution, with the other versions of its Product
+type AnnotatedPackage struct {
+	// The version of the Product in the solution
+	Chosen Packager
+	// The other known versions of the Product, from the newest
+	Alternatives Packages
+}
+
+// Returns the last successfully resolved solution, keyed by Product name,
+// where each chosen Package is annotated with the other versions of its
+// Product that are known to the Resolver, such as to show the versions
+// that were available to a user. The alternatives are not checked for
+// whether they could be part of a solution.
+// Returns a non-nil error if the requirements are not solved, or the
+// solution contains multiple versions of the same Product.
+func (r *Resolver) AnnotatedSolution() (map[string]AnnotatedPackage, error) {
+	if r.solver == nil {
+		return nil, errors.New("Solver not initialized.")
+	}
+	if !r.Solved() {
+		return nil, errors.New("Requirements must be successfully resolved " +
+			"before annotating the solution")
+	}
+
+	annotated := make(map[string]AnnotatedPackage, len(r.solution))
+	for _, p := range r.solution {
+		product := p.ProductName()
+		if other, ok := annotated[product]; ok {
+			return nil, fmt.Errorf("Solution contains multiple versions of product %q: %s, %s",
+				product, other.Chosen.Version(), p.Version())
+		}
+
+		alts := Packages{}
+		for _, ver := range r.prodMap.Packages(product) {
+			if packageKey(ver) != packageKey(p) {
+				alts = append(alts, ver)
+			}
+		}
+		sort.Slice(alts, func(i, j int) bool { return comparePackages(alts[i], alts[j]) > 0 })
+		annotated[product] = AnnotatedPackage{p, alts}
+	}
+	return annotated, nil
+}
+
+// Returns the Packages in the last successfully resolved solution,
+// grouped by the source they were defined by, as reported by the optional
+// SourcePackager interface. Packages without a source are grouped under
+// an empty string.
+func (r *Resolver) SolutionBySource() map[string]Packages {
+	sources := make(map[string]Packages)
+	for _, p := range r.solution {
+		src := packageSource(p)
+		sources[src] = append(sources[src], p)
+	}
+	return sources
+}
+
+// Returns the Packages in the last successfully resolved solution
+// that directly depend on the given Package, through one of
+// their requires-groups. This explains why a Package was included
+// in the solution.
+// Returns an empty list if the Package is one of the requirements.
+// Returns a non-nil error if the Package is not in the solution.
+func (r *Resolver) RequiredBy(p Packager) (Packages, error) {
+	key := packageKey(p)
+
+	selected := make(map[string]Packager, len(r.solution))
+	for _, pkg := range r.solution {
+		selected[packageKey(pkg)] = pkg
+	}
+	if _, ok := selected[key]; !ok {
+		return nil, fmt.Errorf("Package %q is not in the current solution", p.PackageName())
+	}
+
+	packs := Packages{}
+	for _, req := range r.requires {
+		if packageKey(req) == key {
+			return packs, nil
+		}
+	}
+
+	seen := make(map[string]bool)
+	for dep := range r.dependencies() {
+		target, ok := selected[packageKey(dep.Target)]
+		if !ok || seen[packageKey(target)] {
+			continue
+		}
+	groups:
+		for _, vers := range dep.Requires {
+			if r.isIgnored(dep.Target, vers) {
+				continue
+			}
+			for _, ver := range vers {
+				if packageKey(ver) == key {
+					seen[packageKey(target)] = true
+					packs = append(packs, target)
+					break groups
+				}
+			}
+		}
+	}
+	return packs, nil
+}
+
+// Returns the root Packages of a solution, such as one returned by a
+// previous call to Solution(): the Packages that are not in a requires-group
+// of any other Package in the solution. The roots are the requirements that
+// lead to the rest of the solution, for re-resolving with the same top-level
+// choices. When Packages depend on each other in a cycle that is not reached
+// from any root, the first Package of the cycle in the solution is
+// also a root. The roots are returned in the order of the solution.
+func (r *Resolver) RootRequirements(solution Packages) Packages {
+	selected := make(map[string]bool, len(solution))
+	for _, p := range solution {
+		selected[packageKey(p)] = true
+	}
+
+	// The selected members of the requires-groups of each selected Package
+	edges := make(map[string][]string)
+	required := make(map[string]bool)
+	for dep := range r.dependencies() {
+		target := packageKey(dep.Target)
+		if !selected[target] {
+			continue
+		}
+		for _, vers := range dep.Requires {
+			if r.isIgnored(dep.Target, vers) {
+				continue
+			}
+			for _, ver := range vers {
+				name := packageKey(ver)
+				if selected[name] && name != target {
+					edges[target] = append(edges[target], name)
+					required[name] = true
+				}
+			}
+		}
+	}
+
+	reached := make(map[string]bool, len(solution))
+	var visit func(name string)
+	visit = func(name string) {
+		if reached[name] {
+			return
+		}
+		reached[name] = true
+		for _, next := range edges[name] {
+			visit(next)
+		}
+	}
+
+	roots := Packages{}
+	for _, p := range solution {
+		if name := packageKey(p); !required[name] && !reached[name] {
+			roots = append(roots, p)
+			visit(name)
+		}
+	}
+	for _, p := range solution {
+		if name := packageKey(p); !reached[name] {
+			roots = append(roots, p)
+			visit(name)
+		}
+	}
+	return roots
+}
+
+// Returns the dependency depth of a Package: the number of requires
+// edges in the longest chain from the Package down to a Package without
+// dependencies, where every member of a requires-group is a branch of its
+// own. A Package without dependencies has a depth of 0. Deep chains make
+// for slower solves, so this helps to find the Packages that cause them.
+// Returns a non-nil error if the Package does not exist in the Resolver,
+// or if a dependency cycle is reachable from the Package, as it has no
+// finite depth.
+func (r *Resolver) DependencyDepth(p Packager) (int, error) {
+	if r.solver == nil {
+		return 0, errors.New("Solver not initialized.")
+	}
+	if _, err := r.prodMap.PackageByName(packageKey(p)); err != nil {
+		return 0, fmt.Errorf("Package %q does not exist in the Resolver", p.PackageName())
+	}
+
+	edges := make(map[string]Packages)
+	for dep := range r.dependencies() {
+		target := packageKey(dep.Target)
+		for _, vers := range dep.Requires {
+			if !r.isIgnored(dep.Target, vers) {
+				edges[target] = append(edges[target], vers...)
+			}
+		}
+	}
+
+	// The depth of each visited Package, which is -1
+	// while its dependencies are being visited
+	depths := make(map[string]int)
+	var visit func(p Packager) (int, error)
+	visit = func(p Packager) (int, error) {
+		key := packageKey(p)
+		if depth, ok := depths[key]; ok {
+			if depth < 0 {
+				return 0, fmt.Errorf("Package %q is part of a dependency cycle", p.PackageName())
+			}
+			return depth, nil
+		}
+
+		depths[key] = -1
+		depth := 0
+		for _, next := range edges[key] {
+			d, err := visit(next)
+			if err != nil {
+				return 0, err
+			}
+			depth = max(depth, d+1)
+		}
+		depths[key] = depth
+		return depth, nil
+	}
+	return visit(p)
+}
+
+// Transition resolves the current requirements, and compares the solution
+// with a list of currently installed Packages, by product and version.
+// Returns the Packages that need to be installed and removed to go from
+// the installed Packages to the solution. A Product that changes version
+// has its new version in install, and its old version in remove.
+//
+// Returns a non-nil error if the requirements could not be resolved.
+func (r *Resolver) Transition(installed Packages) (install, remove Packages, err error) {
+	solved, err := r.Resolve()
+	if err != nil {
+		return nil, nil, err
+	}
+	if !solved {
+		return nil, nil, fmt.Errorf("Requirements cannot be satisfied: (%s)", r.Conflicts())
+	}
+
+	install, remove = installed.Diff(r.solution)
+	return install, remove, nil
+}
+
+// StreamTransition resolves the current requirements like Transition(),
+// but calls onInstall for each Package that needs to be installed,
+// followed by onRemove for each Package that needs to be removed, instead
+// of collecting them into lists. Either callback may be nil.
+//
+// Returns a non-nil error if the requirements could not be resolved.
+func (r *Resolver) StreamTransition(installed Packages, onInstall, onRemove func(Packager)) error {
+	solved, err := r.Resolve()
+	if err != nil {
+		return err
+	}
+	if !solved {
+		return fmt.Errorf("Requirements cannot be satisfied: (%s)", r.Conflicts())
+	}
+
+	skip := func(Packager) {}
+	if onInstall == nil {
+		onInstall = skip
+	}
+	if onRemove == nil {
+		onRemove = skip
+	}
+	installed.walkDiff(r.solution, onInstall, onRemove)
+	return nil
+}
+
+// MustResolve is like Resolve(), but returns the solution, and panics if
+// there was an internal error, or if the requirements can not be satisfied,
+// with a message that describes the conflicts. It is intended for scripts,
+// tests and examples, where a failed resolve is not expected.
+func (r *Resolver) MustResolve() Packages {
+	solved, err := r.Resolve()
+	if err != nil {
+		panic(fmt.Sprintf("Resolver failed with an error: %s", err.Error()))
+	}
+	if !solved {
+		msg := fmt.Sprintf("Resolver failed to satisfy the requirements: %s", r.Conflicts())
+		if details, err := r.DetailedConflicts(); err == nil {
+			msg += "\n" + details.String()
+		}
+		panic(msg)
+	}
+	return r.Solution()
+}
+
+// Attempt to resolve a package solution with the currently set criteria.
+// Returns a bool indicating whether the Resolver succeeded or conflicted.
+// Returns a non-nil error if there was an internal error.
+func (r *Resolver) Resolve() (bool, error) {
+	ok, err := r.resolve(nil)
+	if err == nil && !ok {
+		r.conflicted()
+	}
+	return ok, err
+}
+
+// Resolve a new set of requirements, keeping the solver warm. Unlike
+// SetRequirements(), which throws the solver away and builds it again,
+// the requirements are replaced and solved as assumptions on the existing
+// solver. The clauses that PicoSAT learned from the previous solves are
+// kept, which accelerates a series of related solves, such as trying
+// many variations of the requirements against the same package index.
+// The learned clauses only follow from the package index and permanent
+// constraints, so they never change which solutions are valid.
+//
+// Returns a bool indicating whether the Resolver succeeded or conflicted.
+// Returns a non-nil error if there was an internal error.
+func (r *Resolver) SolveIncremental(requires Packages) (bool, error) {
+	r.requires = requires
+	ok, err := r.resolve(nil)
+	if err == nil && !ok {
+		r.conflicted()
+	}
+	return ok, err
+}
+
+// resolve performs a Resolve(), with an extra list of literals
+// that are assumed in addition to the requirements.
+// Temporary requirements are cleared after the solve.
+func (r *Resolver) resolve(assumptions []pigosat.Literal) (bool, error) {
+	if r.solver == nil {
+		return false, errors.New("Requirements not set. Solver not initialized.")
+	}
+
+	r.solution = Packages{}
+	r.conflicts = nil
+
+	// Push the fixed requirements into the solver
+	r.addRequires(assumptions)
+	r.temps = nil
+
+	status, solution := r.solver.Solve()
+	if status != pigosat.Satisfiable {
+		return false, nil
+	}
+
+	var (
+		pkgName string
+		pkg     Packager
+		err     error
+	)
+	// Remap the literal ids from the solution back into
+	// the original objects
+	for i := 1; i < len(solution); i++ {
+		if solution[i] {
+			pkgName = r.idMap.IdToString(pigosat.Literal(i))
+			if pkgName == "" {
+				// An auxiliary literal, that is not a Package
+				continue
+			}
+			if pkg, err = r.prodMap.PackageByName(pkgName); err != nil {
+				return false, fmt.Errorf("Resolve failed to look up package by name %q: %s",
+					pkgName, err.Error())
+			}
+			r.solution = append(r.solution, pkg)
+		}
+	}
+	return true, nil
+}
+
+// The results of the last solve, that are kept by Compact()
+type compactResult struct {
+	solved    bool
+	conflicts Packages
+}
+
+// Compact releases the solver and the literal mappings of the Resolver,
+// to free their memory once the results of the last solve are captured.
+// Afterwards only the results of the last solve can be queried:
+// Solved(), Solution(), SolutionVersions(), SolutionMap(), Conflicts()
+// and DetailedConflicts(), which are computed before the solver is
+// released. Resolve() and the other methods that need the solver return
+// an error, and the methods that look up Packages or literals return an
+// empty result, until the Resolver is initialized again with
+// Initialize(), or any method that resets it.
+// The package index and all permanent constraints are kept.
+func (r *Resolver) Compact() {
+	if r.solver == nil {
+		return
+	}
+
+	result := &compactResult{solved: r.Solved()}
+	if !result.solved && r.solver.Res() == pigosat.Unsatisfiable {
+		result.conflicts = r.Conflicts()
+		if !r.noTrace {
+			// Cache the detailed conflicts
+			r.DetailedConflicts()
+		}
+	}
+
+	r.solver.Delete()
+	r.solver = nil
+	r.idMap = nil
+	r.prodMap = nil
+	r.temps = nil
+	r.assumed = nil
+	r.forced = nil
+	r.wildcards = nil
+	r.groups = nil
+	r.amo = nil
+	r.compacted = result
+}
+
+// Returns the returned by the last call to Resolve(),
+// indicating whether the current requirements are solved or not.
+func (r *Resolver) Solved() bool {
+	if r.solver == nil {
+		return r.compacted != nil && r.compacted.solved
+	}
+
+	return r.solver.Res() == pigosat.Satisfiable
+}
+
+// Add a package as a requirement that must be satisfied by the solver.
+// Unlike RequireTemp(), the requirement is kept for every call to
+// Resolve(), as if it was passed to SetRequirements().
+func (r *Resolver) Require(p Packager) {
+	r.requires = append(r.requires, p)
+}
+
+// Add a list of packages as requirements that must be satisfied by the
+// solver, as if each was added with Require(). Packages that are already
+// requirements are not added again.
+func (r *Resolver) RequireAll(pkgs Packages) {
+	seen := make(map[string]bool, len(r.requires)+len(pkgs))
+	for _, p := range r.requires {
+		seen[packageKey(p)] = true
+	}
+	for _, p := range pkgs {
+		if key := packageKey(p); !seen[key] {
+			seen[key] = true
+			r.requires = append(r.requires, p)
+		}
+	}
+}
+
+// Require at least one of a group of Packages known to the Resolver,
+// such as any of several acceptable versions of an interpreter. Unlike a
+// wildcard requirement, the Packages may be of different Products. The
+// requirement is kept for every call to Resolve(), as if it was passed
+// to SetRequirements(). When the group can not be satisfied, Conflicts()
+// reports the whole group as a single requirement, named
+// "one of (<packages>)", and DetailedConflicts() reports it as a
+// RequiredOneOf relation.
+// A Resolver with a one-of requirement can not be frozen with Freeze().
+// Returns a non-nil error if the group is empty, or a Package does not
+// exist in the Resolver.
+func (r *Resolver) RequireOneOf(pkgs Packages) error {
+	if r.solver == nil {
+		return errors.New("Solver not initialized.")
+	}
+	if len(pkgs) == 0 {
+		return errors.New("A one-of requirement needs at least one Package")
+	}
+	for _, p := range pkgs {
+		if _, err := r.idMap.GetId(packageKey(p)); err != nil {
+			return fmt.Errorf("Package %q does not exist in the Resolver", p.PackageName())
+		}
+	}
+
+	g := &oneOfRequirement{
+		pkgs: append(Packages(nil), pkgs...),
+		name: "one of (" + pkgs.String() + ")",
+	}
+	r.requires = append(r.requires, g)
+	return nil
+}
+
+// Add a package as a requirement that must be satisfied by the solver.
+// This addition is only valid until the next call to Resolve(),
+// after which it will be removed.
+func (r *Resolver) RequireTemp(p Packager) {
+	r.temps = append(r.temps, p)
+}
+
+// Require any version of a Product that is at or above a minimum version,
+// as compared by CompareVersions(). This is a permanent requirement that
+// is applied to every solve, and is rebuilt from the versions that are
+// known in the package index whenever the Resolver is initialized.
+// Versions added later with AddDependency() are only considered after
+// the Resolver is initialized again. As with Forbid(), it is skipped
+// while a new package index has no version that satisfies it.
+// Calling it again for the same Product replaces the previous minimum
+// version, and keeps the temporary requirements.
+//
+// Returns a non-nil error if the Product is not known, or no known
+// version satisfies the minimum version.
+func (r *Resolver) RequireAtLeast(product, minVersion string) error {
+	if r.solver == nil {
+		return errors.New("Solver not initialized.")
+	}
+
+	clause, err := r.atLeastClause(product, minVersion)
+	if err != nil {
+		return err
+	}
+
+	_, replace := r.bounds[product]
+	if r.bounds == nil {
+		r.bounds = make(map[string]string)
+	}
+	r.bounds[product] = minVersion
+
+	if replace {
+		temps := r.temps
+		if err := r.Initialize(); err != nil {
+			return err
+		}
+		r.temps = temps
+		return nil
+	}
+
+	r.addClauses(ConstraintClause, pigosat.Formula{clause})
+	return nil
+}
+
+// Forbid a Package known to the Resolver from being part of any solution.
+// This is a permanent constraint that is applied to every solve. It is
+// skipped while a new package index does not have the Package.
+// Returns a non-nil error if the Package does not exist in the Resolver.
+func (r *Resolver) Forbid(p Packager) error {
+	if r.solver == nil {
+		return errors.New("Solver not initialized.")
+	}
+	id, err := r.idMap.GetId(packageKey(p))
+	if err != nil {
+		return fmt.Errorf("Package %q does not exist in the Resolver", p.PackageName())
+	}
+	r.forbidden = append(r.forbidden, p)
+	r.addClauses(ConstraintClause, pigosat.Formula{{-id}})
+	return nil
+}
+
+// Set a security floor for a Product, so that none of its versions below
+// a minimum version, as compared by CompareVersions(), can be part of any
+// solution. Unlike RequireAtLeast(), the Product is not required, and
+// unlike Pin(), no specific version is forced. This is a permanent
+// constraint that is applied to every solve, even when the Product is
+// not yet known to the Resolver. A requirement that needs a version below
+// the floor fails to resolve, and those versions are reported as
+// Restricts relations by DetailedConflicts().
+// Calling it again for the same Product replaces the previous floor, and
+// an empty minimum version removes it.
+// Resets the internal solver and state.
+func (r *Resolver) SetSecurityFloor(product, minVersion string) {
+	if minVersion == "" {
+		delete(r.floors, product)
+	} else {
+		if r.floors == nil {
+			r.floors = make(map[string]string)
+		}
+		r.floors[product] = minVersion
+	}
+	r.reinitialize()
+}
+
+// Replace a Package by another Package, which may be a version of a
+// different Product, such as when a deprecated Package is superseded.
+// Every requirement of the old Package, and every reference to it in the
+// requires-groups of the package index, resolves to the new Package
+// instead, and the old Package itself is dropped from the index, so that
+// it never appears in a solution. Replacements are followed transitively.
+// This is a permanent constraint, applied to every solve.
+// Returns a non-nil error if the replacement would create a cycle, or
+// the replaced index is invalid, such as the new Package sharing its
+// PackageName with a different Package, in which case the Resolver
+// is unchanged.
+// Resets the internal solver and state.
+func (r *Resolver) AddReplacement(old, replacement Packager) error {
+	for p := replacement; p != nil; p = r.replaces[packageKey(p)] {
+		if packageKey(p) == packageKey(old) {
+			return fmt.Errorf("Replacing %q by %q would create a cycle",
+				old.PackageName(), replacement.PackageName())
+		}
+	}
+
+	key := packageKey(old)
+	prev, replaced := r.replaces[key]
+	if r.replaces == nil {
+		r.replaces = make(map[string]Packager)
+	}
+	r.replaces[key] = replacement
+	if err := r.Initialize(); err != nil {
+		if replaced {
+			r.replaces[key] = prev
+		} else {
+			delete(r.replaces, key)
+		}
+		r.reinitialize()
+		return err
+	}
+	return nil
+}
+
+// replacement returns the Package that replaces a Package,
+// following replacements transitively, or the Package itself
+// if it is not replaced
+func (r *Resolver) replacement(p Packager) Packager {
+	for {
+		next, ok := r.replaces[packageKey(p)]
+		if !ok {
+			return p
+		}
+		p = next
+	}
+}
+
+// floorClauses builds the unit clauses that forbid the versions
+// of a Product below a security floor
+func (r *Resolver) floorClauses(product, minVersion string) pigosat.Formula {
+	var ids []pigosat.Literal
+	for _, p := range r.prodMap.Packages(product) {
+		if CompareVersions(p.Version(), minVersion) < 0 {
+			ids = append(ids, r.idMap.StringToId(packageKey(p)))
+		}
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+
+	clauses := make(pigosat.Formula, len(ids))
+	for i, id := range ids {
+		clauses[i] = []pigosat.Literal{-id}
+	}
+	return clauses
+}
+
+// Forbid a list of Packages known to the Resolver from being part of any
+// solution, as if each was forbidden with Forbid(). The clauses of the
+// whole list are added to the solver at once, without initializing the
+// Resolver again.
+// Returns a non-nil error if a Package does not exist in the Resolver,
+// in which case none of the Packages are forbidden.
+func (r *Resolver) RequireNoneOf(pkgs Packages) error {
+	if r.solver == nil {
+		return errors.New("Solver not initialized.")
+	}
+	clauses := make(pigosat.Formula, len(pkgs))
+	for i, p := range pkgs {
+		id, err := r.idMap.GetId(packageKey(p))
+		if err != nil {
+			return fmt.Errorf("Package %q does not exist in the Resolver", p.PackageName())
+		}
+		clauses[i] = []pigosat.Literal{-id}
+	}
+	r.forbidden = append(r.forbidden, pkgs...)
+	r.addClauses(ConstraintClause, clauses)
+	return nil
+}
+
+// Exclude every version of a Product known to the Resolver, so that
+// it is resolved as if the Product did not exist in the package index.
+// This is a permanent constraint that is applied to every solve. A
+// requirement that needs the Product fails to resolve, and the excluded
+// versions are reported as Restricts relations by DetailedConflicts().
+// Versions added later with AddDependency() are only excluded after
+// the Resolver is initialized again.
+// Returns a non-nil error if the Product does not exist in the Resolver.
+func (r *Resolver) ExcludeProduct(product string) error {
+	if r.solver == nil {
+		return errors.New("Solver not initialized.")
+	}
+	if r.prodMap.Packages(product) == nil {
+		return fmt.Errorf("Product %q does not exist in the Resolver", product)
+	}
+	r.excluded = append(r.excluded, product)
+	r.addClauses(ConstraintClause, r.excludeClauses(product))
+	return nil
+}
+
+// excludeClauses builds a negative unit clause for
+// every known version of a Product
+func (r *Resolver) excludeClauses(product string) pigosat.Formula {
+	ids := packagesToIds(r.prodMap.Packages(product), r.idMap)
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+
+	clauses := make(pigosat.Formula, len(ids))
+	for i, id := range ids {
+		clauses[i] = []pigosat.Literal{-id}
+	}
+	return clauses
+}
+
+// Pin a Package known to the Resolver so that it is part of every solution.
+// This is a permanent constraint that is applied to every solve. As with
+// Forbid(), it is skipped while a new package index does not have the
+// Package.
+// Returns a non-nil error if the Package does not exist in the Resolver.
+func (r *Resolver) Pin(p Packager) error {
+	if r.solver == nil {
+		return errors.New("Solver not initialized.")
+	}
+	id, err := r.idMap.GetId(packageKey(p))
+	if err != nil {
+		return fmt.Errorf("Package %q does not exist in the Resolver", p.PackageName())
+	}
+	r.pinned = append(r.pinned, p)
+	r.addClauses(ConstraintClause, pigosat.Formula{{id}})
+	return nil
+}
+
+// An exactlyN is a group of Packages, of which exactly n
+// must be part of every solution
+type exactlyN struct {
+	pkgs Packages
+	n    int
+}
+
+// Require exactly n of a group of Packages known to the Resolver to be
+// part of every solution, such as a quota of optional plugins. This is a
+// permanent constraint that is applied to every solve. It is encoded with
+// a sequential counter, whose auxiliary variables are never part of the
+// Solution(). A Package listed more than once in the group is only
+// counted once.
+// Returns a non-nil error if a Package does not exist in the Resolver,
+// or n is not between 0 and the number of Packages in the group.
+func (r *Resolver) AddExactlyN(pkgs Packages, n int) error {
+	if r.solver == nil {
+		return errors.New("Solver not initialized.")
+	}
+
+	clauses, err := r.exactlyClauses(pkgs, n)
+	if err != nil {
+		return err
+	}
+	r.exactly = append(r.exactly, exactlyN{append(Packages(nil), pkgs...), n})
+	r.addClauses(ConstraintClause, clauses)
+	return nil
+}
+
+// exactlyClauses builds the at-least-n and at-most-n
+// clauses over the literals of a group of Packages
+func (r *Resolver) exactlyClauses(pkgs Packages, n int) (pigosat.Formula, error) {
+	seen := make(map[pigosat.Literal]bool, len(pkgs))
+	lits := make([]pigosat.Literal, 0, len(pkgs))
+	for _, p := range pkgs {
+		id, err := r.idMap.GetId(packageKey(p))
+		if err != nil {
+			return nil, fmt.Errorf("Package %q does not exist in the Resolver", p.PackageName())
+		}
+		if !seen[id] {
+			seen[id] = true
+			lits = append(lits, id)
+		}
+	}
+	if n < 0 || n > len(lits) {
+		return nil, fmt.Errorf("Cannot require exactly %d of %d packages", n, len(lits))
+	}
+
+	// At least n are true, when at most len-n are false
+	negated := make([]pigosat.Literal, len(lits))
+	for i, lit := range lits {
+		negated[i] = -lit
+	}
+	clauses := atMostK(lits, n, r.idMap.NewAux)
+	return append(clauses, atMostK(negated, len(lits)-n, r.idMap.NewAux)...), nil
+}
+
+// atLeastClause builds a clause that requires any of the versions
+// of a Product that are at or above a minimum version
+func (r *Resolver) atLeastClause(product, minVersion string) ([]pigosat.Literal, error) {
+	vers := r.prodMap.Packages(product)
+	if vers == nil {
+		return nil, fmt.Errorf("Product %q does not exist", product)
+	}
+
+	clause := make([]pigosat.Literal, 0, len(vers))
+	for _, p := range vers {
+		if CompareVersions(p.Version(), minVersion) >= 0 {
+			clause = append(clause, r.idMap.StringToId(packageKey(p)))
+		}
+	}
+	if len(clause) == 0 {
+		return nil, fmt.Errorf("No version of product %q satisfies the minimum version %q",
+			product, minVersion)
+	}
+
+	sort.Slice(clause, func(i, j int) bool { return clause[i] < clause[j] })
+	return clause, nil
+}
+
+// Sets a preference score for a given Package known to the Resolver.
+// When resolving with ResolvePreferred(), Packages with a higher score
+// are chosen over Packages with a lower score, as long as a valid
+// solution still exists.
+func (r *Resolver) SetPreference(p Packager, score int) {
+	if r.prefs == nil {
+		r.prefs = make(map[string]int)
+	}
+	r.prefs[packageKey(p)] = score
+}
+
+// Attempt to resolve a package solution with the currently set criteria,
+// biasing the selection towards the Packages with the highest preference
+// score set by SetPreference(), or the highest importance of their Product
+// set by SetProductImportance(). Packages are tried in order of their score,
+// and each one is kept in the solution if it is still satisfiable along
+// with all higher scored Packages that were kept. Packages with equal
+// scores fall back to the order of the sort mode.
+//
+// Returns a bool indicating whether the Resolver succeeded or conflicted.
+// Returns a non-nil error if there was an internal error.
+func (r *Resolver) ResolvePreferred() (bool, error) {
+	if r.solver == nil {
+		return false, errors.New("Requirements not set. Solver not initialized.")
+	}
+
+	// If the requirements can't be solved at all, let
+	// a normal resolve report the conflicts
+	if !r.satisfiable(nil) {
+		return r.resolve(nil)
+	}
+
+	accepted := []pigosat.Literal{}
+	for _, id := range r.preferredIds() {
+		if r.satisfiable(append(accepted, id)) {
+			accepted = append(accepted, id)
+		}
+	}
+
+	return r.resolve(accepted)
+}
+
+// Attempt to resolve a package solution with the currently set criteria,
+// starting from a hinted solution, such as the solution of a previous
+// build. Each hinted Package is assumed to be selected, and the other
+// versions of its Product to be not selected. If the hint is a valid
+// solution, it is solved directly. Otherwise the hint is repaired: hinted
+// Packages are kept in the order of the hint, as long as a valid solution
+// still exists, and the solver is free to choose the rest.
+// Hinted Packages that are not known to the Resolver are ignored.
+//
+// Returns a bool indicating whether the Resolver succeeded or conflicted,
+// and the solution.
+// Returns a non-nil error if there was an internal error.
+func (r *Resolver) ResolveWithHint(hint Packages) (bool, Packages, error) {
+	if r.solver == nil {
+		return false, nil, errors.New("Requirements not set. Solver not initialized.")
+	}
+
+	groups := make([][]pigosat.Literal, 0, len(hint))
+	all := []pigosat.Literal{}
+	for _, p := range hint {
+		id, err := r.idMap.GetId(packageKey(p))
+		if err != nil {
+			continue
+		}
+		group := []pigosat.Literal{id}
+		for _, ver := range r.prodMap.Packages(p.ProductName()) {
+			if packageKey(ver) == packageKey(p) || r.multi[p.ProductName()] {
+				continue
+			}
+			group = append(group, -r.idMap.StringToId(packageKey(ver)))
+		}
+		groups = append(groups, group)
+		all = append(all, group...)
+	}
+
+	var accepted []pigosat.Literal
+	switch {
+	case r.satisfiable(all):
+		accepted = all
+
+	// If the requirements can't be solved at all, let
+	// a normal resolve report the conflicts
+	case !r.satisfiable(nil):
+
+	default:
+		for _, group := range groups {
+			if r.satisfiable(append(accepted, group...)) {
+				accepted = append(accepted, group...)
+			}
+		}
+	}
+
+	ok, err := r.resolve(accepted)
+	if err != nil || !ok {
+		return ok, nil, err
+	}
+	return true, r.Solution(), nil
+}
+
+// Resolves a package solution with the currently set criteria, that
+// includes the newest possible version of each of the named Products.
+// The Products are chosen in order: each one is given its highest version,
+// as compared by CompareVersions(), that can still be solved along with
+// the requirements and the versions already chosen. A lower version is
+// only chosen when no higher version can be solved.
+//
+// Returns a non-nil error if a Product is not known to the Resolver, if
+// no version of a Product can be solved, or the requirements cannot be
+// satisfied.
+func (r *Resolver) ResolveNewest(products []string) (Packages, error) {
+	if r.solver == nil {
+		return nil, errors.New("Requirements not set. Solver not initialized.")
+	}
+
+	if !r.satisfiable(nil) {
+		r.resolve(nil)
+		return nil, fmt.Errorf("Requirements cannot be satisfied: (%s)", r.Conflicts())
+	}
+
+	accepted := []pigosat.Literal{}
+	for _, product := range products {
+		vers := Packages(r.prodMap.Packages(product))
+		if len(vers) == 0 {
+			r.temps = nil
+			return nil, fmt.Errorf("Product %q does not exist in the Resolver", product)
+		}
+		sort.SliceStable(vers, func(i, j int) bool { return comparePackages(vers[i], vers[j]) > 0 })
+
+		found := false
+		for _, ver := range vers {
+			id := r.idMap.StringToId(packageKey(ver))
+			if r.satisfiable(append(accepted, id)) {
+				accepted = append(accepted, id)
+				found = true
+				break
+			}
+		}
+		if !found {
+			r.temps = nil
+			return nil, fmt.Errorf("No version of product %q can be satisfied with the requirements", product)
+		}
+	}
+
+	if _, err := r.resolve(accepted); err != nil {
+		return nil, err
+	}
+	return r.Solution(), nil
+}
+
+// Sets the importance of every version of a Product known to the Resolver,
+// when resolving with ResolvePreferred(). The versions are preferred as if
+// they had a preference score of the importance level, so that a Product
+// with a higher level is chosen over a Product with a lower level. The
+// versions of the Product are tried in the order of the sort mode, from
+// the newest version unless the sort mode is ResolveSortLow. A score set
+// with SetPreference() takes precedence over the importance of its Product.
+// A level of 0 removes the importance of the Product.
+func (r *Resolver) SetProductImportance(product string, level int) {
+	if r.important == nil {
+		r.important = make(map[string]int)
+	}
+	if level == 0 {
+		delete(r.important, product)
+		return
+	}
+	r.important[product] = level
+}
+
+// preferredIds returns the literal ids of all Packages with a preference
+// score, or a Product importance, ordered from the highest score to the
+// lowest. Packages that are not known to the Resolver are skipped.
+func (r *Resolver) preferredIds() []pigosat.Literal {
+	scores := make(map[string]int, len(r.prefs))
+	for product, level := range r.important {
+		for _, ver := range r.prodMap.Packages(product) {
+			scores[packageKey(ver)] = level
+		}
+	}
+	for name, score := range r.prefs {
+		scores[name] = score
+	}
+
+	names := make([]string, 0, len(scores))
+	for name := range scores {
+		if _, err := r.idMap.GetId(name); err == nil {
+			names = append(names, name)
+		}
+	}
+
+	sort.Slice(names, func(i, j int) bool {
+		a, b := names[i], names[j]
+		if scores[a] != scores[b] {
+			return scores[a] > scores[b]
+		}
+		// Versions of the same important Product, in sort order
+		pa, _ := r.prodMap.PackageByName(a)
+		pb, _ := r.prodMap.PackageByName(b)
+		_, prefA := r.prefs[a]
+		_, prefB := r.prefs[b]
+		if !prefA && !prefB && pa.ProductName() == pb.ProductName() {
+			switch r.sortMode {
+			case ResolveSortLow:
+				return compareSortOrder(pa, pb) < 0
+			case ResolveSortSameMajor:
+				return r.compareSameMajor(pa, pb) > 0
+			}
+			return compareSortOrder(pa, pb) > 0
+		}
+		switch r.sortMode {
+		case ResolveSortHigh:
+			return a > b
+		case ResolveSortLow:
+			return a < b
+		}
+		ida, _ := r.idMap.GetId(a)
+		idb, _ := r.idMap.GetId(b)
+		return ida < idb
+	})
+
+	ids := make([]pigosat.Literal, len(names))
+	for i, name := range names {
+		ids[i], _ = r.idMap.GetId(name)
+	}
+	return ids
+}
+
+// Resolves up to max distinct package solutions for the currently set
+// requirements, including any pushed and temporary requirements. A max of 0 or less
+// finds every solution. Each solution after the first is guaranteed to not
+// be a superset of any previous solution.
+//
+// The solutions are found with a separate solver, so the state of the
+// Resolver is not changed, apart from clearing temporary requirements.
+// Returns an empty list if the requirements cannot be satisfied.
+func (r *Resolver) AllSolutions(max int) ([]Packages, error) {
+	requires := append(Packages(nil), r.allRequires()...)
+	r.temps = nil
+
+	tmp, err := r.tempResolver(requires)
+	if err != nil {
+		return nil, err
+	}
+
+	solutions := []Packages{}
+	for max <= 0 || len(solutions) < max {
+		solved, err := tmp.Resolve()
+		if err != nil {
+			return nil, err
+		}
+		if !solved {
+			break
+		}
+
+		solution := tmp.Solution()
+		solutions = append(solutions, solution)
+
+		// Block this solution, and any superset of it,
+		// from being found again
+		block := make([]pigosat.Literal, len(solution))
+		for i, p := range solution {
+			block[i] = -tmp.idMap.StringToId(packageKey(p))
+		}
+		if len(block) == 0 {
+			break
+		}
+		tmp.solver.AddClauses(pigosat.Formula{block})
+	}
+
+	return solutions, nil
+}
+
+// Resolves a package solution with the currently set criteria, that
+// uses the fewest distinct Products. Among the valid solutions, one is
+// preferred that reuses Products over one that introduces new Products.
+// The search is performed with a separate solver, by repeatedly solving
+// while constraining the number of Products to be less than the last
+// solution, until no smaller solution exists.
+//
+// The state of the Resolver is not changed, apart from clearing
+// temporary requirements.
+// Returns a non-nil error if the requirements cannot be satisfied.
+func (r *Resolver) ResolveFewestProducts() (Packages, error) {
+	tmp, err := r.tempResolver(append(Packages(nil), r.allRequires()...))
+	r.temps = nil
+	if err != nil {
+		return nil, err
+	}
+
+	// Add a selector for each Product, that is true
+	// if any version of the Product is selected
+	names := make([]string, 0, tmp.prodMap.NumProducts())
+	for name := range tmp.prodMap.prods {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	clauses := pigosat.Formula{}
+	selectors := make([]pigosat.Literal, len(names))
+	for i, name := range names {
+		sel := tmp.idMap.NewAux()
+		selectors[i] = sel
+
+		ids := packagesToIds(tmp.prodMap.Packages(name), tmp.idMap)
+		clause := make([]pigosat.Literal, 0, len(ids)+1)
+		clause = append(clause, -sel)
+		for _, id := range ids {
+			clauses = append(clauses, []pigosat.Literal{-id, sel})
+			clause = append(clause, id)
+		}
+		clauses = append(clauses, clause)
+	}
+	tmp.solver.AddClauses(clauses)
+
+	solved, err := tmp.Resolve()
+	if err != nil {
+		return nil, err
+	}
+	if !solved {
+		return nil, fmt.Errorf("Requirements cannot be satisfied: (%s)", tmp.Conflicts())
+	}
+	best := tmp.Solution()
+
+	for {
+		prods := make(map[string]bool, len(best))
+		for _, p := range best {
+			prods[p.ProductName()] = true
+		}
+		count := len(prods)
+		if count == 0 {
+			break
+		}
+		tmp.solver.AddClauses(atMostK(selectors, count-1, tmp.idMap.NewAux))
+
+		if solved, err = tmp.Resolve(); err != nil {
+			return nil, err
+		}
+		if !solved {
+			break
+		}
+		best = tmp.Solution()
+	}
+
+	return best, nil
+}
+
+// The cost of a Package that has no cost set with SetCost(),
+// unless it is changed with SetBaseCost()
+const defaultBaseCost = 1
+
+// Set the cost of a Package, such as its download or build cost,
+// which is used by ResolveCheapest() to find the solution with the
+// lowest total cost. Negative costs are treated as zero.
+// Calling it again for the same Package replaces its cost.
+func (r *Resolver) SetCost(p Packager, cost int) {
+	if cost < 0 {
+		cost = 0
+	}
+	if r.costs == nil {
+		r.costs = make(map[string]int)
+	}
+	r.costs[packageKey(p)] = cost
+}
+
+// Set the cost of every Package that has no cost set with SetCost().
+// The default base cost is 1, so that without any costs set,
+// ResolveCheapest() finds the solution with the fewest Packages.
+// Negative costs are treated as zero.
+func (r *Resolver) SetBaseCost(cost int) {
+	if cost < 0 {
+		cost = 0
+	}
+	r.baseCost = &cost
+}
+
+// packageCost returns the cost of a Package by its key
+func (r *Resolver) packageCost(key string) int {
+	if cost, ok := r.costs[key]; ok {
+		return cost
+	}
+	if r.baseCost != nil {
+		return *r.baseCost
+	}
+	return defaultBaseCost
+}
+
+// Resolves a package solution with the currently set criteria, that has
+// the lowest total cost of its Packages, as set with SetCost() and
+// SetBaseCost(). A solution with more Packages is chosen over one with
+// fewer Packages, if their total cost is lower.
+// The search is performed with a separate solver, by repeatedly solving
+// while constraining the total cost to be less than the last solution,
+// until no cheaper solution exists. The size of the constraint grows with
+// the number of Packages times the total cost, so costs should be kept
+// to small integers.
+//
+// The state of the Resolver is not changed, apart from clearing
+// temporary requirements.
+// Returns a non-nil error if the requirements cannot be satisfied.
+func (r *Resolver) ResolveCheapest() (Packages, error) {
+	tmp, err := r.tempResolver(append(Packages(nil), r.allRequires()...))
+	r.temps = nil
+	if err != nil {
+		return nil, err
+	}
+
+	solved, err := tmp.Resolve()
+	if err != nil {
+		return nil, err
+	}
+	if !solved {
+		return nil, fmt.Errorf("Requirements cannot be satisfied: (%s)", tmp.Conflicts())
+	}
+	best := tmp.Solution()
+
+	// The cost of every Package literal, in the order of their ids
+	var (
+		lits  []pigosat.Literal
+		costs []int
+	)
+	for i := pigosat.Literal(1); i <= tmp.idMap.i; i++ {
+		name := tmp.idMap.IdToString(i)
+		if name == "" {
+			continue
+		}
+		lits = append(lits, i)
+		costs = append(costs, r.packageCost(name))
+	}
+
+	for {
+		total := 0
+		for _, p := range best {
+			total += r.packageCost(packageKey(p))
+		}
+		if total == 0 {
+			break
+		}
+		tmp.solver.AddClauses(atMostWeighted(lits, costs, total-1, tmp.idMap.NewAux))
+
+		if solved, err = tmp.Resolve(); err != nil {
+			return nil, err
+		}
+		if !solved {
+			break
+		}
+		best = tmp.Solution()
+	}
+
+	return best, nil
+}
+
+// Returns the largest subset of the requirements, including any pushed
+// and temporary requirements, that can be solved together. When the
+// requirements cannot be solved, this tells which requirements can be
+// kept, as the dual of the conflicts reported by Conflicts().
+// The search is performed with a separate solver, by repeatedly solving
+// while requiring more of the requirements than the last solution
+// satisfied, until no larger subset can be solved. Requirements are
+// returned in their original order, and a requirement listed more than
+// once is only counted once.
+//
+// The state of the Resolver is not changed, apart from clearing
+// temporary requirements.
+// Returns a non-nil error if the permanent constraints cannot be
+// satisfied, even without any requirements.
+func (r *Resolver) MaximalSatisfiableSubset() (Packages, error) {
+	requires := append(Packages(nil), r.allRequires()...)
+	r.temps = nil
+	tmp, err := r.tempResolver(nil)
+	if err != nil {
+		return nil, err
+	}
+
+	subset, ok := tmp.maximalSubset(nil, requires)
+	if !ok {
+		return nil, errors.New("Permanent constraints cannot be satisfied")
+	}
+	return subset, nil
+}
+
+// Returns the largest subset of a list of optional Packages that can be
+// solved together with all of the requirements, including any pushed and
+// temporary requirements, such as to keep as many optional requirements
+// as possible. Unlike a greedy search, keeping an optional Package never
+// prevents keeping two others. The search is performed with a separate
+// solver, as with MaximalSatisfiableSubset(). Packages are returned in
+// their original order, and a Package listed more than once is only
+// counted once.
+//
+// The state of the Resolver is not changed, apart from clearing
+// temporary requirements.
+// Returns a non-nil error if the requirements cannot be satisfied,
+// even without any of the optional Packages.
+func (r *Resolver) MaximalOptionalSubset(optional Packages) (Packages, error) {
+	requires := append(Packages(nil), r.allRequires()...)
+	r.temps = nil
+	tmp, err := r.tempResolver(nil)
+	if err != nil {
+		return nil, err
+	}
+
+	ids := make([]pigosat.Literal, len(requires))
+	for i, p := range requires {
+		ids[i] = tmp.requireId(p)
+	}
+	subset, ok := tmp.maximalSubset(ids, optional)
+	if !ok {
+		return nil, errors.New("Requirements cannot be satisfied")
+	}
+	return subset, nil
+}
+
+// maximalSubset finds the largest subset of the soft Packages that can
+// be solved together with the hard requirement ids, by repeatedly solving
+// while requiring more of the soft Packages than the last solution
+// satisfied, until no larger subset can be solved. This adds clauses to
+// the solver, so it is only used on a temporary Resolver.
+// Returns false if the hard requirements cannot be satisfied on their own.
+func (r *Resolver) maximalSubset(hard []pigosat.Literal, soft Packages) (Packages, bool) {
+	seen := make(map[pigosat.Literal]bool, len(soft))
+	ids := make([]pigosat.Literal, 0, len(soft))
+	for _, p := range soft {
+		id := r.requireId(p)
+		if !seen[id] {
+			seen[id] = true
+			ids = append(ids, id)
+		}
+	}
+	negated := make([]pigosat.Literal, len(ids))
+	for i, id := range ids {
+		negated[i] = -id
+	}
+
+	r.addRequires(hard)
+	status, solution := r.solver.Solve()
+	if status != pigosat.Satisfiable {
+		return nil, false
+	}
+	for {
+		count := 0
+		for _, id := range ids {
+			if solution[id] {
+				count++
+			}
+		}
+		if count == len(ids) {
+			break
+		}
+
+		// Require at least one more Package than the last solution
+		r.solver.AddClauses(atMostK(negated, len(ids)-count-1, r.idMap.NewAux))
+		r.solver.Adjust(r.idMap.Len())
+
+		r.addRequires(hard)
+		next, nextSolution := r.solver.Solve()
+		if next != pigosat.Satisfiable {
+			break
+		}
+		solution = nextSolution
+	}
+
+	subset := Packages{}
+	for _, p := range soft {
+		id := r.requireId(p)
+		if seen[id] && solution[id] {
+			seen[id] = false
+			subset = append(subset, p)
+		}
+	}
+	return subset, true
+}
+
+// Returns the requirements that are redundant, after a successful call
+// to Resolve(). A requirement is redundant if it would always be selected
+// in a solution of the remaining requirements, even if it was not explicitly
+// required. This is checked by trying to solve the remaining requirements
+// while excluding each requirement in turn. The remaining requirements are
+// everything the last solve assumed, including the pushed assumption
+// frames and the temporary requirements of that solve.
+//
+// The last solve is repeated afterwards with the same assumptions, to
+// restore its state.
+// Returns a non-nil error if the current requirements are not solved.
+func (r *Resolver) RedundantRequirements() (Packages, error) {
+	if r.solver == nil {
+		return nil, errors.New("Solver not initialized.")
+	}
+	if !r.Solved() {
+		return nil, errors.New("Requirements must be successfully resolved " +
+			"before checking for redundant requirements")
+	}
 
-	// Now add multi-version conflicts
-	for name, _ := range prodMap.prods {
-		vers := prodMap.Packages(name)
-		if vers == nil {
-			return fmt.Errorf("Resolve init failure: Version list for product %q was nil", name)
+	assumed := append([]pigosat.Literal(nil), r.assumed...)
+	redundant := Packages{}
+	for _, p := range r.requires {
+		id := r.requireId(p)
+		for _, lit := range assumed {
+			if lit != id {
+				r.solver.Assume(lit)
+			}
 		}
+		r.solver.Assume(-id)
 
-		ids := packagesToIds(vers, idMap)
-		for _, conflict := range buildConflictClauses(ids) {
-			clauses = append(clauses, conflict)
+		if status, _ := r.solver.Solve(); status == pigosat.Unsatisfiable {
+			redundant = append(redundant, p)
 		}
 	}
 
-	// Hint the solver at the size of variables, since we
-	// just built up a Package index.
-	r.solver.Adjust(idMap.Len())
-
-	// Now actually add all the clauses that we had built up,
-	// into the solver.
-	// fmt.Printf("Clauses: %v\n", clauses)
-	r.solver.AddClauses(clauses)
+	// Restore the status of the last solve
+	for _, lit := range assumed {
+		r.solver.Assume(lit)
+	}
+	if status, _ := r.solver.Solve(); status != pigosat.Satisfiable {
+		return nil, errors.New("Failed to restore the last solve")
+	}
+	return redundant, nil
+}
 
-	// fmt.Printf("# variables == %d\n", r.solver.Variables())
-	// fmt.Printf("# clauses == %d\n", r.solver.AddedOriginalClauses())
+// Returns the requirements that are already guaranteed by a Package that
+// was pinned with Pin(), such as requiring B-1.0 while B-1.0 is pinned,
+// or requiring any version of B while a version of B is pinned.
+// Unlike RedundantRequirements(), this only compares the requirements
+// with the pinned Packages, and does not solve.
+func (r *Resolver) RedundantWithPins() Packages {
+	pinned := make(map[string]bool, len(r.pinned))
+	products := make(map[string]bool, len(r.pinned))
+	for _, p := range r.pinned {
+		pinned[packageKey(p)] = true
+		products[p.ProductName()] = true
+	}
 
-	return nil
+	redundant := Packages{}
+	for _, p := range r.requires {
+		if p.Version() == AnyVersion {
+			if products[p.ProductName()] {
+				redundant = append(redundant, p)
+			}
+		} else if pinned[packageKey(p)] {
+			redundant = append(redundant, p)
+		}
+	}
+	return redundant
 }
 
-// addRequires applies the Packages stored as requirements,
-// as assumptions to the solver. These assumptions are valid
-// only for one call to Resolve at a time.
-func (r *Resolver) addRequires() {
-	if r.requires == nil {
-		return
+// ValidateRequirements checks that every current requirement refers
+// to a Package in the package index, without solving. A requirement
+// on an unknown Package would otherwise be solved as a free literal
+// that nothing depends on. A wildcard requirement must refer to a
+// known Product, and every Package of a RequireOneOf() group must
+// exist. A Package replaced with AddReplacement() is checked by its
+// replacement, which is what it resolves to.
+// Returns an error for each unknown Package, in the order of the
+// requirements, or nil if they all exist.
+func (r *Resolver) ValidateRequirements() []error {
+	if r.solver == nil {
+		return []error{errors.New("Solver not initialized.")}
 	}
-	var tid pigosat.Literal
-	for _, p := range r.requires {
-		tid = r.idMap.StringToId(p.PackageName())
-		r.solver.Assume(tid)
+	var errs []error
+	for _, req := range r.requires {
+		pkgs := Packages{req}
+		if g, ok := req.(*oneOfRequirement); ok {
+			pkgs = g.pkgs
+		}
+		for _, p := range pkgs {
+			if p.Version() == AnyVersion {
+				if r.prodMap.Packages(p.ProductName()) == nil {
+					errs = append(errs, fmt.Errorf("Required product %q does not exist in the Resolver", p.ProductName()))
+				}
+				continue
+			}
+			if _, err := r.prodMap.PackageByName(packageKey(r.replacement(p))); err != nil {
+				errs = append(errs, fmt.Errorf("Required package %q does not exist in the Resolver", p.PackageName()))
+			}
+		}
 	}
+	return errs
 }
 
-// Returns the last successfully resolved solution of packages
-func (r *Resolver) Solution() Packages {
-	return r.solution
+// satisfiable checks whether the requirements, along with an extra
+// list of assumed literals, can be solved. The solution is discarded
+// and the temporary requirements are kept for the next solve.
+func (r *Resolver) satisfiable(assumptions []pigosat.Literal) bool {
+	r.addRequires(assumptions)
+	status, _ := r.solver.Solve()
+	return status == pigosat.Satisfiable
 }
 
-// Attempt to resolve a package solution with the currently set criteria.
-// Returns a bool indicating whether the Resolver succeeded or conflicted.
-// Returns a non-nil error if there was an internal error.
-func (r *Resolver) Resolve() (bool, error) {
-	r.solution = Packages{}
-	r.conflicts = nil
+// satisfiableAlone checks whether a list of Packages can be solved
+// together, without the requirements, on a temporary Resolver, so that
+// the solver and the last solve of this Resolver are not changed
+func (r *Resolver) satisfiableAlone(pkgs Packages) (bool, error) {
+	tmp, err := r.tempResolver(nil)
+	if err != nil {
+		return false, err
+	}
+	ids := make([]pigosat.Literal, len(pkgs))
+	for i, p := range pkgs {
+		ids[i] = tmp.requireId(p)
+	}
+	return tmp.satisfiable(ids), nil
+}
 
+// Returns true if two Packages can be part of the same solution,
+// independent of the current requirements. Both Packages are solved
+// on their own against the package index and permanent constraints,
+// with a separate solver, so the state of the Resolver is not changed.
+// Returns a non-nil error if either Package does not exist in the Resolver.
+func (r *Resolver) CanCoexist(a, b Packager) (bool, error) {
 	if r.solver == nil {
-		return false, errors.New("Requirements not set. Solver not initialized.")
+		return false, errors.New("Solver not initialized.")
 	}
 
-	// Push the fixed requirements into the solver
-	r.addRequires()
+	for _, p := range []Packager{a, b} {
+		if _, err := r.idMap.GetId(packageKey(p)); err != nil {
+			return false, fmt.Errorf("Package %q does not exist in the Resolver", p.PackageName())
+		}
+	}
 
-	status, solution := r.solver.Solve()
-	if status != pigosat.Satisfiable {
-		return false, nil
+	return r.satisfiableAlone(Packages{a, b})
+}
+
+// Returns true if two sets of requirements can be satisfied together by
+// the same solution, such as two environments sharing one install,
+// independent of the current requirements. The union of both sets is
+// solved against the package index and permanent constraints. A set that
+// can not be satisfied on its own is not compatible with any set.
+// The check uses a separate solver, so the state of the Resolver is
+// not changed.
+// Returns a non-nil error if a Package in either set does not exist
+// in the Resolver.
+func (r *Resolver) SetsCompatible(a, b Packages) (bool, error) {
+	if r.solver == nil {
+		return false, errors.New("Solver not initialized.")
 	}
 
-	var (
-		pkgName string
-		pkg     Packager
-		err     error
-	)
-	// Remap the literal ids from the solution back into
-	// the original objects
-	for i := 1; i < len(solution); i++ {
-		if solution[i] {
-			pkgName = r.idMap.IdToString(pigosat.Literal(i))
-			if pkg, err = r.prodMap.PackageByName(pkgName); err != nil {
-				return false, fmt.Errorf("Resolve failed to look up package by name %q: %s",
-					pkgName, err.Error())
+	pkgs := append(append(Packages(nil), a...), b...)
+	for _, p := range pkgs {
+		if p.Version() == AnyVersion {
+			if r.prodMap.Packages(p.ProductName()) == nil {
+				return false, fmt.Errorf("Product %q does not exist in the Resolver", p.ProductName())
 			}
-			r.solution = append(r.solution, pkg)
+			continue
+		}
+		if _, err := r.idMap.GetId(packageKey(p)); err != nil {
+			return false, fmt.Errorf("Package %q does not exist in the Resolver", p.PackageName())
 		}
 	}
-	return true, nil
+	return r.satisfiableAlone(pkgs)
 }
 
-// Returns the returned by the last call to Resolve(),
-// indicating whether the current requirements are solved or not.
-func (r *Resolver) Solved() bool {
-	if r.solver == nil {
-		return false
+// Checks the requirements, including any pushed and temporary
+// requirements, for conflicts that can be found without invoking the
+// solver: more than one version of the same Product, unless the Product
+// allows multiple versions, and Packages forbidden with Forbid().
+// Returns true and the offending requirements if a conflict was found.
+// A false result does not mean that the requirements can be solved.
+func (r *Resolver) QuickConflictCheck() (bool, Packages) {
+	forbidden := make(map[string]bool, len(r.forbidden))
+	for _, p := range r.forbidden {
+		forbidden[packageKey(p)] = true
 	}
 
-	return r.solver.Res() == pigosat.Satisfiable
-}
+	requires := r.allRequires()
+	versions := make(map[string]map[string]bool, len(requires))
+	for _, p := range requires {
+		if p.Version() == AnyVersion || r.multi[p.ProductName()] {
+			continue
+		}
+		if versions[p.ProductName()] == nil {
+			versions[p.ProductName()] = make(map[string]bool)
+		}
+		versions[p.ProductName()][packageKey(p)] = true
+	}
 
-// Add a package as a requirement that must be satisfied by the solver.
-// This addition is only valid until the next call to Resolve(),
-// after which it will be removed.
-func (r *Resolver) RequireTemp(p Packager) {
-	tid := r.idMap.StringToId(p.PackageName())
-	r.solver.Assume(tid)
+	seen := make(map[string]bool)
+	offending := Packages{}
+	for _, p := range requires {
+		name := packageKey(p)
+		if seen[name] {
+			continue
+		}
+		if forbidden[name] || len(versions[p.ProductName()]) > 1 {
+			seen[name] = true
+			offending = append(offending, p)
+		}
+	}
+	return len(offending) > 0, offending
 }
 
 // Return true if a given required package (by name) caused the Resolver
 // to fail. Only makes sense to call this after having called Resolve()
 // and finding that the resolve was not successful.
 func (r *Resolver) IsPackageNameConflict(packageName string) bool {
-	id, err := r.idMap.GetId(packageName)
+	if r.solver == nil {
+		return false
+	}
+	key := packageName
+	if p, err := r.prodMap.PackageByName(packageName); err == nil {
+		key = packageKey(p)
+	}
+	id, err := r.idMap.GetId(key)
 	if err != nil {
 		return false
 	}
@@ -273,7 +3034,16 @@ func (r *Resolver) IsPackageNameConflict(packageName string) bool {
 // to fail. Only makes sense to call this after having called Resolve()
 // and finding that the resolve was not successful.
 func (r *Resolver) IsPackageConflict(p Packager) bool {
-	id, err := r.idMap.GetId(p.PackageName())
+	if r.solver == nil {
+		return false
+	}
+	if p.Version() == AnyVersion {
+		if w, ok := r.wildcards[p.ProductName()]; ok {
+			return r.solver.FailedAssumption(w.lit)
+		}
+		return false
+	}
+	id, err := r.idMap.GetId(packageKey(p))
 	if err != nil {
 		return false
 	}
@@ -283,27 +3053,57 @@ func (r *Resolver) IsPackageConflict(p Packager) bool {
 // Returns a package list of all Packages that were requirements, or added with
 // RequireTemp(), that caused the Resolver to fail. Only makes sense to call this
 // after having called Resolve() and finding that the resolve was not successful.
+// The Packages are ordered by their priority set by SetRequirementPriority(),
+// highest first, and otherwise in the order reported by the solver.
 func (r *Resolver) Conflicts() Packages {
+	if r.solver == nil && r.compacted != nil {
+		return r.compacted.conflicts
+	}
 	ids := r.solver.FailedAssumptions()
-	packs := make(Packages, len(ids))
-	var (
-		name string
-		pak  Packager
-	)
-	for i, id := range ids {
-		name = r.idMap.IdToString(id)
-		pak, _ = r.prodMap.PackageByName(name)
-		packs[i] = pak
+	packs := make(Packages, 0, len(ids))
+	seen := make(map[pigosat.Literal]bool, len(ids))
+	for _, id := range ids {
+		// A forced Package is reported as the requirement that forces it
+		if origin, ok := r.forced[id]; ok {
+			id = origin
+		}
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+		p, _ := r.literalPackage(id)
+		packs = append(packs, p)
+	}
+	if len(r.priority) > 0 {
+		sort.SliceStable(packs, func(i, j int) bool {
+			return r.priority[packageKey(packs[i])] > r.priority[packageKey(packs[j])]
+		})
 	}
 	return packs
 }
 
+// Sets the priority of a requirement, for reporting conflicts. When
+// more than one requirement causes the Resolver to fail, Conflicts()
+// lists the requirements with a higher priority first, so that the most
+// important conflict is reported first. Requirements default to a
+// priority of 0. The priority does not affect solving.
+func (r *Resolver) SetRequirementPriority(p Packager, prio int) {
+	if r.priority == nil {
+		r.priority = make(map[string]int)
+	}
+	r.priority[packageKey(p)] = prio
+}
+
 // If the previous call to Resolve() returned false, meaning the
 // currently requirements are no solvable, then this method builds
 // a list of the packages involved in the conflict.
 //
 // Returns a slice of PackageRelations, which describe 1 or 2 packages,
-// and a descriptive Relation flag
+// and a descriptive Relation flag. The relations are ordered by their
+// Relation, from the requirements through their dependencies to the
+// conflicts, and then by the names of their Packages, so that the report
+// is stable across runs.
+// Returns a non-nil error if tracing was disabled with SetTracing().
 func (r *Resolver) DetailedConflicts() (PackageRelations, error) {
 	if r.Solved() {
 		return PackageRelations{}, nil
@@ -313,12 +3113,16 @@ func (r *Resolver) DetailedConflicts() (PackageRelations, error) {
 		return r.conflicts, nil
 	}
 
-	var buf bytes.Buffer
-	if err := r.solver.WriteClausalCore(&buf); err != nil {
-		return nil, fmt.Errorf("Failed to generate detailed conflict report: %s", err.Error())
+	if r.noTrace {
+		return nil, errors.New("Detailed conflicts are not available, because tracing is disabled")
+	}
+	if r.solver == nil {
+		return nil, errors.New("Detailed conflicts are not available, because the solver was released")
 	}
 
-	pkgs, err := r.cnfToPackageRelations(&buf)
+	core := r.clausalCore()
+	defer core.Close()
+	pkgs, err := r.cnfToPackageRelations(core)
 	if err != nil {
 		return nil, err
 	}
@@ -327,9 +3131,273 @@ func (r *Resolver) DetailedConflicts() (PackageRelations, error) {
 	return pkgs, nil
 }
 
+// WarmUp prepares the Resolver for reporting conflicts, to lower the
+// latency of the first call to DetailedConflicts() after a failed resolve.
+// It runs a throwaway solve on a separate solver for the same package
+// index, that assumes a Package both in and out of the solution, which
+// can never be satisfied, so that the conflict is traced, and streams and
+// parses the resulting clausal core. The solver of the Resolver is not
+// used, so the status, requirements, solution and conflicts of the last
+// solve are not changed. It does nothing for an empty package index.
+// Returns a non-nil error if tracing was disabled with SetTracing(), as
+// detailed conflicts would not be available, or if the solver was
+// released with Compact().
+func (r *Resolver) WarmUp() error {
+	if r.noTrace {
+		return errors.New("Detailed conflicts are not available, because tracing is disabled")
+	}
+	if r.solver == nil {
+		return errors.New("Detailed conflicts are not available, because the solver was released")
+	}
+	if r.idMap.Len() == 0 {
+		return nil
+	}
+
+	tmp, err := r.tempResolver(nil)
+	if err != nil {
+		return err
+	}
+	defer tmp.solver.Delete()
+	tmp.solver.Assume(1)
+	tmp.solver.Assume(-1)
+	tmp.solver.Solve()
+
+	core := tmp.clausalCore()
+	defer core.Close()
+	_, err = readClauses(core)
+	return err
+}
+
+// If the previous call to Resolve() returned false, this method returns
+// the raw clauses of the clausal core of the conflict, as literal ids,
+// which are the data that DetailedConflicts() interprets. This allows
+// implementing custom explanations. Use LiteralName() to map the literal
+// ids back to Package names.
+// Returns an empty list if the requirements are solved.
+// Returns a non-nil error if tracing was disabled with SetTracing().
+func (r *Resolver) FailedClauses() (pigosat.Formula, error) {
+	if r.Solved() {
+		return pigosat.Formula{}, nil
+	}
+	if r.noTrace {
+		return nil, errors.New("Failed clauses are not available, because tracing is disabled")
+	}
+	if r.solver == nil {
+		return nil, errors.New("Failed clauses are not available, because the solver was released")
+	}
+
+	core := r.clausalCore()
+	defer core.Close()
+	return readClauses(core)
+}
+
+// Returns the name of the Package for a literal id, or of the wildcard
+// requirement for a wildcard literal. A negative id returns the name
+// of the Package it negates.
+// Returns an empty string for an auxiliary literal of an encoding,
+// or an id that is not known to the Resolver.
+func (r *Resolver) LiteralName(l pigosat.Literal) string {
+	p, err := r.literalPackage(l)
+	if err != nil || p == nil {
+		return ""
+	}
+	return p.PackageName()
+}
+
+// Returns a copy of the mapping of the literal ids of the solver to the
+// Packages they represent, such as to correlate the output of WriteDIMACS()
+// or FailedClauses() with the Packages. Each Package is named by its key,
+// which is the SatKey() of a KeyPackager, or else its PackageName(), and
+// can be looked up with PackageByName(). Auxiliary literals of encodings
+// are not included.
+func (r *Resolver) LiteralMapping() map[int]string {
+	if r.solver == nil {
+		return map[int]string{}
+	}
+	mapping := make(map[int]string, len(r.idMap.i_map))
+	for id, name := range r.idMap.i_map {
+		mapping[int(id)] = name
+	}
+	return mapping
+}
+
+// Returns the literal id of a Package known to the Resolver,
+// and false if the Package is not known.
+func (r *Resolver) PackageLiteral(p Packager) (int, bool) {
+	if r.solver == nil {
+		return 0, false
+	}
+	id, err := r.idMap.GetId(packageKey(p))
+	if err != nil {
+		return 0, false
+	}
+	return int(id), true
+}
+
+// Writes the proof that the last call to Resolve() was unsatisfiable to w,
+// so that it can be checked independently of pakr. The proof is the
+// extended resolution trace of PicoSAT, in the TraceCheck format, where
+// the literals are the ids of the Packages. Use Freeze() to record the
+// names of the literal ids.
+// Returns a non-nil error if the last solve was not unsatisfiable,
+// or tracing is disabled.
+func (r *Resolver) WriteProof(w io.Writer) error {
+	if r.solver == nil || r.solver.Res() != pigosat.Unsatisfiable {
+		return errors.New("A proof is only available after an unsatisfiable resolve")
+	}
+	if r.noTrace {
+		return errors.New("A proof is not available, because tracing is disabled")
+	}
+	if err := r.solver.WriteExtendedTrace(w); err != nil {
+		return fmt.Errorf("Failed to write the proof: %s", err.Error())
+	}
+	return nil
+}
+
+// If the previous call to Resolve() returned false, this method builds
+// the smallest explanation of the conflict. The clausal core that is used
+// by DetailedConflicts() may contain relations that are not needed for the
+// contradiction. Each relation of the core is removed in turn, and stays
+// removed if the remaining relations still contradict the failed
+// requirements. The result is a minimal set of relations, where every
+// relation is needed to explain the failure.
+//
+// This solves the core once per relation, so it is more expensive than
+// DetailedConflicts(), but produces a much shorter report for conflicts
+// deep in the dependency graph.
+// Returns a non-nil error if tracing was disabled with SetTracing().
+func (r *Resolver) SmallestConflict() (PackageRelations, error) {
+	if r.Solved() {
+		return PackageRelations{}, nil
+	}
+
+	if r.noTrace {
+		return nil, errors.New("Conflicts are not available, because tracing is disabled")
+	}
+	if r.solver == nil {
+		return nil, errors.New("Conflicts are not available, because the solver was released")
+	}
+
+	stream := r.clausalCore()
+	core, err := readClauses(stream)
+	stream.Close()
+	if err != nil {
+		return nil, err
+	}
+	assumptions := r.solver.FailedAssumptions()
+
+	for i := 0; i < len(core); {
+		trial := append(append(pigosat.Formula(nil), core[:i]...), core[i+1:]...)
+		unsat, err := unsatisfiable(trial, assumptions)
+		if err != nil {
+			return nil, err
+		}
+		if unsat {
+			core = trial
+		} else {
+			i++
+		}
+	}
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "p cnf %d %d\n", r.idMap.Len(), len(core))
+	for _, clause := range core {
+		for _, lit := range clause {
+			fmt.Fprintf(&buf, "%d ", lit)
+		}
+		buf.WriteString("0\n")
+	}
+	return r.cnfToPackageRelations(&buf)
+}
+
+// unsatisfiable checks whether a formula is unsatisfiable under
+// a list of assumed literals, using a new solver
+func unsatisfiable(clauses pigosat.Formula, assumptions []pigosat.Literal) (bool, error) {
+	solver, err := pigosat.New(nil)
+	if err != nil {
+		return false, fmt.Errorf("Failed to initialize a solver object from pigosat: %s", err.Error())
+	}
+	defer solver.Delete()
+
+	solver.AddClauses(clauses)
+	for _, lit := range assumptions {
+		solver.Assume(lit)
+	}
+	status, _ := solver.Solve()
+	return status == pigosat.Unsatisfiable, nil
+}
+
+// A reader of the clausal core, that is streamed from the solver
+type coreReader struct {
+	*io.PipeReader
+	done chan struct{}
+}
+
+// Close the reader, and wait for the solver to stop writing
+// to it, so that the solver can be used again
+func (c *coreReader) Close() error {
+	err := c.PipeReader.Close()
+	<-c.done
+	return err
+}
+
+// clausalCore streams the clausal core of the last conflict from the
+// solver, so that it is parsed while the solver writes it, instead of
+// buffering it in full. An error of the solver is returned by a read.
+// The reader must be closed before the solver is used again.
+func (r *Resolver) clausalCore() io.ReadCloser {
+	pr, pw := io.Pipe()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		if err := r.solver.WriteClausalCore(pw); err != nil {
+			pw.CloseWithError(fmt.Errorf("Failed to generate the clausal core: %s", err.Error()))
+			return
+		}
+		pw.Close()
+	}()
+	return &coreReader{pr, done}
+}
+
+// readClauses parses the clauses of a DIMACS CNF stream,
+// skipping the comments and preamble
+func readClauses(stream io.Reader) (pigosat.Formula, error) {
+	var clauses pigosat.Formula
+
+	buf := bufio.NewScanner(stream)
+	for buf.Scan() {
+		line := buf.Text()
+		if len(line) == 0 || line[0] == 'c' || line[0] == 'p' {
+			continue
+		}
+
+		fields := strings.Fields(line)
+		clause := make([]pigosat.Literal, 0, len(fields))
+		for _, f := range fields {
+			lit, err := strconv.ParseInt(f, 10, 32)
+			if err != nil {
+				return nil, fmt.Errorf("Error parsing int %q from line %q", f, line)
+			}
+			if lit == 0 {
+				break
+			}
+			clause = append(clause, pigosat.Literal(lit))
+		}
+		clauses = append(clauses, clause)
+	}
+
+	if err := buf.Err(); err != nil {
+		return nil, err
+	}
+	return clauses, nil
+}
+
 // Return a Package by its name.
 // If the Package is not known to the Resolver, return an error
 func (r *Resolver) PackageByName(packageName string) (Packager, error) {
+	if r.solver == nil {
+		return nil, errors.New("Solver not initialized.")
+	}
 	return r.prodMap.PackageByName(packageName)
 }
 
@@ -382,96 +3450,105 @@ func (r *Resolver) PackageByName(packageName string) (Packager, error) {
 // into relationship structures that define the progression that
 // led to a failed solve.
 func (r *Resolver) cnfToPackageRelations(stream io.Reader) (PackageRelations, error) {
+	clauses, err := readClauses(stream)
+	if err != nil {
+		return nil, err
+	}
+
+	// The versions in the compact conflict encoding of each Product
 	var (
-		line   string
-		clause int
-		err    error
-		parsed int64
-		rels   PackageRelations
+		products []string
+		versions = make(map[string][]int)
 	)
 
-	buf := bufio.NewScanner(stream)
-	for buf.Scan() {
-		line = buf.Text()
-		if len(line) == 0 {
-			continue
-		}
-
-		switch string(line[0]) {
-
-		case "c":
-			// "c" is a commented line
-			continue
-
-		case "p":
-			// "p" indicates the preamble, defining structure sizes
-			fields := strings.Fields(line)
-			size, err := strconv.ParseInt(fields[len(fields)-1], 10, 32)
-			if err != nil {
-				return nil, err
+	rels := make(PackageRelations, 0, len(clauses))
+clauses:
+	for _, clause := range clauses {
+		for _, lit := range clause {
+			id := lit
+			if id < 0 {
+				id = -id
 			}
-			rels = make(PackageRelations, size, size)
-
-		default:
-			// parse a clause, one per line
-			fields := strings.Fields(line)
-			if len(fields) == 0 {
-				return nil, fmt.Errorf("Expected to parse literals, but got none in line: %s", line)
+			product, ok := r.amo[id]
+			if !ok {
+				continue
 			}
-
-			lits := make([]int, len(fields)-1)
-			negs := 0
-			for i, f := range fields {
-				if f == "0" {
-					continue
-				}
-				if parsed, err = strconv.ParseInt(f, 10, 32); err != nil {
-					return nil, fmt.Errorf("Error parsing int %r from line %r", f, line)
-				}
-				if parsed < 0 {
-					negs++
+			if _, seen := versions[product]; !seen {
+				products = append(products, product)
+				versions[product] = []int{}
+			}
+			for _, other := range clause {
+				if other < 0 && !r.isAux(other) && !slices.Contains(versions[product], int(other)) {
+					versions[product] = append(versions[product], int(other))
 				}
-				lits[i] = int(parsed)
 			}
+			continue clauses
+		}
+
+		lits := make([]int, 0, len(clause))
+		negs := 0
+		for _, lit := range clause {
+			if r.isAux(lit) {
+				// Auxiliary literals of an encoding are not Packages
+				continue
+			}
+			if lit < 0 {
+				negs++
+			}
+			lits = append(lits, int(lit))
+		}
+		if len(lits) == 0 {
+			continue
+		}
 
-			sort.Ints(lits)
+		sort.Ints(lits)
 
-			paks := make(Packages, len(lits))
-			for i, l := range lits {
-				if paks[i], err = r.PackageByName(r.idMap.IdToString(pigosat.Literal(l))); err != nil {
-					return nil, fmt.Errorf("Unexpected literal %r in line %r "+
-						"could not be mapped back to Package name", l, line)
-				}
+		paks := make(Packages, len(lits))
+		for i, l := range lits {
+			if paks[i], err = r.literalPackage(pigosat.Literal(l)); err != nil {
+				return nil, fmt.Errorf("Unexpected literal %d in clause %v "+
+					"could not be mapped back to Package name", l, clause)
 			}
+		}
 
-			var relates Relation
-			if len(lits) == 1 {
-				// We parsed a single literal
-				if lits[0] > 0 {
-					relates = Required
-				} else {
-					relates = Restricts
-				}
+		var relates Relation
+		if len(lits) == 1 {
+			// We parsed a single literal
+			if lits[0] > 0 {
+				relates = Required
 			} else {
-				// We parsed multiple valid literals
-				if negs == 1 {
-					relates = Depends
-				} else if negs > 1 {
-					relates = Conflicts
-				} else {
-					return nil, fmt.Errorf("Unhandled clause type for line: %s", line)
-				}
+				relates = Restricts
+			}
+		} else {
+			// We parsed multiple valid literals
+			if negs == 1 {
+				relates = Depends
+			} else if negs > 1 {
+				relates = Conflicts
+			} else {
+				relates = RequiredOneOf
 			}
-			rels[clause] = &PackageRelation{paks, relates}
-			clause++
 		}
-
+		rels = append(rels, &PackageRelation{paks, relates})
 	}
 
-	if err := buf.Err(); err != nil {
-		return nil, err
+	// Each Product of the compact encoding conflicts with itself
+	for _, product := range products {
+		lits := versions[product]
+		if len(lits) < 2 {
+			continue
+		}
+		sort.Ints(lits)
+		paks := make(Packages, len(lits))
+		for i, l := range lits {
+			if paks[i], err = r.literalPackage(pigosat.Literal(l)); err != nil {
+				return nil, fmt.Errorf("Unexpected literal %d could not be mapped back to Package name", l)
+			}
+		}
+		rels = append(rels, &PackageRelation{paks, Conflicts})
 	}
 
+	sortRelations(rels)
 	return rels, nil
 }
 
@@ -542,6 +3619,14 @@ func (m *stringIdMap) StringToId(s string) pigosat.Literal {
 	return m.i
 }
 
+// NewAux returns a new unique id that is not mapped to any string.
+// Auxiliary ids are used for helper variables in the SAT clauses,
+// that do not represent a Package.
+func (m *stringIdMap) NewAux() pigosat.Literal {
+	m.i++
+	return m.i
+}
+
 // GetId looks up an id for an existing string mapping.
 // If no id exists, then return an error
 func (m *stringIdMap) GetId(s string) (pigosat.Literal, error) {
@@ -0,0 +1,95 @@
+package pakr
+
+import (
+	"math/rand"
+
+	"github.com/justinfx/pigosat"
+)
+
+// The propagation limit of the first attempt of ResolveWithRestarts(),
+// which doubles for each following attempt
+const restartPropagations = 10000
+
+// Resolves a package solution with the currently set criteria, by
+// restarting the search up to a number of attempts. Each attempt but the
+// last is given a limited number of propagations, and every attempt after
+// the first prefers a different random order of the Packages, which is
+// seeded by the attempt number so that the results are repeatable. The
+// last attempt is not limited, so that it always reaches a result.
+//
+// This only helps requirements that can be solved, but are hard for the
+// fixed order of a single search. Requirements that can not be solved are
+// reported as soon as any attempt proves it, which may still take the
+// full search of the last attempt.
+//
+// The attempts are performed with separate solvers. The solver of the
+// attempt that reaches the result then replaces the solver of the Resolver,
+// so that Solution(), Conflicts() and DetailedConflicts() report it, as
+// after Resolve(). It keeps the propagation limit of its attempt until
+// the Resolver is initialized again. Temporary requirements are cleared.
+// Returns a bool indicating whether the Resolver succeeded or conflicted,
+// and the solution.
+// Returns a non-nil error if there was an internal error.
+func (r *Resolver) ResolveWithRestarts(attempts int) (bool, Packages, error) {
+	requires := append(Packages(nil), r.allRequires()...)
+	r.temps = nil
+	if attempts < 1 {
+		attempts = 1
+	}
+
+	for i := 0; i < attempts; i++ {
+		tmp := r.tempConfig(requires)
+		if i < attempts-1 {
+			tmp.propLimit = restartPropagations << uint(i)
+		}
+		if err := tmp.Initialize(); err != nil {
+			return false, nil, err
+		}
+
+		if i > 0 {
+			rnd := rand.New(rand.NewSource(int64(i)))
+			for id := 1; id <= int(tmp.idMap.i); id++ {
+				if rnd.Intn(2) == 0 {
+					tmp.solver.SetMoreImportant(pigosat.Literal(id))
+				}
+			}
+		}
+
+		ok, err := tmp.Resolve()
+		if err != nil {
+			return false, nil, err
+		}
+		if ok {
+			r.adopt(tmp)
+			return true, tmp.Solution(), nil
+		}
+		if tmp.solver.Res() == pigosat.Unsatisfiable || i == attempts-1 {
+			r.adopt(tmp)
+			return false, nil, nil
+		}
+	}
+	return false, nil, nil
+}
+
+// adopt replaces the solver of the Resolver, along with the literal
+// mappings and the results of its last solve, with those of a temporary
+// Resolver that shares the configuration of this Resolver
+func (r *Resolver) adopt(tmp *Resolver) {
+	if r.solver != nil {
+		r.solver.Delete()
+	}
+	r.solver = tmp.solver
+	r.idMap = tmp.idMap
+	r.prodMap = tmp.prodMap
+	r.assumed = tmp.assumed
+	r.forced = tmp.forced
+	r.units = tmp.units
+	r.wildcards = tmp.wildcards
+	r.groups = tmp.groups
+	r.amo = tmp.amo
+	r.kinds = tmp.kinds
+	r.warnings = tmp.warnings
+	r.compacted = nil
+	r.solution = tmp.solution
+	r.conflicts = nil
+}
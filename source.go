@@ -0,0 +1,180 @@
+package pakr
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"iter"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/justinfx/pigosat"
+)
+
+// An IndexSource provides the Dependencies of a package index, so that
+// a Resolver can build its clauses from an index that is never held in
+// a single []Dependency, such as rows streamed from a database.
+//
+// Dependencies may be called more than once for each Initialize() of a
+// Resolver, such as once to sort the Packages and once to build the
+// clauses, and must yield the same Dependencies each time.
+type IndexSource interface {
+	Dependencies() iter.Seq[Dependency]
+}
+
+// A SliceIndexSource is an IndexSource for an index
+// that is already held in a []Dependency
+type SliceIndexSource []Dependency
+
+// Returns a sequence of the Dependencies in the slice
+func (s SliceIndexSource) Dependencies() iter.Seq[Dependency] {
+	return slices.Values(s)
+}
+
+// NewResolverFromSource creates a new Resolver, which reads its package
+// index from an IndexSource. Dependencies added with AddDependency() are
+// kept in addition to the Dependencies of the source.
+// Panics if the package index is invalid, such as two different Packages
+// sharing the same PackageName, or a Package defined by two sources.
+// Use NewResolverFromSourceE() to get the error instead.
+func NewResolverFromSource(requires Packages, source IndexSource) *Resolver {
+	r, err := NewResolverFromSourceE(requires, source)
+	if err != nil {
+		panic(err)
+	}
+	return r
+}
+
+// NewResolverFromSourceE creates a new Resolver, which reads its package
+// index from an IndexSource, as with NewResolverFromSource().
+// Returns a non-nil error if the package index is invalid.
+func NewResolverFromSourceE(requires Packages, source IndexSource) (*Resolver, error) {
+	r := &Resolver{requires: requires, source: source}
+	if err := r.Initialize(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// Set the IndexSource that provides the package index.
+// Replaces any index set with SetPackageIndex(), or
+// the formula of a Resolver loaded with LoadFrozen().
+// Resets the internal solver and state.
+// Returns a non-nil error if the package index is invalid, in which
+// case the Resolver keeps its previous index.
+func (r *Resolver) SetIndexSource(source IndexSource) error {
+	prevIndex, prevFrozen, prevSource := r.index, r.frozen, r.source
+	r.source = source
+	r.index = nil
+	r.frozen = nil
+	if err := r.Initialize(); err != nil {
+		r.index, r.frozen, r.source = prevIndex, prevFrozen, prevSource
+		r.reinitialize()
+		return err
+	}
+	return nil
+}
+
+// dependencies returns a sequence of the Dependencies of the
+// IndexSource, if any, followed by those of the package index.
+// Replaced Packages are dropped, and references to them are
+// rewritten to their replacements.
+func (r *Resolver) dependencies() iter.Seq[Dependency] {
+	return func(yield func(Dependency) bool) {
+		if r.source != nil {
+			for dep := range r.source.Dependencies() {
+				if dep, ok := r.replaceDependency(dep); ok && !yield(dep) {
+					return
+				}
+			}
+		}
+		for _, dep := range r.index {
+			if dep, ok := r.replaceDependency(dep); ok && !yield(dep) {
+				return
+			}
+		}
+	}
+}
+
+// replaceDependency applies the replacements to a Dependency.
+// Returns false if the target of the Dependency is replaced.
+func (r *Resolver) replaceDependency(dep Dependency) (Dependency, bool) {
+	if len(r.replaces) == 0 {
+		return dep, true
+	}
+	if _, ok := r.replaces[packageKey(dep.Target)]; ok {
+		return dep, false
+	}
+
+	var requires []Packages
+	for i, vers := range dep.Requires {
+		for j, ver := range vers {
+			if _, ok := r.replaces[packageKey(ver)]; !ok {
+				continue
+			}
+			if requires == nil {
+				// Copy the groups on the first replacement,
+				// to not modify the package index
+				requires = make([]Packages, len(dep.Requires))
+				for k, group := range dep.Requires {
+					requires[k] = append(Packages(nil), group...)
+				}
+			}
+			requires[i][j] = r.replacement(ver)
+		}
+	}
+	if requires != nil {
+		dep.Requires = requires
+	}
+	return dep, true
+}
+
+// IndexFingerprint returns a stable hash of the package index, including
+// the Dependencies of an IndexSource and those added with AddDependency(),
+// for use as a cache key. The fingerprint depends on the content of the
+// index as it is resolved, after the replacements of AddReplacement() are
+// applied, so adding a replacement changes it. Reordering the Dependencies,
+// their requires-groups, or the members of a group does not change it,
+// while changing any Package or dependency does. A Resolver loaded with
+// LoadFrozen() is fingerprinted by its frozen clauses.
+func (r *Resolver) IndexFingerprint() string {
+	pkgString := func(p Packager) string {
+		return fmt.Sprintf("%q %q %q", packageKey(p), p.ProductName(), p.Version())
+	}
+
+	var lines []string
+	for dep := range r.dependencies() {
+		groups := make([]string, len(dep.Requires))
+		for i, vers := range dep.Requires {
+			members := make([]string, len(vers))
+			for j, ver := range vers {
+				members[j] = pkgString(ver)
+			}
+			sort.Strings(members)
+			groups[i] = "(" + strings.Join(members, " | ") + ")"
+		}
+		sort.Strings(groups)
+		lines = append(lines, pkgString(dep.Target)+" : "+strings.Join(groups, " & "))
+	}
+	sort.Strings(lines)
+
+	h := sha256.New()
+	for _, line := range lines {
+		fmt.Fprintln(h, line)
+	}
+	if r.frozen != nil {
+		ids := make([]int, 0, len(r.frozen.packages))
+		for id := range r.frozen.packages {
+			ids = append(ids, int(id))
+		}
+		sort.Ints(ids)
+		for _, id := range ids {
+			fmt.Fprintf(h, "v %d %s\n", id, pkgString(r.frozen.packages[pigosat.Literal(id)]))
+		}
+		for _, clause := range r.frozen.clauses {
+			fmt.Fprintln(h, clause)
+		}
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
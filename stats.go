@@ -0,0 +1,90 @@
+package pakr
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// IndexStatistics describes the shape of a package index
+type IndexStatistics struct {
+	// The number of distinct Products
+	Products int
+	// The number of distinct Packages, including Packages
+	// that are only referenced in a requires-group
+	Packages int
+	// The number of Dependencies in the index
+	Dependencies int
+	// The total number of Package references in all requires-groups
+	Edges int
+	// The number of Products, by their number of versions
+	VersionCounts map[int]int
+	// The Product with the most versions, and its number of versions.
+	// Ties are broken by the lowest product name.
+	MaxVersionsProduct string
+	MaxVersions        int
+	// The number of conflict pairs between versions of the same
+	// Product, that a pairwise encoding would generate. The Resolver
+	// encodes Products with many versions more compactly, and none for
+	// Products allowed multiple versions, so see EstimatedClauseCount()
+	// for the clauses it generates.
+	ConflictPairs int64
+}
+
+func (s IndexStatistics) String() string {
+	counts := make([]int, 0, len(s.VersionCounts))
+	for n := range s.VersionCounts {
+		counts = append(counts, n)
+	}
+	sort.Ints(counts)
+
+	var buf strings.Builder
+	fmt.Fprintf(&buf, "Products: %d\n", s.Products)
+	fmt.Fprintf(&buf, "Packages: %d\n", s.Packages)
+	fmt.Fprintf(&buf, "Dependencies: %d\n", s.Dependencies)
+	fmt.Fprintf(&buf, "Dependency edges: %d\n", s.Edges)
+	fmt.Fprintf(&buf, "Most versions: %s (%d)\n", s.MaxVersionsProduct, s.MaxVersions)
+	fmt.Fprintf(&buf, "Conflict pairs: %d\n", s.ConflictPairs)
+	buf.WriteString("Versions per product:\n")
+	for _, n := range counts {
+		fmt.Fprintf(&buf, "    %d: %d\n", n, s.VersionCounts[n])
+	}
+	return buf.String()
+}
+
+// IndexStats analyzes the shape of a package index, without resolving.
+// The number of versions of the largest Product is a useful warning sign,
+// as the number of conflict clauses grows with the square of the number
+// of versions of each Product.
+func IndexStats(index []Dependency) IndexStatistics {
+	stats := IndexStatistics{
+		Dependencies:  len(index),
+		VersionCounts: make(map[int]int),
+	}
+
+	for _, dep := range index {
+		for _, vers := range dep.Requires {
+			stats.Edges += len(vers)
+		}
+	}
+
+	flat := flattenDependencies(slices.Values(index))
+	stats.Packages = len(flat)
+
+	versions := make(map[string]int)
+	for _, p := range flat {
+		versions[p.ProductName()]++
+	}
+	stats.Products = len(versions)
+
+	for product, n := range versions {
+		stats.VersionCounts[n]++
+		stats.ConflictPairs += int64(n) * int64(n-1) / 2
+		if n > stats.MaxVersions || (n == stats.MaxVersions && product < stats.MaxVersionsProduct) {
+			stats.MaxVersions = n
+			stats.MaxVersionsProduct = product
+		}
+	}
+	return stats
+}
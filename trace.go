@@ -0,0 +1,107 @@
+package pakr
+
+import (
+	"strconv"
+
+	"github.com/justinfx/pigosat"
+)
+
+// A TraceStep is a single assignment of a Package,
+// in the order it was made by the solver
+type TraceStep struct {
+	Package Packager
+	// True if the assignment was a decision, such as an assumed
+	// requirement, and false if it was propagated from a clause
+	Decision bool
+	// Whether the Package was assigned to be
+	// part of the solution (true) or not (false)
+	Value bool
+}
+
+func (s TraceStep) String() string {
+	kind := "propagate"
+	if s.Decision {
+		kind = "decide"
+	}
+	return kind + " " + s.Package.PackageName() + " = " + strconv.FormatBool(s.Value)
+}
+
+// Returns the sequence of assignments made by the last solve.
+//
+// PicoSAT does not expose the decisions it makes during a search, only
+// the clausal core of an unsatisfiable solve, so the trace is scoped to
+// what can be reconstructed from it. The trace begins with the assumed
+// requirements, as decisions. For a failed solve, it continues with the
+// assignments propagated by the clauses of the core, starting from the
+// assumptions, up to the point where the core contradicts itself. For a
+// successful solve, only the assumptions are reported; use Solution()
+// for the final assignment.
+//
+// Auxiliary literals that do not represent a Package are not reported.
+// Propagation over the core requires tracing, so a failed solve with
+// tracing disabled by SetTracing() only reports the assumptions.
+func (r *Resolver) LastTrace() []TraceStep {
+	steps := []TraceStep{}
+	if r.solver == nil {
+		return steps
+	}
+
+	assigned := make(map[pigosat.Literal]bool)
+	add := func(lit pigosat.Literal, decision bool) {
+		assigned[lit] = true
+		if p, err := r.literalPackage(lit); err == nil {
+			steps = append(steps, TraceStep{p, decision, lit > 0})
+		}
+	}
+
+	for _, lit := range r.assumed {
+		if !assigned[lit] {
+			add(lit, true)
+		}
+	}
+
+	if r.noTrace || r.solver.Res() != pigosat.Unsatisfiable {
+		return steps
+	}
+
+	stream := r.clausalCore()
+	core, err := readClauses(stream)
+	stream.Close()
+	if err != nil {
+		return steps
+	}
+
+	// Unit propagation over the core, until nothing
+	// more can be propagated or a clause is falsified
+	for changed := true; changed; {
+		changed = false
+		for _, clause := range core {
+			var (
+				unit       pigosat.Literal
+				unassigned int
+				satisfied  bool
+			)
+			for _, lit := range clause {
+				if assigned[lit] {
+					satisfied = true
+					break
+				}
+				if !assigned[-lit] {
+					unassigned++
+					unit = lit
+				}
+			}
+			switch {
+			case satisfied:
+				continue
+			case unassigned == 0:
+				// The core contradicts itself
+				return steps
+			case unassigned == 1:
+				add(unit, false)
+				changed = true
+			}
+		}
+	}
+	return steps
+}
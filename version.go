@@ -0,0 +1,169 @@
+package pakr
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// CompareVersions compares two version identifiers, returning
+// -1 if a is lower than b, 1 if a is higher than b, and 0 if they
+// are equal.
+//
+// As with semantic versioning, a version is split on its first "-" into
+// a release and a pre-release, such as "1.0.0" and "rc1" in "1.0.0-rc1".
+// The releases are compared first, and when they are equal, a version
+// without a pre-release is higher than one with a pre-release, so that
+// "1.0.0-rc1" is lower than "1.0.0". Otherwise the pre-releases are
+// compared.
+//
+// Each part is split into segments on any non-alphanumeric character
+// (such as "."). Segments are compared in order. Numeric segments
+// are compared by their value, so that "1.10.0" is higher than "1.9.0",
+// and all other segments are compared as strings. A numeric segment is
+// lower than a non-numeric one. When all common segments are equal, the
+// part with more segments is higher.
+func CompareVersions(a, b string) int {
+	aRel, aPre, aHasPre := strings.Cut(a, "-")
+	bRel, bPre, bHasPre := strings.Cut(b, "-")
+
+	if c := compareVersionParts(aRel, bRel); c != 0 {
+		return c
+	}
+	switch {
+	case aHasPre && !bHasPre:
+		return -1
+	case !aHasPre && bHasPre:
+		return 1
+	}
+	return compareVersionParts(aPre, bPre)
+}
+
+// compareVersionParts compares the release, or the
+// pre-release, of two versions by their segments
+func compareVersionParts(a, b string) int {
+	as := splitVersion(a)
+	bs := splitVersion(b)
+
+	for i := 0; i < len(as) && i < len(bs); i++ {
+		if c := compareVersionSegment(as[i], bs[i]); c != 0 {
+			return c
+		}
+	}
+
+	switch {
+	case len(as) < len(bs):
+		return -1
+	case len(as) > len(bs):
+		return 1
+	}
+	return 0
+}
+
+// splitVersion splits a version identifier into its
+// alphanumeric segments
+func splitVersion(v string) []string {
+	return strings.FieldsFunc(v, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// majorVersion returns the major version of a version identifier,
+// which is its first segment, without a leading "v" before a number.
+// Numeric major versions are normalized, so that "01" is "1".
+func majorVersion(v string) string {
+	segs := splitVersion(v)
+	if len(segs) == 0 {
+		return ""
+	}
+	major := segs[0]
+	if len(major) > 1 && (major[0] == 'v' || major[0] == 'V') && unicode.IsDigit(rune(major[1])) {
+		major = major[1:]
+	}
+	if n, err := strconv.ParseUint(major, 10, 64); err == nil {
+		return strconv.FormatUint(n, 10)
+	}
+	return major
+}
+
+// compareVersionSegment compares a single segment of two versions
+func compareVersionSegment(a, b string) int {
+	an, aErr := strconv.ParseUint(a, 10, 64)
+	bn, bErr := strconv.ParseUint(b, 10, 64)
+
+	switch {
+	case aErr == nil && bErr == nil:
+		if an < bn {
+			return -1
+		} else if an > bn {
+			return 1
+		}
+		return 0
+	case aErr == nil:
+		return -1
+	case bErr == nil:
+		return 1
+	}
+	return strings.Compare(a, b)
+}
+
+// CheckVersionOrdering checks that the versions of every Product in a
+// package index form a total order under a comparator, such as
+// CompareVersions(), which is used if cmp is nil. The sort modes of a
+// Resolver assume this, and choose unpredictably between versions that
+// can not be ordered. A Product is reported when the comparator finds two
+// different versions equal, or is inconsistent, such as ordering a before
+// b and b before a, or a before b and b before c but not a before c.
+// Returns an error for the first problem found in each Product, in
+// order of the product names, or nil if every Product is ordered.
+func CheckVersionOrdering(index []Dependency, cmp func(a, b string) int) []error {
+	if cmp == nil {
+		cmp = CompareVersions
+	}
+
+	versions := make(map[string][]string)
+	for _, p := range flattenDependencies(slices.Values(index)) {
+		versions[p.ProductName()] = append(versions[p.ProductName()], p.Version())
+	}
+	products := make([]string, 0, len(versions))
+	for product := range versions {
+		products = append(products, product)
+	}
+	sort.Strings(products)
+
+	var errs []error
+	for _, product := range products {
+		if err := checkVersionOrder(product, versions[product], cmp); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errs
+}
+
+// checkVersionOrder checks that the comparator orders the versions of a
+// single Product. After sorting, a total order places every version
+// strictly before all of the versions that follow it.
+func checkVersionOrder(product string, vers []string, cmp func(a, b string) int) error {
+	vers = slices.Compact(slices.Sorted(slices.Values(vers)))
+	for _, v := range vers {
+		if cmp(v, v) != 0 {
+			return fmt.Errorf("Product %s version %s does not compare as equal to itself", product, v)
+		}
+	}
+
+	sort.SliceStable(vers, func(i, j int) bool { return cmp(vers[i], vers[j]) < 0 })
+	for i, a := range vers {
+		for _, b := range vers[i+1:] {
+			switch ab, ba := cmp(a, b), cmp(b, a); {
+			case ab == 0 && ba == 0:
+				return fmt.Errorf("Product %s has different versions %s and %s that compare as equal", product, a, b)
+			case ab >= 0 || ba <= 0:
+				return fmt.Errorf("Product %s versions %s and %s are not consistently ordered", product, a, b)
+			}
+		}
+	}
+	return nil
+}